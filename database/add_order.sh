@@ -1,5 +1,7 @@
 #!/bin/bash
 # 交互式添加订单脚本
+# 订单的添加、查询与取消通过 sports-order orders 子命令完成，由程序按状态机校验并记录订单的变更记录
+# （order_events），不直接写数据库，因此 SQLite 与 PostgreSQL 都适用。
 
 set -e
 
@@ -10,13 +12,11 @@ YELLOW='\033[1;33m'
 BLUE='\033[0;34m'
 NC='\033[0m' # No Color
 
-# 数据库路径（相对于项目根目录）
-DB_PATH="sports-order.db"
-
 # 获取脚本所在目录的父目录（项目根目录）
 SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
 PROJECT_ROOT="$(dirname "$SCRIPT_DIR")"
-DB_FULL_PATH="$PROJECT_ROOT/$DB_PATH"
+APP="$PROJECT_ROOT/sports-order"
+CONFIG="$PROJECT_ROOT/config.yaml"
 
 # 打印带颜色的消息
 print_info() {
@@ -35,15 +35,25 @@ print_warning() {
     echo -e "${YELLOW}[WARNING]${NC} $1"
 }
 
-# 检查数据库是否存在
-check_database() {
-    if [ ! -f "$DB_FULL_PATH" ]; then
-        print_error "数据库文件不存在: $DB_FULL_PATH"
-        print_info "请先运行 'make init-db' 初始化数据库"
+# 检查程序与配置文件是否存在
+check_app() {
+    if [ ! -x "$APP" ]; then
+        print_error "程序不存在: $APP"
+        print_info "请先运行 'make build' 编译"
+        exit 1
+    fi
+    if [ ! -f "$CONFIG" ]; then
+        print_error "配置文件不存在: $CONFIG"
+        print_info "请先运行 'make config' 生成配置"
         exit 1
     fi
 }
 
+# 在项目根目录执行 sports-order orders 子命令（配置中的相对数据库路径基于当前目录）
+orders() {
+    (cd "$PROJECT_ROOT" && "$APP" orders "$1" -config "$CONFIG" "${@:2}")
+}
+
 # 验证日期格式 (YYYY-MM-DD)
 validate_date() {
     local date=$1
@@ -79,15 +89,23 @@ validate_venue() {
     return 0
 }
 
+# 输入表单名称（对应 config.yaml 中 forms 的键，回车使用默认表单）
+read_form() {
+    echo -n "请输入表单名称 (回车使用默认表单): "
+    read -r input_form
+    if [[ -n $input_form && ! $input_form =~ ^[A-Za-z0-9_-]+$ ]]; then
+        print_warning "表单名称只能包含字母、数字、下划线和短横线，已使用默认表单"
+        input_form=""
+    fi
+}
+
 # 显示现有订单
 show_orders() {
     print_info "当前待处理订单:"
-    echo "----------------------------------------"
-    sqlite3 -header -column "$DB_FULL_PATH" \
-        "SELECT id, date, hour || ':00-' || (hour+1) || ':00' AS time_slot, venue, status, created_at 
-         FROM orders 
-         WHERE status = 'PENDING' 
-         ORDER BY date, hour;"
+    for status in PENDING RETRYING; do
+        echo "---------------- $status ----------------"
+        orders list -status "$status"
+    done
     echo "----------------------------------------"
 }
 
@@ -98,7 +116,7 @@ show_menu() {
     echo "1. 添加新订单"
     echo "2. 查看待处理订单"
     echo "3. 查看所有订单"
-    echo "4. 删除订单"
+    echo "4. 取消订单"
     echo "5. 批量添加订单"
     echo "0. 退出"
     echo -e "${BLUE}==============================${NC}"
@@ -151,12 +169,16 @@ add_single_order() {
         fi
     done
     
+    # 输入表单
+    read_form
+    
     # 确认信息
     echo ""
     print_info "订单信息确认:"
     echo "  日期: $input_date"
     echo "  时段: ${input_hour}:00-$((input_hour+1)):00"
     echo "  场地: $input_venue 号场"
+    echo "  表单: ${input_form:-默认}"
     echo -n "确认添加? (Y/n): "
     read -r confirm
     
@@ -165,14 +187,10 @@ add_single_order() {
         return
     fi
     
-    # 插入数据库
-    sqlite3 "$DB_FULL_PATH" \
-        "INSERT INTO orders (date, hour, venue, status) VALUES ('$input_date', $input_hour, $input_venue, 'PENDING');"
-    
-    if [ $? -eq 0 ]; then
+    if orders add -date "$input_date" -hour "$input_hour" -venue "$input_venue" -form "$input_form"; then
         print_success "订单添加成功!"
     else
-        print_error "订单添加失败"
+        print_error "订单添加失败（同一表单的该日期、时段、场地可能已有订单）"
     fi
 }
 
@@ -181,40 +199,39 @@ show_all_orders() {
     echo ""
     print_info "所有订单:"
     echo "----------------------------------------"
-    sqlite3 -header -column "$DB_FULL_PATH" \
-        "SELECT id, date, hour || ':00-' || (hour+1) || ':00' AS time_slot, venue, status, created_at 
-         FROM orders 
-         ORDER BY date DESC, hour;"
+    orders list
     echo "----------------------------------------"
 }
 
-# 删除订单
-delete_order() {
+# 取消订单：订单置为 CANCELLED 并保留记录，取消后该时段可以重新添加；正被处理或已完成的订单不能取消
+cancel_order() {
     echo ""
     show_orders
-    echo -n "请输入要删除的订单ID (输入 0 取消): "
+    echo -n "请输入要取消的订单ID (输入 0 返回): "
     read -r order_id
     
     if [ "$order_id" = "0" ]; then
-        print_warning "已取消删除"
+        print_warning "已放弃取消"
         return
     fi
-    
-    # 检查订单是否存在
-    exists=$(sqlite3 "$DB_FULL_PATH" "SELECT COUNT(*) FROM orders WHERE id = $order_id;")
-    if [ "$exists" = "0" ]; then
-        print_error "订单不存在"
+    if [[ ! $order_id =~ ^[0-9]+$ ]]; then
+        print_error "订单ID无效"
         return
     fi
     
-    echo -n "确认删除订单 #$order_id? (y/N): "
+    echo -n "取消原因 (可留空): "
+    read -r reason
+    echo -n "确认取消订单 #$order_id? (y/N): "
     read -r confirm
     
     if [ "$confirm" = "y" ] || [ "$confirm" = "Y" ]; then
-        sqlite3 "$DB_FULL_PATH" "DELETE FROM orders WHERE id = $order_id;"
-        print_success "订单 #$order_id 已删除"
+        if orders cancel -reason "$reason" "$order_id"; then
+            print_success "订单 #$order_id 已取消"
+        else
+            print_error "订单 #$order_id 取消失败（订单不存在、正被处理或已完成）"
+        fi
     else
-        print_warning "已取消删除"
+        print_warning "已放弃取消"
     fi
 }
 
@@ -274,6 +291,9 @@ batch_add_orders() {
         fi
     done
     
+    # 输入表单
+    read_form
+    
     # 计算订单数量
     order_count=$((end_hour - start_hour + 1))
     
@@ -283,6 +303,7 @@ batch_add_orders() {
     echo "  日期: $input_date"
     echo "  时段: ${start_hour}:00 - $((end_hour+1)):00 (共 $order_count 个时段)"
     echo "  场地: $input_venue 号场"
+    echo "  表单: ${input_form:-默认}"
     echo -n "确认添加 $order_count 个订单? (Y/n): "
     read -r confirm
     
@@ -294,9 +315,7 @@ batch_add_orders() {
     # 批量插入
     success_count=0
     for hour in $(seq "$start_hour" "$end_hour"); do
-        sqlite3 "$DB_FULL_PATH" \
-            "INSERT INTO orders (date, hour, venue, status) VALUES ('$input_date', $hour, $input_venue, 'PENDING');"
-        if [ $? -eq 0 ]; then
+        if orders add -date "$input_date" -hour "$hour" -venue "$input_venue" -form "$input_form"; then
             success_count=$((success_count + 1))
         fi
     done
@@ -306,7 +325,7 @@ batch_add_orders() {
 
 # 主程序
 main() {
-    check_database
+    check_app
     
     echo -e "${GREEN}"
     echo "╔═══════════════════════════════════════╗"
@@ -329,7 +348,7 @@ main() {
                 show_all_orders
                 ;;
             4)
-                delete_order
+                cancel_order
                 ;;
             5)
                 batch_add_orders
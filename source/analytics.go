@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"sports_order/common"
+	"sports_order/service"
+)
+
+// weekdayNames 是星期的中文名称，下标与 time.Weekday 一致。
+var weekdayNames = []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// analyticsCommand 分发 analytics 的子命令：sample 采样、report 输出报告。
+func analyticsCommand(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: sports-order analytics <sample|report> [参数]")
+		return 2
+	}
+	switch args[0] {
+	case "sample":
+		return analyticsSample(ctx, args[1:])
+	case "report":
+		return analyticsReport(ctx, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知的 analytics 子命令: %s\n", args[0])
+		return 2
+	}
+}
+
+// analyticsSample 在开放时刻之后高频拉取目录，记录各时段各场地的约满时间。
+// 可在开放前启动（例如 cron 07:59），命令会等待到开放时刻再开始采样。
+func analyticsSample(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("analytics sample")
+	formName := fs.String("form", "", "表单名称，默认使用默认表单")
+	dateFlag := fs.String("date", "", "采样的预约日期 (YYYY-MM-DD)，默认今天 + 2 天")
+	windowSec := fs.Int("window", 0, "采样窗口（秒），默认取配置 analytics.window_sec")
+	intervalMs := fs.Int("interval", 0, "采样间隔（毫秒），默认取配置 analytics.interval_ms")
+	fs.Parse(args)
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	cfg := app.config.Analytics
+	if *windowSec > 0 {
+		cfg.WindowSec = *windowSec
+	}
+	if *intervalMs > 0 {
+		cfg.IntervalMs = *intervalMs
+	}
+	window := time.Duration(intOr(cfg.WindowSec, common.DefaultSampleWindowSec)) * time.Second
+	interval := time.Duration(intOr(cfg.IntervalMs, common.DefaultSampleIntervalMs)) * time.Millisecond
+
+	openAt, err := todayAt(stringOr(cfg.OpenTime, common.DefaultOpenTime))
+	if err != nil {
+		log.Printf("无效的开放时刻: %v", err)
+		return 1
+	}
+	if time.Now().After(openAt.Add(window)) {
+		log.Printf("今天的采样窗口已结束（开放时刻 %s，窗口 %v）", openAt.Format("15:04:05"), window)
+		return 1
+	}
+
+	date := *dateFlag
+	if date == "" {
+		date = time.Now().AddDate(0, 0, common.DaysAhead).Format("2006-01-02")
+	}
+
+	form, err := app.config.Form(*formName)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	apiClient, _, closeClient, err := buildAPIClient(ctx, app.config, app.repo, app.runID, "")
+	if err != nil {
+		log.Printf("初始化 API 客户端失败: %v", err)
+		return 1
+	}
+	defer closeClient()
+
+	// 等待开放时刻
+	if wait := time.Until(openAt); wait > 0 {
+		log.Printf("等待开放时刻 %s（%v）", openAt.Format("15:04:05"), wait.Round(time.Second))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return 1
+		}
+	}
+
+	booking := service.NewBookingService(apiClient, app.repo, &app.config.User, form)
+	sampler := service.NewFillSampler(booking, app.repo, interval, window)
+	fills, err := sampler.Sample(ctx, form.Name, date, openAt)
+	if err != nil {
+		log.Printf("采样失败: %v", err)
+		return 1
+	}
+
+	soldOut := 0
+	for _, fill := range fills {
+		if fill.FilledAt != nil {
+			soldOut++
+			fmt.Printf("%s %02d:00 %-6s 约满用时 %.1fs\n", fill.Date, fill.Hour, fill.VenueName, *fill.FillSeconds)
+		}
+	}
+	app.repo.CreateLogf(ctx, common.LogLevelInfo, nil, "约满采样完成 [%s] %s: %d/%d 个时段场地约满", form.Name, date, soldOut, len(fills))
+	fmt.Fprintf(os.Stderr, "%s: %d/%d 个时段场地在窗口内约满\n", date, soldOut, len(fills))
+	return 0
+}
+
+// analyticsReport 按星期/时段/场地汇总历史采样：约满比例与约满用时中位数。
+func analyticsReport(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("analytics report")
+	formName := fs.String("form", "", "表单名称，默认使用默认表单")
+	jsonOutput := fs.Bool("json", false, "以 JSON 输出")
+	fs.Parse(args)
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	form, err := app.config.Form(*formName)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	fills, err := app.repo.ListSlotFills(ctx, form.Name)
+	if err != nil {
+		log.Printf("查询采样结果失败: %v", err)
+		return 1
+	}
+	rows := service.BuildFillReport(fills)
+
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(rows, "", "  ")
+		fmt.Println(string(data))
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "星期\t时段\t场地\t采样次数\t约满比例\t约满用时中位数")
+	for _, row := range rows {
+		medianText := "-"
+		if row.MedianSeconds >= 0 {
+			medianText = fmt.Sprintf("%.1fs", row.MedianSeconds)
+		}
+		fmt.Fprintf(w, "%s\t%02d:00\t%s\t%d\t%.0f%%\t%s\n",
+			weekdayNames[row.Weekday], row.Hour, row.Venue, row.Observations, row.SoldOutPct, medianText)
+	}
+	w.Flush()
+	return 0
+}
+
+// todayAt 返回今天指定时刻（HH:MM 或 HH:MM:SS）的本地时间。
+func todayAt(clock string) (time.Time, error) {
+	layout := "15:04"
+	if len(clock) > 5 {
+		layout = "15:04:05"
+	}
+	t, err := time.ParseInLocation(layout, clock, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+}
+
+// intOr 在 v 未配置（<=0）时返回默认值。
+func intOr(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+// stringOr 在 v 为空时返回默认值。
+func stringOr(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
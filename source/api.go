@@ -2,22 +2,185 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"sports_order/common"
 )
 
-// httpRequest 发送 HTTP 请求，并统一处理请求头、超时与非 200 的错误响应。
-func httpRequest(method, url string, data []byte, authToken string) ([]byte, error) {
+// maxErrorBodySize 是读取错误响应体的上限。
+const maxErrorBodySize = 1 << 20
+
+// HTTPClient 是对外部 API 的 HTTP 实现（满足 APIClient 接口）。
+// 内部持有一个复用的 http.Client 与连接池，避免每次请求都重新建连与握手。
+type HTTPClient struct {
+	client *http.Client
+	dialer *net.Dialer
+
+	mu       sync.RWMutex
+	resolved map[string][]string // host -> 预解析得到的 IP 列表
+}
+
+// NewHTTPClient 按配置创建带连接池的 HTTPClient。
+func NewHTTPClient(cfg common.HTTPConfig) *HTTPClient {
+	c := &HTTPClient{
+		dialer: &net.Dialer{
+			Timeout:   secondsOr(cfg.DialTimeoutSec, common.DefaultDialTimeoutSec),
+			KeepAlive: 30 * time.Second,
+		},
+		resolved: make(map[string][]string),
+	}
+
+	poolSize := cfg.MaxIdleConns
+	if poolSize <= 0 {
+		poolSize = common.MaxConcurrentOrders
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           c.dialContext,
+		MaxIdleConns:          poolSize,
+		MaxIdleConnsPerHost:   poolSize,
+		IdleConnTimeout:       secondsOr(cfg.IdleConnTimeoutSec, common.DefaultIdleConnTimeoutSec),
+		TLSHandshakeTimeout:   secondsOr(cfg.TLSHandshakeTimeoutSec, common.DefaultTLSHandshakeTimeoutSec),
+		ExpectContinueTimeout: 1 * time.Second,
+		ForceAttemptHTTP2:     cfg.EnableHTTP2,
+	}
+	if !cfg.EnableHTTP2 {
+		// 非 nil 的空映射会关闭 HTTP/2 协商
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	c.client = &http.Client{
+		Transport: transport,
+		Timeout:   secondsOr(cfg.TimeoutSec, common.DefaultTimeoutSec),
+	}
+	return c
+}
+
+// secondsOr 将秒数配置转换为 time.Duration，未配置时使用默认值。
+func secondsOr(sec, def int) time.Duration {
+	if sec <= 0 {
+		sec = def
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// dialContext 优先使用预解析的 IP 建连，全部失败时退回常规 DNS 解析。
+func (c *HTTPClient) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return c.dialer.DialContext(ctx, network, addr)
+	}
+
+	c.mu.RLock()
+	ips := c.resolved[host]
+	c.mu.RUnlock()
+
+	for _, ip := range ips {
+		conn, err := c.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return c.dialer.DialContext(ctx, network, addr)
+}
+
+// Resolve 预先解析主机名并缓存结果，避免开抢时再做 DNS 查询。
+func (c *HTTPClient) Resolve(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return fmt.Errorf("DNS 解析 %s 失败: %w", host, err)
+	}
+
+	c.mu.Lock()
+	c.resolved[host] = addrs
+	c.mu.Unlock()
+	return nil
+}
+
+// Warmup 预解析目标地址并预先建立 conns 条连接放入连接池。
+// 预热失败不影响后续请求（请求时会重新建连），因此只返回首个错误供调用方记录。
+func (c *HTTPClient) Warmup(ctx context.Context, baseURL string, conns int) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("解析地址 %s 失败: %w", baseURL, err)
+	}
+	if err := c.Resolve(ctx, u.Hostname()); err != nil {
+		return err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL, nil)
+			if err == nil {
+				var resp *http.Response
+				if resp, err = c.client.Do(req); err == nil {
+					// 读完并关闭响应体，连接才会回到空闲池
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+			}
+			if err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("预热连接失败: %w", err)
+				}
+				errMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// Probe 向 baseURL 发送一次 HEAD 请求，返回往返耗时与本机相对服务端的时钟偏差
+// （服务端时间取自 Date 响应头，精度约 1 秒；没有 Date 头时 skew 为 0）。任何状态码都视为可达。
+func (c *HTTPClient) Probe(ctx context.Context, baseURL string) (rtt, skew time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	rtt = time.Since(start)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		// Date 只精确到秒，取该秒的中点；服务端大约在往返的中点生成响应
+		serverTime := date.Add(500 * time.Millisecond)
+		skew = start.Add(rtt / 2).Sub(serverTime)
+	}
+	return rtt, skew, nil
+}
+
+// do 发送 HTTP 请求，并统一处理请求头与非 200 的错误响应。
+func (c *HTTPClient) do(ctx context.Context, method, url string, data []byte, authToken string, headers map[string]string) ([]byte, error) {
 	var body io.Reader
 	if data != nil {
 		body = bytes.NewBuffer(data)
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -26,43 +189,37 @@ func httpRequest(method, url string, data []byte, authToken string) ([]byte, err
 	if authToken != "" {
 		req.Header.Set("Authorization", authToken)
 	}
-	for key, value := range common.HTTPHeaders {
+	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
 
-	client := &http.Client{Timeout: time.Duration(common.DefaultTimeoutSec) * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		errorBody := make([]byte, 1024)
-		n, _ := resp.Body.Read(errorBody)
-		if n > 0 {
-			return nil, fmt.Errorf("HTTP %d: %s - %s", resp.StatusCode, resp.Status, errorBody[:n])
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		// 保留完整错误响应体（设上限防止异常响应占满内存），并丢弃剩余部分以便连接复用
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		io.Copy(io.Discard, resp.Body)
+		return nil, &common.HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: errorBody}
 	}
 
 	return io.ReadAll(resp.Body)
 }
 
-// HTTPClient 是对外部 API 的简单 HTTP 实现（满足 APIClient 接口）。
-type HTTPClient struct{}
-
-// NewHTTPClient 创建一个新的 HTTPClient。
-func NewHTTPClient() *HTTPClient {
-	return &HTTPClient{}
+// Get 发起 GET 请求（不带授权）。
+func (c *HTTPClient) Get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, url, nil, "", headers)
 }
 
-// Get 发起 GET 请求（不带授权）。
-func (c *HTTPClient) Get(url string) ([]byte, error) {
-	return httpRequest(http.MethodGet, url, nil, "")
+// GetAuthorized 携带授权 token 发起 GET 请求，用于只读地验证 token（见 doctor -probe-token）。
+func (c *HTTPClient) GetAuthorized(ctx context.Context, url, auth string, headers map[string]string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, url, nil, auth, headers)
 }
 
 // Post 发起 POST 请求（可携带授权 token）。
-func (c *HTTPClient) Post(url string, data []byte, auth string) ([]byte, error) {
-	return httpRequest(http.MethodPost, url, data, auth)
+func (c *HTTPClient) Post(ctx context.Context, url string, data []byte, auth string, headers map[string]string) ([]byte, error) {
+	return c.do(ctx, http.MethodPost, url, data, auth, headers)
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sports_order/common"
+)
+
+// countingServer 是记录新建连接数的测试服务端。
+type countingServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	conns int
+}
+
+func newCountingServer(t *testing.T, handler http.HandlerFunc) *countingServer {
+	s := &countingServer{Server: httptest.NewUnstartedServer(handler)}
+	s.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+		}
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *countingServer) newConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// TestWarmupReusesConnections 预热建立的连接放入连接池，之后的请求复用而不再建连。
+func TestWarmupReusesConnections(t *testing.T) {
+	server := newCountingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	client := NewHTTPClient(common.HTTPConfig{})
+	ctx := context.Background()
+
+	if err := client.Warmup(ctx, server.URL, 3); err != nil {
+		t.Fatalf("Warmup: %v", err)
+	}
+	if got := server.newConns(); got != 3 {
+		t.Fatalf("connections after warm-up = %d, want 3", got)
+	}
+	for i := 0; i < 5; i++ {
+		if body, err := client.Get(ctx, server.URL+"/profile", nil); err != nil || string(body) != "ok" {
+			t.Fatalf("Get = %q, %v", body, err)
+		}
+	}
+	if got := server.newConns(); got != 3 {
+		t.Errorf("connections after requests = %d, want the 3 warmed ones reused", got)
+	}
+}
+
+// TestWarmupFailure 目标不可达或地址无效时返回错误，之后的请求不受影响。
+func TestWarmupFailure(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	client := NewHTTPClient(common.HTTPConfig{})
+	ctx := context.Background()
+
+	if err := client.Warmup(ctx, closed.URL, 2); err == nil || !strings.Contains(err.Error(), "预热连接失败") {
+		t.Errorf("Warmup(closed) = %v, want warm-up error", err)
+	}
+	if err := client.Warmup(ctx, "://bad", 1); err == nil {
+		t.Error("Warmup(invalid URL) succeeded")
+	}
+
+	server := newCountingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	if _, err := client.Get(ctx, server.URL, nil); err != nil {
+		t.Errorf("Get after failed warm-up: %v", err)
+	}
+}
+
+// TestDialUsesResolvedAddresses 建连优先使用预解析的 IP，全部失败时退回原地址。
+func TestDialUsesResolvedAddresses(t *testing.T) {
+	server := newCountingServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewHTTPClient(common.HTTPConfig{})
+	ctx := context.Background()
+
+	// 主机名无法解析，只能通过预解析的地址连上
+	client.resolved["sports-order.invalid"] = []string{"127.0.0.1"}
+	conn, err := client.dialContext(ctx, "tcp", net.JoinHostPort("sports-order.invalid", port))
+	if err != nil {
+		t.Fatalf("dial via resolved address: %v", err)
+	}
+	conn.Close()
+
+	// 预解析的地址不可用时退回原地址
+	client.resolved["127.0.0.1"] = []string{"not-an-ip"}
+	conn, err = client.dialContext(ctx, "tcp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		t.Fatalf("dial fallback: %v", err)
+	}
+	conn.Close()
+
+	if err := client.Resolve(ctx, "localhost"); err != nil {
+		t.Fatalf("Resolve(localhost): %v", err)
+	}
+	if len(client.resolved["localhost"]) == 0 {
+		t.Error("Resolve did not cache addresses for localhost")
+	}
+}
+
+// TestRequestTimeout 服务端无响应时请求在 http.timeout_sec 后失败，而不是一直挂起。
+func TestRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := newCountingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	client := NewHTTPClient(common.HTTPConfig{TimeoutSec: 1})
+
+	start := time.Now()
+	_, err := client.Post(context.Background(), server.URL, []byte("{}"), "token", nil)
+	if err == nil {
+		t.Fatal("Post to a hanging server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Post returned after %v, want about 1s", elapsed)
+	}
+
+	// ctx 的截止时间同样生效
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, _, err := client.Probe(ctx, server.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Probe err = %v, want deadline exceeded", err)
+	}
+}
+
+// TestProbe 任何状态码都视为可达，并按 Date 响应头计算时钟偏差；非 200 的请求返回 HTTPError。
+func TestProbe(t *testing.T) {
+	server := newCountingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-10*time.Second).UTC().Format(http.TimeFormat))
+		http.Error(w, `{"code":17936}`, http.StatusUnprocessableEntity)
+	})
+	client := NewHTTPClient(common.HTTPConfig{})
+	ctx := context.Background()
+
+	rtt, skew, err := client.Probe(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if rtt <= 0 || skew < 8*time.Second || skew > 12*time.Second {
+		t.Errorf("rtt = %v, skew = %v; want skew about 10s", rtt, skew)
+	}
+
+	_, err = client.Get(ctx, server.URL, nil)
+	httpErr, ok := common.AsHTTPError(err)
+	if !ok || httpErr.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(string(httpErr.Body), "17936") {
+		t.Errorf("Get err = %v, want HTTPError 422 with body", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"testing"
 
 	"sports_order/common"
@@ -42,12 +43,17 @@ func TestReadCatalogAndBook(t *testing.T) {
 		t.Log("警告: Token 为空，预定请求将会失败")
 	}
 
-	httpClient := NewHTTPClient()
-	bookingService := service.NewBookingService(httpClient, nil, &config.User)
+	form, err := config.Form("")
+	if err != nil {
+		t.Fatalf("读取表单配置失败: %v", err)
+	}
+
+	httpClient := NewHTTPClient(config.HTTP)
+	bookingService := service.NewBookingService(httpClient, nil, &config.User, form)
 
 	// Step 1: 读取 Catalog
 	t.Log("\n=== Step 1: 从真实 API 读取 Catalog ===")
-	catalogData, err := bookingService.GetCatalogData()
+	catalogData, err := bookingService.GetCatalogData(context.Background())
 	if err != nil {
 		t.Fatalf("读取 Catalog 失败: %v", err)
 	}
@@ -106,7 +112,7 @@ func TestReadCatalogAndBook(t *testing.T) {
 	t.Logf("  场地: %d号", slot.Venue)
 
 	// 尝试预定
-	err = bookingService.BookTimeSlot(catalogData, slot)
+	err = bookingService.BookTimeSlot(context.Background(), catalogData, slot)
 	if err != nil {
 		t.Logf("预定失败: %v", err)
 	} else {
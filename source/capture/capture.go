@@ -0,0 +1,226 @@
+// Package capture 从抓包导出文件（HAR 或 mitmproxy 的 JSON 流导出）中提取预约所需的凭据：
+// 找到目标表单过去提交的 form_data 请求，取出 Authorization token 以及学号、姓名、手机号和图片地址。
+package capture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"sports_order/common"
+)
+
+// Request 是抓包文件中的一条 HTTP 请求（两种格式统一后的形式）。
+type Request struct {
+	Method    string
+	URL       string
+	Headers   map[string]string // 键为小写的请求头名
+	Body      string
+	StartedAt time.Time
+}
+
+// Result 是从抓包中提取到的凭据。
+type Result struct {
+	User       common.User
+	Source     string    // 提取自哪个请求
+	CapturedAt time.Time // 该请求的时间，未知时为零值
+}
+
+// ErrNoSubmission 表示抓包中没有目标表单的 form_data 提交。
+var ErrNoSubmission = errors.New("抓包中没有找到该表单的 form_data 提交请求")
+
+// LoadFile 读取 HAR 或 mitmproxy JSON 导出文件。
+func LoadFile(path string) ([]Request, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取抓包文件失败: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse 解析抓包数据：顶层为对象且含 log.entries 时按 HAR 解析，顶层为数组时按 mitmproxy 流解析。
+func Parse(data []byte) ([]Request, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		return parseMitmproxy(data)
+	}
+	return parseHAR(data)
+}
+
+// harFile 是 HAR 1.2 中用到的部分字段。
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime string `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					Text string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+func parseHAR(data []byte) ([]Request, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("解析 HAR 失败: %w", err)
+	}
+	requests := make([]Request, 0, len(har.Log.Entries))
+	for _, entry := range har.Log.Entries {
+		req := Request{
+			Method:  strings.ToUpper(entry.Request.Method),
+			URL:     entry.Request.URL,
+			Headers: make(map[string]string),
+		}
+		for _, header := range entry.Request.Headers {
+			req.Headers[strings.ToLower(header.Name)] = header.Value
+		}
+		if entry.Request.PostData != nil {
+			req.Body = entry.Request.PostData.Text
+		}
+		req.StartedAt, _ = time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// mitmFlow 是 mitmproxy 导出的单个流（mitmweb 的 flows JSON，或 jsondump 插件的输出）。
+// 请求头为 [名, 值] 数组，请求体在 content 或 text 字段中。
+type mitmFlow struct {
+	Request struct {
+		Method         string      `json:"method"`
+		URL            string      `json:"url"`
+		Scheme         string      `json:"scheme"`
+		Host           string      `json:"host"`
+		Port           int         `json:"port"`
+		Path           string      `json:"path"`
+		Headers        [][2]string `json:"headers"`
+		Content        string      `json:"content"`
+		Text           string      `json:"text"`
+		TimestampStart float64     `json:"timestamp_start"`
+	} `json:"request"`
+}
+
+func parseMitmproxy(data []byte) ([]Request, error) {
+	var flows []mitmFlow
+	if err := json.Unmarshal(data, &flows); err != nil {
+		return nil, fmt.Errorf("解析 mitmproxy 导出失败: %w", err)
+	}
+	requests := make([]Request, 0, len(flows))
+	for _, flow := range flows {
+		r := flow.Request
+		req := Request{Method: strings.ToUpper(r.Method), URL: r.URL, Headers: make(map[string]string), Body: r.Content}
+		if req.URL == "" {
+			req.URL = fmt.Sprintf("%s://%s%s", r.Scheme, r.Host, r.Path)
+			if r.Port != 0 && r.Port != 80 && r.Port != 443 {
+				req.URL = fmt.Sprintf("%s://%s:%d%s", r.Scheme, r.Host, r.Port, r.Path)
+			}
+		}
+		if req.Body == "" {
+			req.Body = r.Text
+		}
+		for _, header := range r.Headers {
+			req.Headers[strings.ToLower(header[0])] = header[1]
+		}
+		if r.TimestampStart > 0 {
+			req.StartedAt = time.UnixMilli(int64(r.TimestampStart * 1000))
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+// Extract 在 requests 中找到 form 最近一次 form_data 提交，提取 token 与用户信息。
+// 提交请求没有携带 Authorization 时，使用同一域名下最近一次带 token 的请求。
+func Extract(requests []Request, form *common.FormConfig) (*Result, error) {
+	base, err := url.Parse(form.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("解析表单地址失败: %w", err)
+	}
+	submitPath := "/v1/" + form.FormID + "/form_data"
+
+	// 按时间排序（有请求缺少时间时保持文件中的顺序），取最后一次提交
+	sorted := append([]Request(nil), requests...)
+	if allTimed(sorted) {
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartedAt.Before(sorted[j].StartedAt) })
+	}
+
+	var submission *Request
+	token := ""
+	for i := range sorted {
+		req := &sorted[i]
+		u, err := url.Parse(req.URL)
+		if err != nil || u.Host != base.Host {
+			continue
+		}
+		if auth := req.Headers["authorization"]; auth != "" {
+			token = auth
+		}
+		if req.Method == "POST" && u.Path == submitPath && req.Body != "" {
+			submission = req
+		}
+	}
+	if submission == nil {
+		return nil, ErrNoSubmission
+	}
+
+	var body common.BookingRequest
+	if err := json.Unmarshal([]byte(submission.Body), &body); err != nil {
+		return nil, fmt.Errorf("解析 form_data 请求体失败: %w", err)
+	}
+
+	result := &Result{Source: submission.Method + " " + submission.URL, CapturedAt: submission.StartedAt}
+	result.User.Token = submission.Headers["authorization"]
+	if result.User.Token == "" {
+		result.User.Token = token
+	}
+	for _, field := range body.Catalogs {
+		switch field.Cid {
+		case form.Fields.Name:
+			result.User.Name = stringValue(field.Value)
+		case form.Fields.Phone:
+			result.User.Phone = stringValue(field.Value)
+		case form.Fields.StudentID:
+			result.User.StudentID = stringValue(field.Value)
+		case form.Fields.Image:
+			result.User.ImageURL = stringValue(field.Value)
+		}
+	}
+	return result, nil
+}
+
+func allTimed(requests []Request) bool {
+	for _, req := range requests {
+		if req.StartedAt.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// stringValue 取字段值：字符串直接返回，数组（如图片）取第一个元素。
+func stringValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []any:
+		if len(v) > 0 {
+			if s, ok := v[0].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
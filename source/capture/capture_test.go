@@ -0,0 +1,102 @@
+package capture
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"sports_order/common"
+)
+
+// submissionBody 构造一次过去的 form_data 提交请求体。
+func submissionBody(t *testing.T, form *common.FormConfig) string {
+	t.Helper()
+	body, err := json.Marshal(common.BookingRequest{
+		Catalogs: []common.RequestField{
+			{Type: common.TypeWord, Cid: form.Fields.Name, Value: "张三"},
+			{Type: common.TypeTelephone, Cid: form.Fields.Phone, Value: "13800138000"},
+			{Type: common.TypeWord, Cid: form.Fields.StudentID, Value: "20231234567"},
+			{Type: common.TypeImage, Cid: form.Fields.Image, Value: []string{"https://oss2.qun100.com/a/V2/form1/qbq7lqHseqa2a68d88f.jpg"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestExtractFromHAR(t *testing.T) {
+	form := common.DefaultFormConfig()
+	har := map[string]any{"log": map[string]any{"entries": []any{
+		map[string]any{
+			"startedDateTime": "2025-12-20T08:00:00.000+08:00",
+			"request": map[string]any{
+				"method":  "GET",
+				"url":     form.ProfileURL(),
+				"headers": []any{map[string]string{"name": "Authorization", "value": "old-token"}},
+			},
+		},
+		map[string]any{
+			"startedDateTime": "2025-12-20T08:00:01.000+08:00",
+			"request": map[string]any{
+				"method":   "POST",
+				"url":      form.FormDataURL(),
+				"headers":  []any{map[string]string{"name": "authorization", "value": "new-token"}},
+				"postData": map[string]string{"mimeType": "application/json", "text": submissionBody(t, form)},
+			},
+		},
+		map[string]any{
+			"startedDateTime": "2025-12-20T08:00:02.000+08:00",
+			"request":         map[string]any{"method": "POST", "url": "https://example.com/v1/x/form_data", "postData": map[string]string{"text": "{}"}},
+		},
+	}}}
+	data, _ := json.Marshal(har)
+
+	requests, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	result, err := Extract(requests, form)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := common.User{
+		StudentID: "20231234567", Name: "张三", Phone: "13800138000",
+		ImageURL: "https://oss2.qun100.com/a/V2/form1/qbq7lqHseqa2a68d88f.jpg", Token: "new-token",
+	}
+	if result.User != want {
+		t.Fatalf("user = %+v, want %+v", result.User, want)
+	}
+}
+
+func TestExtractFromMitmproxy(t *testing.T) {
+	form := common.DefaultFormConfig()
+	flows := []any{
+		map[string]any{"request": map[string]any{
+			"method": "GET", "scheme": "https", "host": "form.qun100.com", "port": 443,
+			"path": "/v1/form/" + form.FormID + "/catalog", "headers": [][2]string{{"Authorization", "mitm-token"}},
+		}},
+		map[string]any{"request": map[string]any{
+			"method": "POST", "scheme": "https", "host": "form.qun100.com", "port": 443,
+			"path": "/v1/" + form.FormID + "/form_data", "text": submissionBody(t, form),
+		}},
+	}
+	data, _ := json.Marshal(flows)
+
+	requests, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	result, err := Extract(requests, form)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	// 提交请求本身没有 token 时，使用同域名下之前请求携带的 token
+	if result.User.Token != "mitm-token" || result.User.Phone != "13800138000" {
+		t.Fatalf("user = %+v", result.User)
+	}
+
+	if _, err := Extract(requests[:1], form); !errors.Is(err, ErrNoSubmission) {
+		t.Fatalf("Extract without submission err = %v, want ErrNoSubmission", err)
+	}
+}
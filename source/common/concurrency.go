@@ -0,0 +1,9 @@
+package common
+
+// GlobalLimit 返回同时提交订单的总数上限，未配置时为 MaxConcurrentOrders。
+func (c ConcurrencyConfig) GlobalLimit() int {
+	if c.Global <= 0 {
+		return MaxConcurrentOrders
+	}
+	return c.Global
+}
@@ -1,22 +1,28 @@
 package common
 
-// API 地址与路径常量
+import "time"
+
+// API 路径常量
 const (
-	BaseURL         = "https://form.qun100.com"
-	FormID          = "1627049420674297856"
-	FormURL         = BaseURL + "/v1/form/" + FormID + "/"
-	FormDataURL     = BaseURL + "/v1/" + FormID + "/form_data"
 	ProfileEndpoint = "profile"
 	CatalogEndpoint = "catalog"
 )
 
-// 表单字段 CID（Content ID）
+// 默认表单（config.yaml 未配置 forms 时使用）
 const (
-	CIDName        = "1627049422343630849"
-	CIDPhone       = "1627049422343630851"
-	CIDStudentID   = "1628873244736188417"
-	CIDImage       = "1627056232015765504"
-	CIDReservation = "1627049422343630855"
+	DefaultFormName = "default"
+	DefaultBaseURL  = "https://form.qun100.com"
+	DefaultFormID   = "1627049420674297856"
+	DefaultAppID    = "wxfc4ef6d539d03373"
+)
+
+// 默认表单字段 CID（Content ID）
+const (
+	DefaultCIDName        = "1627049422343630849"
+	DefaultCIDPhone       = "1627049422343630851"
+	DefaultCIDStudentID   = "1628873244736188417"
+	DefaultCIDImage       = "1627056232015765504"
+	DefaultCIDReservation = "1627049422343630855"
 )
 
 // 表单字段类型
@@ -27,26 +33,60 @@ const (
 	TypeReservation = "RESERVATION"
 )
 
-// ShowQuestions 指定提交预约时需要展示/提交的字段 CID 列表。
-var ShowQuestions = []string{CIDName, CIDPhone, CIDStudentID, CIDImage, CIDReservation}
-
-// HTTPHeaders 是调用外部表单 API 时需要携带的一组固定请求头。
-var HTTPHeaders = map[string]string{
-	"client-form-id": FormID,
-	"client-app-id":  "wxfc4ef6d539d03373",
-	"Content-Type":   "application/json",
-}
-
 // 对外 API 返回码
 const ResponseCodeSuccess = 0
 
+// FormVersionMismatchKeywords 是服务端因表单版本过期而拒绝时，原因中可能出现的关键字。
+var FormVersionMismatchKeywords = []string{"版本", "表单已更新", "表单已修改", "请刷新", "formVersion"}
+
+// MaxVersionRetries 是因表单版本不一致而重新拉取目录并重试的最大次数。
+const MaxVersionRetries = 2
+
 // 默认值
 const (
-	DefaultVenueCount = 1
-	DefaultTimeoutSec = 30
-	DaysAhead         = 2 // 处理"今天 + N 天"的订单
+	DefaultVenueCount   = 1
+	DefaultVenue        = 4  // 新建订单未指定场地时使用，与 orders.venue 的列默认值一致
+	DaysAhead           = 2  // 处理"今天 + N 天"的订单
+	MaxConcurrentOrders = 10 // 同时处理订单的默认最大并发数（run.concurrency.global）
+	DefaultCassetteDir  = "cassettes"
+)
+
+// 约满时间采样默认值
+const (
+	DefaultOpenTime         = "08:00"
+	DefaultSampleIntervalMs = 500
+	DefaultSampleWindowSec  = 300
+)
+
+// HTTP 客户端默认值（config.yaml 的 http 段未配置时使用）
+const (
+	DefaultTimeoutSec             = 30
+	DefaultDialTimeoutSec         = 5
+	DefaultTLSHandshakeTimeoutSec = 5
+	DefaultIdleConnTimeoutSec     = 90
+)
+
+// 表单状态（profile 的 status 字段）。抓包样本中正在收集的表单为 2，其余状态均视为暂停或关闭。
+const FormStatusActive = 2
+
+// FileStatusExpired 表示 fileLifeCycle 中的文件已过期失效。
+const FileStatusExpired = -2
+
+// DefaultImageWarnDays 是图片到期前开始告警的默认天数。
+const DefaultImageWarnDays = 7
+
+// doctor 检查的时钟偏差阈值：超过 ClockSkewWarn 告警，超过 ClockSkewFail 视为阻断。
+const (
+	ClockSkewWarn = 2 * time.Second
+	ClockSkewFail = 30 * time.Second
 )
 
+// DefaultPassphraseEnv 是未配置 credentials.passphrase_env 时读取凭据口令的环境变量。
+const DefaultPassphraseEnv = "SPORTS_ORDER_PASSPHRASE"
+
+// ProfileTimeLayout 是 profile 中时间字段的格式（本地时间）。
+const ProfileTimeLayout = "2006-01-02 15:04:05"
+
 // CatalogRole 表示 catalog 节点的角色类型。
 type CatalogRole string
 
@@ -67,8 +107,61 @@ const (
 // OrderStatus 表示订单状态。
 type OrderStatus string
 
+// 允许的状态变更见 order_state.go。
+const (
+	OrderStatusPending    OrderStatus = "PENDING"     // 等待预约日到来
+	OrderStatusScheduled  OrderStatus = "SCHEDULED"   // 已被某次运行认领（带租约），尚未提交
+	OrderStatusInProgress OrderStatus = "IN_PROGRESS" // 正在提交预约（带租约）
+	OrderStatusRetrying   OrderStatus = "RETRYING"    // 上次未能提交（表单临时不可用）或已核对未预约，下次运行重试
+	OrderStatusUnknown    OrderStatus = "UNKNOWN"     // 提交被中断或认领者失联，无法确认是否已预约成功，不再自动提交，等待人工核对
+	OrderStatusSuccess    OrderStatus = "SUCCESS"
+	OrderStatusFailed     OrderStatus = "FAILED"
+	OrderStatusCancelled  OrderStatus = "CANCELLED" // 手动取消
+	OrderStatusExpired    OrderStatus = "EXPIRED"   // 预约日期已过仍未完成
+)
+
+// OrderEventType 表示订单变更记录的类型。
+type OrderEventType string
+
+const (
+	OrderEventCreated OrderEventType = "CREATED" // 新建订单
+	OrderEventUpdated OrderEventType = "UPDATED" // 修改日期、时段、场地或表单
+	OrderEventStatus  OrderEventType = "STATUS"  // 状态变更（认领、提交、完成、退回、取消等）
+)
+
+// DefaultLeaseSec 是订单认领租约的默认时长（秒）。处理期间每三分之一租约续期一次，
+// 进程崩溃后租约到期，订单可被其他进程重新认领。
+const DefaultLeaseSec = 120
+
+// 单实例锁：持有者每 LockHeartbeatInterval 刷新一次心跳，超过 LockStaleAfter 未刷新视为失效，可被接管。
+const (
+	LockHeartbeatInterval = 10 * time.Second
+	LockStaleAfter        = 3 * LockHeartbeatInterval
+)
+
+// RunLockName 是 run 命令使用的单实例锁名称。
+const RunLockName = "run"
+
+// SQLiteBusyTimeoutMs 是 SQLite 等待其他连接释放写锁的时长，多个进程同时认领订单时避免立即报 database is locked。
+const SQLiteBusyTimeoutMs = 5000
+
+// CatalogEventType 表示快照差异的类型。
+type CatalogEventType string
+
+const (
+	EventFormVersionChanged CatalogEventType = "FORM_VERSION_CHANGED" // 表单版本变化
+	EventDateAdded          CatalogEventType = "DATE_ADDED"           // 新日期放出
+	EventDateRemoved        CatalogEventType = "DATE_REMOVED"         // 日期下线
+	EventSlotAdded          CatalogEventType = "SLOT_ADDED"           // 新增时段
+	EventSlotRemoved        CatalogEventType = "SLOT_REMOVED"         // 时段被移除
+	EventVenueAdded         CatalogEventType = "VENUE_ADDED"          // 新增场地
+	EventVenueRemoved       CatalogEventType = "VENUE_REMOVED"        // 场地被移除
+	EventVenueRenamed       CatalogEventType = "VENUE_RENAMED"        // 场地改名
+	EventCapacityChanged    CatalogEventType = "CAPACITY_CHANGED"     // 时段容量变化
+)
+
+// 数据库驱动
 const (
-	OrderStatusPending OrderStatus = "PENDING"
-	OrderStatusSuccess OrderStatus = "SUCCESS"
-	OrderStatusFailed  OrderStatus = "FAILED"
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
 )
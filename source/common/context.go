@@ -0,0 +1,32 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// runIDKey 是运行 ID 在 context 中的键。
+type runIDKey struct{}
+
+// WithRunID 返回携带运行 ID 的 context，日志会自动带上该 ID。
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// RunIDFrom 从 context 中取出运行 ID，不存在时返回空字符串。
+func RunIDFrom(ctx context.Context) string {
+	if runID, ok := ctx.Value(runIDKey{}).(string); ok {
+		return runID
+	}
+	return ""
+}
+
+// LeaseOwner 返回本进程认领订单时使用的标识：主机名、PID 与 ctx 中的运行 ID。
+func LeaseOwner(ctx context.Context) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), RunIDFrom(ctx))
+}
@@ -0,0 +1,17 @@
+package common
+
+// DriverName 返回数据库驱动，未配置时为 sqlite。
+func (d DatabaseConfig) DriverName() string {
+	if d.Driver == "" {
+		return DriverSQLite
+	}
+	return d.Driver
+}
+
+// Target 返回用于日志与提示的数据库位置：SQLite 为文件路径，PostgreSQL 为隐藏密码后的连接串。
+func (d DatabaseConfig) Target() string {
+	if d.DriverName() == DriverPostgres {
+		return RedactDSN(d.DSN)
+	}
+	return d.Path
+}
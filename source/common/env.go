@@ -0,0 +1,68 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix 是覆盖配置项的环境变量前缀。变量名由 YAML 路径转成大写、以下划线连接，
+// 如 SPORTS_ORDER_USER_TOKEN 覆盖 user.token，SPORTS_ORDER_HTTP_TIMEOUT_SEC 覆盖 http.timeout_sec。
+// forms 下的表单配置不支持覆盖。
+const EnvPrefix = "SPORTS_ORDER_"
+
+// ApplyEnvOverrides 用环境变量覆盖配置中的标量字段，lookup 通常为 os.LookupEnv。
+// 返回被覆盖的环境变量名，值无法解析时返回错误。
+func (c *Config) ApplyEnvOverrides(lookup func(string) (string, bool)) ([]string, error) {
+	var applied []string
+	err := applyEnv(reflect.ValueOf(c).Elem(), strings.TrimSuffix(EnvPrefix, "_"), lookup, &applied)
+	return applied, err
+}
+
+func applyEnv(v reflect.Value, name string, lookup func(string) (string, bool), applied *[]string) error {
+	if v.Kind() == reflect.Struct {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			if tag == "" || tag == "-" {
+				continue
+			}
+			if err := applyEnv(v.Field(i), name+"_"+strings.ToUpper(tag), lookup, applied); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	value, ok := lookup(name)
+	if !ok {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 应为整数: %q", name, value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 应为数字: %q", name, value)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 应为 true/false: %q", name, value)
+		}
+		v.SetBool(b)
+	default:
+		return nil // map 等类型不支持覆盖
+	}
+	*applied = append(*applied, name)
+	return nil
+}
@@ -0,0 +1,155 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrFormVersionMismatch 表示提交的 formVersion 与服务端当前表单版本不一致。
+var ErrFormVersionMismatch = errors.New("表单版本不一致")
+
+// ErrFormUnavailable 表示表单已暂停、过期或超过截止时间，不再接受提交。
+var ErrFormUnavailable = errors.New("表单不可预约")
+
+// ErrImageExpired 表示配置的图片（image_url）已过期，提交会被拒绝或附件无法查看。
+var ErrImageExpired = errors.New("图片已过期")
+
+// ErrLeaseLost 表示订单租约已过期并被其他进程接管，本进程不应再提交或落库。
+var ErrLeaseLost = errors.New("订单租约已失效")
+
+// ErrAccountLimit 表示提交订单会超出账号在表单上的预约额度（每人次数上限、每天场次上限）。
+var ErrAccountLimit = errors.New("超出账号预约额度")
+
+// ErrLockLost 表示单实例锁已被其他进程接管（本进程心跳中断过久），应停止处理。
+var ErrLockLost = errors.New("单实例锁已失效")
+
+// TransitionError 表示订单状态变更不被状态机允许。
+type TransitionError struct {
+	OrderID uint
+	From    OrderStatus
+	To      OrderStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("订单 %d 不能从 %s 变为 %s", e.OrderID, e.From, e.To)
+}
+
+// SlotError 表示要预约的日期、时段或场地号在目录中不存在。
+type SlotError struct {
+	Message string
+}
+
+func (e *SlotError) Error() string {
+	return e.Message
+}
+
+// MaxErrorBodyInMessage 是错误信息中保留的响应体字节数，完整响应体保存在 HTTPError.Body。
+const MaxErrorBodyInMessage = 1024
+
+// HTTPError 表示对外 API 返回了非 200 响应。
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte // 完整响应体
+}
+
+func (e *HTTPError) Error() string {
+	body := e.Body
+	if len(body) > MaxErrorBodyInMessage {
+		body = body[:MaxErrorBodyInMessage]
+	}
+	if len(body) > 0 {
+		return fmt.Sprintf("HTTP %d: %s - %s", e.StatusCode, e.Status, body)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Status)
+}
+
+// Retryable 判断该响应是否值得重试（限流或服务端错误）。
+func (e *HTTPError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// AsHTTPError 从错误链中取出 HTTPError。
+func AsHTTPError(err error) (*HTTPError, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr, true
+	}
+	return nil, false
+}
+
+// RejectionError 表示服务端收到了预约请求但拒绝受理（业务码非 0）。
+type RejectionError struct {
+	StatusCode int    // HTTP 状态码（业务码放在 200 响应中时为 200）
+	Code       int    // 业务码
+	Message    string // 服务端给出的原因
+}
+
+func (e *RejectionError) Error() string {
+	if e.StatusCode != http.StatusOK {
+		return fmt.Sprintf("预约失败 (HTTP %d, code %d): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("预约失败: %s", e.Message)
+}
+
+// IsVersionMismatchMessage 判断拒绝原因是否提示表单版本已变化。
+func IsVersionMismatchMessage(message string) bool {
+	for _, keyword := range FormVersionMismatchKeywords {
+		if strings.Contains(message, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorClass 是订单错误的分类，便于按类统计与告警。
+type ErrorClass string
+
+const (
+	ErrorClassRejected        ErrorClass = "REJECTED"         // 服务端拒绝受理（业务码非 0）
+	ErrorClassHTTP            ErrorClass = "HTTP"             // 服务端返回非 200 响应
+	ErrorClassNetwork         ErrorClass = "NETWORK"          // 连接、超时等网络错误
+	ErrorClassSlot            ErrorClass = "SLOT"             // 日期、时段或场地不在目录中
+	ErrorClassFormUnavailable ErrorClass = "FORM_UNAVAILABLE" // 表单暂停、过期或截止
+	ErrorClassImageExpired    ErrorClass = "IMAGE_EXPIRED"    // image_url 图片已过期
+	ErrorClassLeaseLost       ErrorClass = "LEASE_LOST"       // 订单租约已被其他进程接管
+	ErrorClassCanceled        ErrorClass = "CANCELED"         // 运行被取消或超时
+	ErrorClassLimit           ErrorClass = "LIMIT"            // 超出账号预约额度
+	ErrorClassOther           ErrorClass = "OTHER"
+)
+
+// ClassifyError 返回错误的分类，err 为 nil 时返回空字符串。
+func ClassifyError(err error) ErrorClass {
+	var rejection *RejectionError
+	var slotErr *SlotError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrLeaseLost):
+		return ErrorClassLeaseLost
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassCanceled
+	case errors.Is(err, ErrFormUnavailable):
+		return ErrorClassFormUnavailable
+	case errors.Is(err, ErrImageExpired):
+		return ErrorClassImageExpired
+	case errors.Is(err, ErrAccountLimit):
+		return ErrorClassLimit
+	case errors.As(err, &rejection):
+		return ErrorClassRejected
+	case errors.As(err, &slotErr):
+		return ErrorClassSlot
+	}
+	if _, ok := AsHTTPError(err); ok {
+		return ErrorClassHTTP
+	}
+	if errors.As(err, &netErr) {
+		return ErrorClassNetwork
+	}
+	return ErrorClassOther
+}
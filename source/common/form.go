@@ -0,0 +1,127 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ============================================================================
+// 表单描述（由 config.yaml 的 forms 配置生成）
+// ============================================================================
+
+// DefaultFormConfig 返回内置的默认表单配置（羽毛球馆场地预约）。
+func DefaultFormConfig() *FormConfig {
+	return &FormConfig{
+		Name:    DefaultFormName,
+		BaseURL: DefaultBaseURL,
+		FormID:  DefaultFormID,
+		AppID:   DefaultAppID,
+		Fields: FormFields{
+			Name:        DefaultCIDName,
+			Phone:       DefaultCIDPhone,
+			StudentID:   DefaultCIDStudentID,
+			Image:       DefaultCIDImage,
+			Reservation: DefaultCIDReservation,
+		},
+	}
+}
+
+// FormURL 返回表单元数据接口前缀，如 https://form.qun100.com/v1/form/{id}/。
+func (f *FormConfig) FormURL() string {
+	return f.BaseURL + "/v1/form/" + f.FormID + "/"
+}
+
+// ProfileURL 返回 profile 接口地址。
+func (f *FormConfig) ProfileURL() string {
+	return f.FormURL() + ProfileEndpoint
+}
+
+// CatalogURL 返回 catalog 接口地址。
+func (f *FormConfig) CatalogURL() string {
+	return f.FormURL() + CatalogEndpoint
+}
+
+// FormDataURL 返回提交预约的接口地址。
+func (f *FormConfig) FormDataURL() string {
+	return f.BaseURL + "/v1/" + f.FormID + "/form_data"
+}
+
+// Headers 返回调用该表单 API 时需要携带的一组固定请求头。
+func (f *FormConfig) Headers() map[string]string {
+	return map[string]string{
+		"client-form-id": f.FormID,
+		"client-app-id":  f.AppID,
+		"Content-Type":   "application/json",
+	}
+}
+
+// OrderForms 返回订单 form 列中指向该表单的写法：表单名称；默认表单另含空字符串
+// （早期版本的订单，启动时归入默认表单，与其他订单重复而无法归入的保持为空）。
+func (f *FormConfig) OrderForms() []string {
+	if f.Default {
+		return []string{f.Name, ""}
+	}
+	return []string{f.Name}
+}
+
+// ShowQuestions 返回提交预约时需要展示/提交的字段 CID 列表。
+func (f *FormConfig) ShowQuestions() []string {
+	return []string{f.Fields.Name, f.Fields.Phone, f.Fields.StudentID, f.Fields.Image, f.Fields.Reservation}
+}
+
+// Form 按名称查找表单配置；名称为空时返回默认表单。
+// 未配置 forms 时退回内置默认表单，以兼容旧版配置文件。
+func (c *Config) Form(name string) (*FormConfig, error) {
+	if len(c.Forms) == 0 {
+		if name == "" || name == DefaultFormName {
+			form := DefaultFormConfig()
+			form.Default = true
+			return form, nil
+		}
+		return nil, fmt.Errorf("未配置表单: %s", name)
+	}
+
+	defaultName := c.DefaultForm
+	if defaultName == "" && len(c.Forms) == 1 {
+		for key := range c.Forms {
+			defaultName = key
+		}
+	}
+	if name == "" {
+		name = defaultName
+	}
+
+	form, ok := c.Forms[name]
+	if !ok || form == nil {
+		return nil, fmt.Errorf("未配置表单: %q", name)
+	}
+	form.Name, form.Default = name, name == defaultName
+	if form.BaseURL == "" {
+		form.BaseURL = DefaultBaseURL
+	}
+	if form.AppID == "" {
+		form.AppID = DefaultAppID
+	}
+	return form, nil
+}
+
+// AllForms 返回全部已配置表单（按名称排序）；未配置 forms 时返回内置默认表单。
+func (c *Config) AllForms() []*FormConfig {
+	if len(c.Forms) == 0 {
+		return []*FormConfig{DefaultFormConfig()}
+	}
+
+	names := make([]string, 0, len(c.Forms))
+	for name := range c.Forms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	forms := make([]*FormConfig, 0, len(names))
+	for _, name := range names {
+		if form, err := c.Form(name); err == nil {
+			forms = append(forms, form)
+		}
+	}
+	return forms
+}
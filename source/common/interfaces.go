@@ -1,21 +1,59 @@
 package common
 
+import (
+	"context"
+	"time"
+)
+
 // ============================================================================
 // 接口定义（便于替换实现与单元测试）
 // ============================================================================
 
 // APIClient 抽象对外 HTTP 调用，便于 mock。
+// headers 为目标表单要求的固定请求头（见 FormConfig.Headers）。
 type APIClient interface {
-	Get(url string) ([]byte, error)
-	Post(url string, data []byte, auth string) ([]byte, error)
+	Get(ctx context.Context, url string, headers map[string]string) ([]byte, error)
+	Post(ctx context.Context, url string, data []byte, auth string, headers map[string]string) ([]byte, error)
 }
 
 // Repository 抽象数据库操作。
+// 日志方法会从 ctx 中读取运行 ID（见 WithRunID）一并落库。
 type Repository interface {
-	// 订单相关
-	FindOrdersByDate(date string) ([]*Order, error)
-	UpdateOrderStatus(id uint, status OrderStatus) error
+	// 订单相关：处理前先认领（→ SCHEDULED），认领者与租约由 owner、lease 指定；状态变更受状态机约束
+	RecoverExpiredLeases(ctx context.Context, date string) ([]*Order, error) // 回收租约已过期的订单，返回回收前的订单
+	ClaimOrders(ctx context.Context, date, owner string, lease time.Duration) ([]*Order, error)
+	RenewLeases(ctx context.Context, owner string, lease time.Duration) (int64, error)
+	TransitionOrder(ctx context.Context, id uint, owner string, to OrderStatus, cause error) error        // 租约已被接管时返回 ErrLeaseLost
+	ReconcileOrder(ctx context.Context, id uint, owner string, to OrderStatus, cause error) (bool, error) // 补写本地日志中的提交结果，见 OutcomeJournal
+	ReleaseOrders(ctx context.Context, owner string) (int64, error)                                       // 退回 owner 仍持有的订单
+	FindStaleOrders(ctx context.Context, before string) ([]*Order, error)                                 // 预约日期早于 before 且未进入终态的订单
+	CountSucceededOrders(ctx context.Context, filter SucceededFilter) (int64, error)                      // 预约成功的订单数，见 SucceededFilter
+	// 目录快照相关
+	SaveCatalogSnapshot(ctx context.Context, snapshot *CatalogSnapshot) error
+	LatestCatalogSnapshot(ctx context.Context, form string) (*CatalogSnapshot, error) // 无快照时返回 nil, nil
+	CatalogDates(ctx context.Context, form string) ([]string, error)                  // 历次快照中出现过的日期
+	CreateCatalogEvents(ctx context.Context, events []*CatalogEvent) error
+	// 约满时间采样相关
+	SaveSlotFills(ctx context.Context, fills []*SlotFill) error
+	ListSlotFills(ctx context.Context, form string) ([]*SlotFill, error)
 	// 日志相关
-	CreateLog(level LogLevel, message string, orderID *int) error
-	CreateLogf(level LogLevel, orderID *int, format string, args ...any) error
+	CreateLog(ctx context.Context, level LogLevel, message string, orderID *int) error
+	CreateLogf(ctx context.Context, level LogLevel, orderID *int, format string, args ...any) error
+}
+
+// SucceededFilter 是 CountSucceededOrders 的筛选条件，零值表示不限。
+type SucceededFilter struct {
+	Forms []string  // 订单 form 列的取值（见 FormConfig.OrderForms）
+	Date  string    // 预约日期
+	Venue int       // 场地号
+	Since time.Time // 预约成功时间不早于 Since
+	Until time.Time // 预约成功时间早于 Until
+}
+
+// OutcomeJournal 是订单提交结果的本地预写日志（见 journal 包）：结果先落盘再写数据库，
+// 数据库写入失败时下次启动据此补写，避免已预约成功的订单被再次提交。
+type OutcomeJournal interface {
+	Record(entry *JournalEntry) error // 落盘并分配 entry.ID
+	Applied(id string) error          // 标记记录已写入数据库
+	Pending() []*JournalEntry         // 尚未写入数据库的记录，按写入顺序
 }
@@ -0,0 +1,16 @@
+package common
+
+import "time"
+
+// JournalEntry 是本地结果日志中的一条记录：订单提交后的目标状态与日志，先于数据库落盘。
+type JournalEntry struct {
+	ID      string      `json:"id"` // 运行 ID + 序号，由 OutcomeJournal.Record 分配
+	OrderID uint        `json:"order_id"`
+	Owner   string      `json:"owner"` // 提交时的认领者
+	RunID   string      `json:"run_id"`
+	Status  OrderStatus `json:"status"`          // 提交后的目标状态：SUCCESS、FAILED、RETRYING 或 UNKNOWN
+	Error   string      `json:"error,omitempty"` // 失败原因，写入 last_error
+	Level   LogLevel    `json:"level"`
+	Message string      `json:"message"` // 订单日志
+	At      time.Time   `json:"at"`
+}
@@ -0,0 +1,92 @@
+package common
+
+// orderTransitions 列出每个状态允许变更到的状态。SUCCESS、FAILED、CANCELLED、EXPIRED 为终态。
+//
+//	PENDING     → SCHEDULED（被某次运行认领）、CANCELLED、EXPIRED
+//	SCHEDULED   → IN_PROGRESS（开始提交）、PENDING（未开始即释放）、FAILED（无法处理，如表单未配置）
+//	IN_PROGRESS → SUCCESS、FAILED、RETRYING（表单临时不可用）、UNKNOWN（提交被中断或认领者失联，结果未知）
+//	RETRYING    → SCHEDULED、CANCELLED、EXPIRED
+//	UNKNOWN     → SUCCESS、FAILED（核对后确认结果）、RETRYING（确认未预约，重新提交）、CANCELLED、EXPIRED
+//
+// UNKNOWN 不会被认领，避免同一订单被重复提交；由 orders resolve 人工核对后变更。
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:    {OrderStatusScheduled, OrderStatusCancelled, OrderStatusExpired},
+	OrderStatusScheduled:  {OrderStatusInProgress, OrderStatusPending, OrderStatusFailed},
+	OrderStatusInProgress: {OrderStatusSuccess, OrderStatusFailed, OrderStatusRetrying, OrderStatusUnknown},
+	OrderStatusRetrying:   {OrderStatusScheduled, OrderStatusCancelled, OrderStatusExpired},
+	OrderStatusUnknown:    {OrderStatusSuccess, OrderStatusFailed, OrderStatusRetrying, OrderStatusCancelled, OrderStatusExpired},
+}
+
+// orderStatusTimestamps 是进入各状态时写入的时间列（PENDING 使用 created_at，UNKNOWN 只记 status_changed_at）。
+var orderStatusTimestamps = map[OrderStatus]string{
+	OrderStatusScheduled:  "scheduled_at",
+	OrderStatusInProgress: "started_at",
+	OrderStatusRetrying:   "retrying_at",
+	OrderStatusSuccess:    "succeeded_at",
+	OrderStatusFailed:     "failed_at",
+	OrderStatusCancelled:  "cancelled_at",
+	OrderStatusExpired:    "expired_at",
+}
+
+// OrderStatuses 是全部合法的订单状态。
+var OrderStatuses = []OrderStatus{
+	OrderStatusPending, OrderStatusScheduled, OrderStatusInProgress, OrderStatusRetrying, OrderStatusUnknown,
+	OrderStatusSuccess, OrderStatusFailed, OrderStatusCancelled, OrderStatusExpired,
+}
+
+// Valid 判断是否为合法的订单状态。
+func (s OrderStatus) Valid() bool {
+	for _, status := range OrderStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminal 判断是否为终态。
+func (s OrderStatus) Terminal() bool {
+	return s.Valid() && len(orderTransitions[s]) == 0
+}
+
+// Leased 判断该状态的订单是否由某个进程持有租约。
+func (s OrderStatus) Leased() bool {
+	return s == OrderStatusScheduled || s == OrderStatusInProgress
+}
+
+// CanTransitionTo 判断能否从 s 变更到 to。
+func (s OrderStatus) CanTransitionTo(to OrderStatus) bool {
+	for _, next := range orderTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TimestampColumn 返回进入该状态时写入的时间列，PENDING 返回空字符串。
+func (s OrderStatus) TimestampColumn() string {
+	return orderStatusTimestamps[s]
+}
+
+// TerminalStatuses 返回全部终态。
+func TerminalStatuses() []OrderStatus {
+	var terminal []OrderStatus
+	for _, status := range OrderStatuses {
+		if status.Terminal() {
+			terminal = append(terminal, status)
+		}
+	}
+	return terminal
+}
+
+// StatusesBefore 返回可以变更到 to 的全部状态。
+func StatusesBefore(to OrderStatus) []OrderStatus {
+	var from []OrderStatus
+	for _, status := range OrderStatuses {
+		if status.CanTransitionTo(to) {
+			from = append(from, status)
+		}
+	}
+	return from
+}
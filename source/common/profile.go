@@ -0,0 +1,100 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Deadline 返回活动截止时间；未配置或无法解析时 ok 为 false。
+func (p *FormProfile) Deadline() (deadline time.Time, ok bool) {
+	if p.Config.ActEndTime == "" {
+		return time.Time{}, false
+	}
+	deadline, err := time.ParseInLocation(ProfileTimeLayout, p.Config.ActEndTime, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return deadline, true
+}
+
+// ActivityWindow 返回活动的起止时间，即 perLimit 的统计区间；未配置或无法解析的一端为零值（不限）。
+func (p *FormProfile) ActivityWindow() (begin, end time.Time) {
+	if p.Config.ActBeginTime != "" {
+		begin, _ = time.ParseInLocation(ProfileTimeLayout, p.Config.ActBeginTime, time.Local)
+	}
+	end, _ = p.Deadline()
+	return begin, end
+}
+
+// CheckAvailable 判断表单在 now 时刻是否仍接受提交。
+// 表单暂停、已过期或超过截止时间时返回包装了 ErrFormUnavailable 的错误。
+func (p *FormProfile) CheckAvailable(now time.Time) error {
+	switch {
+	case p.Status != FormStatusActive:
+		return fmt.Errorf("%w: 「%s」已暂停或关闭 (status=%d)", ErrFormUnavailable, p.Title, p.Status)
+	case p.ExpStatus != 0:
+		return fmt.Errorf("%w: 「%s」已过期 (expStatus=%d)", ErrFormUnavailable, p.Title, p.ExpStatus)
+	}
+	if deadline, ok := p.Deadline(); ok && now.After(deadline) {
+		return fmt.Errorf("%w: 「%s」已于 %s 截止", ErrFormUnavailable, p.Title, p.Config.ActEndTime)
+	}
+	return nil
+}
+
+// FileKeyFromURL 从文件地址中取出 fileLifeCycle 使用的 key（文件名去掉扩展名），
+// 如 https://oss2.qun100.com/ZDg5/V2/form1/qR3n_Fhrs9C6591c3b7.jpg -> qR3n_Fhrs9C6591c3b7。
+func FileKeyFromURL(fileURL string) string {
+	if i := strings.IndexAny(fileURL, "?#"); i >= 0 {
+		fileURL = fileURL[:i]
+	}
+	name := path.Base(fileURL)
+	if i := strings.LastIndex(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// ImageStatus 是配置图片在 fileLifeCycle 中的状态。
+type ImageStatus struct {
+	Key       string
+	Found     bool      // fileLifeCycle 中是否有该图片
+	ExpireAt  time.Time // 过期时间，Found 为 false 时为零值
+	Expired   bool      // 已失效（fileStatus 为 -2 或已过期）
+	ExpiresIn time.Duration
+}
+
+// ImageStatus 查找 imageURL 对应的有效期信息。
+func (p *FormProfile) ImageStatus(imageURL string, now time.Time) ImageStatus {
+	status := ImageStatus{Key: FileKeyFromURL(imageURL)}
+	entry, ok := p.FileLifeCycle[status.Key]
+	if !ok {
+		return status
+	}
+	status.Found = true
+	status.ExpireAt = time.UnixMilli(entry.ExpireAt)
+	status.ExpiresIn = status.ExpireAt.Sub(now)
+	status.Expired = entry.FileStatus == FileStatusExpired || (entry.ExpireAt > 0 && status.ExpiresIn <= 0)
+	return status
+}
+
+// CheckImage 核对配置的图片是否仍然有效：已过期时返回包装了 ErrImageExpired 的错误；
+// 将在 warnBefore 内过期或无法核对时返回告警信息。
+func (p *FormProfile) CheckImage(imageURL string, now time.Time, warnBefore time.Duration) (warning string, err error) {
+	if imageURL == "" {
+		return "未配置 image_url", nil
+	}
+	status := p.ImageStatus(imageURL, now)
+	switch {
+	case !status.Found:
+		return fmt.Sprintf("表单 fileLifeCycle 中没有图片 %s，无法核对有效期", status.Key), nil
+	case status.Expired:
+		return "", fmt.Errorf("%w: %s 已于 %s 过期，请重新上传并更新 image_url",
+			ErrImageExpired, status.Key, status.ExpireAt.Format(ProfileTimeLayout))
+	case status.ExpiresIn <= warnBefore:
+		return fmt.Sprintf("图片 %s 将于 %s 过期（剩余 %.1f 天），请尽快重新上传",
+			status.Key, status.ExpireAt.Format(ProfileTimeLayout), status.ExpiresIn.Hours()/24), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RedactedPlaceholder 是脱敏后替换敏感值的占位符。
+const RedactedPlaceholder = "***"
+
+// Redactor 将文本中出现的敏感值（token、手机号、学号等）替换为占位符。
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor 创建脱敏器，空字符串会被忽略。
+func NewRedactor(secrets ...string) *Redactor {
+	r := &Redactor{}
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	// 先替换较长的值，避免短值截断长值
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	return r
+}
+
+// UserRedactor 针对用户的身份信息与 token 创建脱敏器。
+func UserRedactor(user *User) *Redactor {
+	if user == nil {
+		return NewRedactor()
+	}
+	return NewRedactor(user.Token, user.Phone, user.StudentID, user.Name)
+}
+
+// Redact 返回脱敏后的文本。
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, RedactedPlaceholder)
+	}
+	return s
+}
+
+// MaskSecret 仅保留首尾少量字符用于辨认，例如 "hEBO***Qm7".
+// 按字符而非字节截取，中文姓名不会被截成乱码。
+func MaskSecret(s string) string {
+	runes := []rune(s)
+	if len(runes) <= 8 {
+		if s == "" {
+			return ""
+		}
+		return RedactedPlaceholder
+	}
+	return string(runes[:4]) + RedactedPlaceholder + string(runes[len(runes)-3:])
+}
+
+// dsnPasswordPattern 匹配 key=value 形式连接串中的 password 项。
+var dsnPasswordPattern = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// RedactDSN 隐藏数据库连接串中的密码，支持 URL 与 key=value 两种形式。
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		// Redacted 用 xxxxx 代替密码，这里统一为占位符
+		return strings.Replace(u.Redacted(), ":xxxxx@", ":"+RedactedPlaceholder+"@", 1)
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "${1}"+RedactedPlaceholder)
+}
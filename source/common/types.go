@@ -6,10 +6,39 @@ package common
 
 // ProfileResponse 对应 profile 接口响应。
 type ProfileResponse struct {
-	Code int `json:"code"`
-	Data struct {
-		Version int `json:"version"`
-	} `json:"data"`
+	Code int         `json:"code"`
+	Data FormProfile `json:"data"`
+}
+
+// FormProfile 是 profile 接口返回的表单信息（只解析用得到的字段）。
+type FormProfile struct {
+	FormID     string            `json:"formId"`
+	Title      string            `json:"title"`
+	Type       string            `json:"type"`
+	Version    int               `json:"version"`
+	Status     int               `json:"status"`    // 表单状态，见 FormStatus*
+	ExpStatus  int               `json:"expStatus"` // 过期状态，非 0 表示已过期
+	CreateTime string            `json:"createTime"`
+	ModifyTime string            `json:"modifyTime"`
+	Config     FormProfileConfig `json:"config"`
+
+	FileLifeCycle map[string]FileLifeCycle `json:"fileLifeCycle"` // 文件 key -> 有效期信息
+}
+
+// FileLifeCycle 是已上传文件（如提交时附带的图片）的有效期信息。
+type FileLifeCycle struct {
+	ExpireAt   int64  `json:"expireAt"`   // 过期时间（毫秒时间戳）
+	UploadTime string `json:"uploadTime"` // 上传时间，"2006-01-02 15:04:05"
+	FileStatus int    `json:"fileStatus"` // 文件状态，FileStatusExpired 表示已失效
+}
+
+// FormProfileConfig 是表单的活动配置。
+type FormProfileConfig struct {
+	ActBeginTime string `json:"actBeginTime"` // 活动开始时间，"2006-01-02 15:04:05"
+	ActEndTime   string `json:"actEndTime"`   // 活动截止时间，过后不再接受提交
+	Limit        int    `json:"limit"`        // 总提交次数上限，-1 表示不限
+	PerLimit     int    `json:"perLimit"`     // 每人提交次数上限，-1 表示不限
+	AllowModify  bool   `json:"allowModify"`
 }
 
 // CatalogResponse 对应 catalog 接口响应。
@@ -32,9 +61,22 @@ type FormCatalog struct {
 	Cid           string         `json:"cid"`
 	Content       string         `json:"content"`
 	Role          string         `json:"role"`
+	UUID          string         `json:"uuid,omitempty"` // 场地选项的 UUID，时段下的占用情况通过它关联场地
+	Config        CatalogConfig  `json:"config"`
 	ChildCatalogs []ChildCatalog `json:"childCatalogs,omitempty"`
 }
 
+// CatalogConfig 是目录节点的配置（只解析用得到的项）。
+type CatalogConfig struct {
+	Limit *CatalogLimit `json:"LIMIT,omitempty"`
+}
+
+// CatalogLimit 是场地选项的限制：启用时每人每天在该场地最多预约 Attachment 场。
+type CatalogLimit struct {
+	Active     bool `json:"active"`
+	Attachment int  `json:"attachment"`
+}
+
 // ChildCatalog 表示时段节点及其元数据。
 type ChildCatalog struct {
 	Cid     string `json:"cid"`
@@ -42,6 +84,16 @@ type ChildCatalog struct {
 		StartTime int `json:"startTime"`
 		EndTime   int `json:"endTime"`
 	} `json:"content"`
+	ChildCatalogs []SlotOption `json:"childCatalogs,omitempty"`
+}
+
+// SlotOption 表示某个时段下单个场地的容量与已预约数。
+type SlotOption struct {
+	Content struct {
+		UUID      string `json:"uuid"`
+		Limit     int    `json:"limit"`
+		UsedCount int    `json:"usedCount"`
+	} `json:"content"`
 }
 
 // BookingResponse 对应提交预约后的响应。
@@ -86,14 +138,32 @@ type ReservationValue struct {
 // CatalogData 保存解析后的配置：版本号、场地选项，以及日期到时段的映射。
 type CatalogData struct {
 	FormVersion int                 // 表单版本号
+	Profile     *FormProfile        // 拉取目录时的表单信息
 	Options     []string            // 场地选项 ID 列表
+	Venues      []VenueInfo         // 场地详情，与 Options 一一对应
 	DateMap     map[string]DateInfo // 日期 -> 时段映射
 }
 
+// VenueInfo 表示一个场地选项。
+type VenueInfo struct {
+	Cid  string // 场地选项 ID
+	UUID string // 场地 UUID
+	Name string // 场地名称，如 "1号"
+	// Limit 是每人每天在该场地最多预约的场次（场地选项的 LIMIT），0 表示不限
+	Limit int
+}
+
 // DateInfo 表示某一天的时段映射。
 type DateInfo struct {
-	DateID  string         // 外部 API 的日期标识
-	TimeMap map[int]string // 小时 -> 时段 ID
+	DateID  string                       // 外部 API 的日期标识
+	TimeMap map[int]string               // 小时 -> 时段 ID
+	Usage   map[int]map[string]SlotUsage // 小时 -> 场地 UUID -> 占用情况
+}
+
+// SlotUsage 表示某个时段某个场地的容量与已预约数。
+type SlotUsage struct {
+	Limit     int
+	UsedCount int
 }
 
 // BookingSlot 表示一个预约时段的参数。
@@ -0,0 +1,155 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FieldError 是某个配置项的校验错误，Field 为 YAML 路径，如 user.phone。
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors 汇总一次校验发现的全部错误。
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return "配置校验失败: " + strings.Join(messages, "；")
+}
+
+// phonePattern 是中国大陆手机号格式。
+var phonePattern = regexp.MustCompile(`^1\d{10}$`)
+
+// validator 累积校验错误。
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) fail(field, format string, args ...any) {
+	v.errs = append(v.errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.fail(field, "未配置")
+	}
+}
+
+func (v *validator) nonNegative(field string, value int) {
+	if value < 0 {
+		v.fail(field, "不能为负数 (%d)", value)
+	}
+}
+
+func (v *validator) url(field, value string) {
+	if value != "" && !strings.HasPrefix(value, "https://") && !strings.HasPrefix(value, "http://") {
+		v.fail(field, "应为 http(s) 地址 (%q)", value)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// Validate 检查配置项的格式与取值，返回 ValidationErrors（无问题时为 nil）。
+// 预约所需的用户信息是否齐全由 ValidateForBooking 检查。
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	if c.User.Phone != "" && !phonePattern.MatchString(c.User.Phone) {
+		v.fail("user.phone", "应为 11 位手机号 (%q)", c.User.Phone)
+	}
+	v.url("user.image_url", c.User.ImageURL)
+	switch c.Database.DriverName() {
+	case DriverSQLite:
+		v.required("database.path", c.Database.Path)
+	case DriverPostgres:
+		v.required("database.dsn", c.Database.DSN)
+	default:
+		v.fail("database.driver", "应为 %s 或 %s (%q)", DriverSQLite, DriverPostgres, c.Database.Driver)
+	}
+
+	v.nonNegative("http.timeout_sec", c.HTTP.TimeoutSec)
+	v.nonNegative("http.dial_timeout_sec", c.HTTP.DialTimeoutSec)
+	v.nonNegative("http.tls_handshake_timeout_sec", c.HTTP.TLSHandshakeTimeoutSec)
+	v.nonNegative("http.idle_conn_timeout_sec", c.HTTP.IdleConnTimeoutSec)
+	v.nonNegative("http.max_idle_conns", c.HTTP.MaxIdleConns)
+
+	mw := c.Middleware
+	v.nonNegative("middleware.logging.max_body_size", mw.Logging.MaxBodySize)
+	v.nonNegative("middleware.retry.max_attempts", mw.Retry.MaxAttempts)
+	v.nonNegative("middleware.retry.base_delay_ms", mw.Retry.BaseDelayMs)
+	v.nonNegative("middleware.retry.max_delay_ms", mw.Retry.MaxDelayMs)
+	if mw.RateLimit.Enabled && mw.RateLimit.RPS <= 0 {
+		v.fail("middleware.rate_limit.rps", "启用限流时必须大于 0")
+	}
+	v.nonNegative("middleware.rate_limit.burst", mw.RateLimit.Burst)
+	v.nonNegative("middleware.circuit_breaker.failure_threshold", mw.CircuitBreaker.FailureThreshold)
+	v.nonNegative("middleware.circuit_breaker.cooldown_sec", mw.CircuitBreaker.CooldownSec)
+
+	if open := c.Analytics.OpenTime; open != "" {
+		_, err1 := time.Parse("15:04", open)
+		_, err2 := time.Parse("15:04:05", open)
+		if err1 != nil && err2 != nil {
+			v.fail("analytics.open_time", "应为 HH:MM 或 HH:MM:SS (%q)", open)
+		}
+	}
+	v.nonNegative("analytics.interval_ms", c.Analytics.IntervalMs)
+	v.nonNegative("analytics.window_sec", c.Analytics.WindowSec)
+	v.nonNegative("run.timeout_sec", c.Run.TimeoutSec)
+	v.nonNegative("run.image_warn_days", c.Run.ImageWarnDays)
+	v.nonNegative("run.lease_sec", c.Run.LeaseSec)
+	v.nonNegative("run.concurrency.global", c.Run.Concurrency.Global)
+	v.nonNegative("run.concurrency.per_account", c.Run.Concurrency.PerAccount)
+	v.nonNegative("run.concurrency.per_venue", c.Run.Concurrency.PerVenue)
+
+	if c.DefaultForm != "" && len(c.Forms) > 0 {
+		if _, ok := c.Forms[c.DefaultForm]; !ok {
+			v.fail("default_form", "%q 不在 forms 中", c.DefaultForm)
+		}
+	}
+	for _, form := range c.AllForms() {
+		prefix := "forms." + form.Name
+		v.url(prefix+".base_url", form.BaseURL)
+		v.required(prefix+".form_id", form.FormID)
+		v.required(prefix+".fields.name", form.Fields.Name)
+		v.required(prefix+".fields.phone", form.Fields.Phone)
+		v.required(prefix+".fields.student_id", form.Fields.StudentID)
+		v.required(prefix+".fields.image", form.Fields.Image)
+		v.required(prefix+".fields.reservation", form.Fields.Reservation)
+		v.nonNegative(prefix+".max_per_day", form.MaxPerDay)
+	}
+	for name, form := range c.Forms {
+		if form == nil {
+			v.fail("forms."+name, "为空")
+		}
+	}
+	return v.err()
+}
+
+// ValidateForBooking 检查提交预约所需的用户信息与 token 是否齐全。
+func (c *Config) ValidateForBooking() error {
+	v := &validator{}
+	v.required("user.student_id", c.User.StudentID)
+	v.required("user.name", c.User.Name)
+	v.required("user.phone", c.User.Phone)
+	v.required("user.image_url", c.User.ImageURL)
+	if c.User.Token == "" {
+		v.fail("user.token", "未配置（可使用 token、token_file、token_env 或环境变量 %sUSER_TOKEN）", EnvPrefix)
+	}
+	return v.err()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sports_order/common"
+	"sports_order/credentials"
+)
+
+// writeConfig 在临时目录写入配置文件并返回路径。
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigRejectsUnknownKey(t *testing.T) {
+	path := writeConfig(t, "user:\n  phnoe: \"13800138000\"\ndatabase:\n  path: a.db\n")
+	_, err := LoadConfigFrom(path)
+	if err == nil || !strings.Contains(err.Error(), "phnoe") {
+		t.Fatalf("err = %v, want unknown field phnoe", err)
+	}
+}
+
+func TestLoadConfigFieldErrors(t *testing.T) {
+	path := writeConfig(t, "user:\n  phone: \"12345\"\n  image_url: \"ftp://x\"\nhttp:\n  timeout_sec: -1\n")
+	_, err := LoadConfigFrom(path)
+	var fieldErrs common.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		t.Fatalf("err = %v, want ValidationErrors", err)
+	}
+	got := make(map[string]bool)
+	for _, fieldErr := range fieldErrs {
+		got[fieldErr.Field] = true
+	}
+	for _, field := range []string{"user.phone", "user.image_url", "database.path", "http.timeout_sec"} {
+		if !got[field] {
+			t.Errorf("missing error for %s in %v", field, err)
+		}
+	}
+}
+
+func TestLoadConfigDatabaseDriver(t *testing.T) {
+	path := writeConfig(t, "database:\n  driver: postgres\n  path: a.db\n")
+	_, err := LoadConfigFrom(path)
+	if err == nil || !strings.Contains(err.Error(), "database.dsn") {
+		t.Fatalf("err = %v, want database.dsn required", err)
+	}
+
+	path = writeConfig(t, "database:\n  driver: mysql\n")
+	if _, err := LoadConfigFrom(path); err == nil || !strings.Contains(err.Error(), "database.driver") {
+		t.Fatalf("err = %v, want unsupported driver", err)
+	}
+
+	t.Setenv("SPORTS_ORDER_DATABASE_DSN", "postgres://order:secret@db:5432/sports?sslmode=disable")
+	path = writeConfig(t, "database:\n  driver: postgres\n")
+	config, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Database.Target(); strings.Contains(got, "secret") || !strings.Contains(got, "order:***@db:5432") {
+		t.Errorf("Target() = %q, want password hidden", got)
+	}
+}
+
+func TestLoadConfigTokenSources(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "token.txt"), []byte("file-token\n"), 0600)
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte("user:\n  token_file: token.txt\ndatabase:\n  path: a.db\n"), 0600)
+
+	config, err := LoadConfigFrom(path)
+	if err != nil || config.User.Token != "file-token" {
+		t.Fatalf("token_file: token = %q, err = %v", config.User.Token, err)
+	}
+
+	t.Setenv("MY_TOKEN", "env-token")
+	config, err = LoadConfigFrom(writeConfig(t, "user:\n  token_env: MY_TOKEN\ndatabase:\n  path: a.db\n"))
+	if err != nil || config.User.Token != "env-token" {
+		t.Fatalf("token_env: token = %q, err = %v", config.User.Token, err)
+	}
+
+	if _, err := LoadConfigFrom(writeConfig(t, "user:\n  token: a\n  token_env: MY_TOKEN\ndatabase:\n  path: a.db\n")); err == nil {
+		t.Fatal("token and token_env together should be rejected")
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	t.Setenv("SPORTS_ORDER_USER_TOKEN", "override")
+	t.Setenv("SPORTS_ORDER_DATABASE_PATH", "other.db")
+	t.Setenv("SPORTS_ORDER_HTTP_TIMEOUT_SEC", "7")
+	t.Setenv("SPORTS_ORDER_MIDDLEWARE_RETRY_ENABLED", "true")
+
+	config, err := LoadConfigFrom(writeConfig(t, "user:\n  token_file: missing.txt\ndatabase:\n  path: a.db\n"))
+	if err != nil {
+		t.Fatalf("LoadConfigFrom: %v", err)
+	}
+	if config.User.Token != "override" || config.Database.Path != "other.db" ||
+		config.HTTP.TimeoutSec != 7 || !config.Middleware.Retry.Enabled {
+		t.Fatalf("overrides not applied: %+v", config)
+	}
+
+	t.Setenv("SPORTS_ORDER_HTTP_TIMEOUT_SEC", "abc")
+	if _, err := LoadConfigFrom(writeConfig(t, "database:\n  path: a.db\n")); err == nil {
+		t.Fatal("invalid integer override should be rejected")
+	}
+}
+
+func TestLoadConfigFromCredentialStore(t *testing.T) {
+	dir := t.TempDir()
+	if err := credentials.Save(filepath.Join(dir, "creds.enc"), []byte("pw"), &credentials.Credentials{Phone: "13800138000", Token: "store-token"}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte("user:\n  name: 张三\ndatabase:\n  path: a.db\ncredentials:\n  path: creds.enc\n"), 0600)
+
+	if _, err := LoadConfigFrom(path); err == nil {
+		t.Fatal("missing passphrase should be rejected")
+	}
+
+	t.Setenv(common.DefaultPassphraseEnv, "pw")
+	config, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatalf("LoadConfigFrom: %v", err)
+	}
+	if config.User.Token != "store-token" || config.User.Phone != "13800138000" || config.User.Name != "张三" {
+		t.Fatalf("user = %+v", config.User)
+	}
+
+	// 环境变量覆盖优先于凭据存储
+	t.Setenv("SPORTS_ORDER_USER_TOKEN", "env-token")
+	if config, err = LoadConfigFrom(path); err != nil || config.User.Token != "env-token" {
+		t.Fatalf("token = %q, err = %v; want env-token", config.User.Token, err)
+	}
+}
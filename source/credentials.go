@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sports_order/common"
+	"sports_order/credentials"
+)
+
+// newPassphraseEnv 是 credentials rotate 读取新口令的环境变量。
+const newPassphraseEnv = "SPORTS_ORDER_NEW_PASSPHRASE"
+
+// credentialsCommand 分发 credentials 的子命令：set 写入、show 脱敏展示、rotate 更换口令、gen-key 生成密钥文件。
+func credentialsCommand(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: sports-order credentials <set|show|rotate|gen-key> [参数]")
+		return 2
+	}
+	switch args[0] {
+	case "set":
+		return credentialsSet(args[1:])
+	case "show":
+		return credentialsShow(args[1:])
+	case "rotate":
+		return credentialsRotate(args[1:])
+	case "gen-key":
+		return credentialsGenKey(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知的 credentials 子命令: %s\n", args[0])
+		return 2
+	}
+}
+
+// credentialStore 是配置中指定的凭据存储位置与密钥。
+type credentialStore struct {
+	path   string
+	secret []byte
+}
+
+// openCredentialStore 读取配置中的 credentials 设置（不解密存储本身）。
+func openCredentialStore(configPath string) (*common.Config, *credentialStore, error) {
+	config, err := parseConfig(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.Credentials.Path == "" {
+		return nil, nil, errors.New("config.yaml 中未配置 credentials.path")
+	}
+	baseDir := filepath.Dir(configPath)
+	secret, err := credentialSecret(config.Credentials, baseDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, &credentialStore{path: resolvePath(baseDir, config.Credentials.Path), secret: secret}, nil
+}
+
+// credentialsSet 写入或更新凭据；存储不存在时新建。
+func credentialsSet(args []string) int {
+	fs, configPath := newFlagSet("credentials set")
+	studentID := fs.String("student-id", "", "学号")
+	name := fs.String("name", "", "姓名")
+	phone := fs.String("phone", "", "手机号")
+	imageURL := fs.String("image-url", "", "图片地址")
+	token := fs.String("token", "", "认证令牌（会留在 shell 历史中，建议使用 -token-stdin）")
+	tokenStdin := fs.Bool("token-stdin", false, "从标准输入读取 token")
+	fromConfig := fs.Bool("from-config", false, "导入 config.yaml 中当前的明文用户信息")
+	fs.Parse(args)
+
+	config, store, err := openCredentialStore(*configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+
+	creds, err := credentials.Load(store.path, store.secret)
+	if errors.Is(err, os.ErrNotExist) {
+		creds, err = &credentials.Credentials{}, nil
+	}
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+
+	if *fromConfig {
+		creds.Merge(config.User)
+	}
+	if *tokenStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Printf("读取 token 失败: %v", err)
+			return 1
+		}
+		*token = strings.TrimSpace(line)
+	}
+	creds.Merge(common.User{StudentID: *studentID, Name: *name, Phone: *phone, ImageURL: *imageURL, Token: *token})
+
+	if err := credentials.Save(store.path, store.secret, creds); err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "凭据已保存到 %s\n", store.path)
+	printCredentials(creds)
+	if *fromConfig {
+		fmt.Fprintln(os.Stderr, "请从 config.yaml 中删除 user 下的明文信息")
+	}
+	return 0
+}
+
+// credentialsShow 脱敏展示凭据。
+func credentialsShow(args []string) int {
+	fs, configPath := newFlagSet("credentials show")
+	fs.Parse(args)
+
+	_, store, err := openCredentialStore(*configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	creds, err := credentials.Load(store.path, store.secret)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	printCredentials(creds)
+	return 0
+}
+
+// credentialsRotate 用新口令（环境变量 SPORTS_ORDER_NEW_PASSPHRASE）或新密钥文件重新加密存储。
+func credentialsRotate(args []string) int {
+	fs, configPath := newFlagSet("credentials rotate")
+	newKeyFile := fs.String("new-key-file", "", "改用该密钥文件加密；不指定时从环境变量 "+newPassphraseEnv+" 读取新口令")
+	fs.Parse(args)
+
+	_, store, err := openCredentialStore(*configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	creds, err := credentials.Load(store.path, store.secret)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+
+	var secret []byte
+	if *newKeyFile != "" {
+		data, err := os.ReadFile(*newKeyFile)
+		if err != nil {
+			log.Printf("读取新密钥文件失败: %v", err)
+			return 1
+		}
+		secret = []byte(strings.TrimSpace(string(data)))
+	} else {
+		secret = []byte(os.Getenv(newPassphraseEnv))
+	}
+	if len(secret) == 0 {
+		log.Printf("请通过 -new-key-file 或环境变量 %s 提供新的密钥", newPassphraseEnv)
+		return 1
+	}
+
+	if err := credentials.Save(store.path, secret, creds); err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	if *newKeyFile != "" {
+		fmt.Fprintf(os.Stderr, "已重新加密 %s，请将 credentials.key_file 改为 %s\n", store.path, *newKeyFile)
+	} else {
+		fmt.Fprintf(os.Stderr, "已重新加密 %s，请将口令环境变量更新为新口令\n", store.path)
+	}
+	return 0
+}
+
+// credentialsGenKey 生成随机密钥文件（不覆盖已有文件）。
+func credentialsGenKey(args []string) int {
+	fs, _ := newFlagSet("credentials gen-key")
+	out := fs.String("out", "credentials.key", "密钥文件路径")
+	fs.Parse(args)
+
+	key, err := credentials.GenerateKey()
+	if err != nil {
+		log.Printf("生成密钥失败: %v", err)
+		return 1
+	}
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		log.Printf("创建密钥文件失败: %v", err)
+		return 1
+	}
+	defer f.Close()
+	if _, err := f.WriteString(key); err != nil {
+		log.Printf("写入密钥文件失败: %v", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "密钥已写入 %s，请妥善保管且不要提交到版本库\n", *out)
+	return 0
+}
+
+// printCredentials 输出脱敏后的凭据。
+func printCredentials(creds *credentials.Credentials) {
+	redacted := creds.Redacted()
+	fmt.Printf("student_id: %s\n", redacted.StudentID)
+	fmt.Printf("name:       %s\n", redacted.Name)
+	fmt.Printf("phone:      %s\n", redacted.Phone)
+	fmt.Printf("image_url:  %s\n", redacted.ImageURL)
+	fmt.Printf("token:      %s\n", redacted.Token)
+	if !creds.UpdatedAt.IsZero() {
+		fmt.Printf("updated_at: %s\n", creds.UpdatedAt.Format("2006-01-02 15:04:05"))
+	}
+}
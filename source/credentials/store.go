@@ -0,0 +1,189 @@
+// Package credentials 提供加密的本地凭据存储：token、学号、姓名、手机号等个人信息
+// 使用 AES-256-GCM 加密保存，密钥由口令或密钥文件经 PBKDF2-SHA256 派生，
+// 这样 config.yaml 可以纳入版本管理而不泄露任何人的会话。
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sports_order/common"
+)
+
+// 存储文件格式与密钥派生参数。
+const (
+	formatVersion = 1
+	kdfName       = "pbkdf2-sha256"
+	kdfIterations = 600000
+	saltSize      = 16
+	keySize       = 32 // AES-256
+)
+
+// ErrDecrypt 表示口令或密钥文件不正确（或文件被篡改），无法解密。
+var ErrDecrypt = errors.New("无法解密凭据存储：口令或密钥文件不正确")
+
+// Credentials 是加密保存的用户凭据，字段与 common.User 对应。
+type Credentials struct {
+	StudentID string    `json:"student_id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Phone     string    `json:"phone,omitempty"`
+	ImageURL  string    `json:"image_url,omitempty"`
+	Token     string    `json:"token,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ApplyTo 用凭据中非空的字段覆盖 user。
+func (c *Credentials) ApplyTo(user *common.User) {
+	for _, field := range []struct {
+		src string
+		dst *string
+	}{
+		{c.StudentID, &user.StudentID},
+		{c.Name, &user.Name},
+		{c.Phone, &user.Phone},
+		{c.ImageURL, &user.ImageURL},
+		{c.Token, &user.Token},
+	} {
+		if field.src != "" {
+			*field.dst = field.src
+		}
+	}
+}
+
+// Merge 用 user 中非空的字段更新凭据。
+func (c *Credentials) Merge(user common.User) {
+	for _, field := range []struct {
+		src string
+		dst *string
+	}{
+		{user.StudentID, &c.StudentID},
+		{user.Name, &c.Name},
+		{user.Phone, &c.Phone},
+		{user.ImageURL, &c.ImageURL},
+		{user.Token, &c.Token},
+	} {
+		if field.src != "" {
+			*field.dst = field.src
+		}
+	}
+}
+
+// Redacted 返回脱敏后的凭据，便于展示。
+func (c *Credentials) Redacted() Credentials {
+	return Credentials{
+		StudentID: common.MaskSecret(c.StudentID),
+		Name:      common.MaskSecret(c.Name),
+		Phone:     common.MaskSecret(c.Phone),
+		ImageURL:  c.ImageURL,
+		Token:     common.MaskSecret(c.Token),
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+// file 是存储文件的 JSON 结构，[]byte 字段按 base64 编码。
+type file struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Load 使用口令或密钥文件内容 secret 解密 path 处的凭据。
+func Load(path string, secret []byte) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取凭据存储失败: %w", err)
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("解析凭据存储失败: %w", err)
+	}
+	if f.Version != formatVersion || f.KDF != kdfName {
+		return nil, fmt.Errorf("不支持的凭据存储格式: version %d, kdf %s", f.Version, f.KDF)
+	}
+
+	gcm, err := newGCM(secret, f.Salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, fmt.Errorf("解析凭据失败: %w", err)
+	}
+	return &creds, nil
+}
+
+// Save 使用 secret 加密并写入凭据。每次保存都生成新的盐与随机数，
+// 因此更换口令（rotate）只需用新口令重新保存。
+func Save(path string, secret []byte, creds *Credentials) error {
+	if len(secret) == 0 {
+		return errors.New("口令或密钥为空")
+	}
+	creds.UpdatedAt = time.Now()
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("序列化凭据失败: %w", err)
+	}
+
+	f := file{Version: formatVersion, KDF: kdfName, Iterations: kdfIterations, Salt: make([]byte, saltSize)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(secret, f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Ciphertext = gcm.Seal(nil, f.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("创建凭据目录失败: %w", err)
+	}
+	// 先写临时文件再重命名，避免中途崩溃留下损坏的存储
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("写入凭据存储失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// GenerateKey 生成一个随机密钥（十六进制文本），可写入密钥文件代替口令。
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x\n", key), nil
+}
+
+func newGCM(secret, salt []byte, iterations int) (cipher.AEAD, error) {
+	if iterations <= 0 || len(salt) == 0 {
+		return nil, errors.New("凭据存储缺少密钥派生参数")
+	}
+	block, err := aes.NewCipher(pbkdf2SHA256(secret, salt, iterations, keySize))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,60 @@
+package credentials
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sports_order/common"
+)
+
+// RFC 7914 第 11 节给出的 PBKDF2-HMAC-SHA256 测试向量。
+func TestPBKDF2SHA256(t *testing.T) {
+	got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64))
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got != want {
+		t.Fatalf("pbkdf2 = %s, want %s", got, want)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds.enc")
+	creds := &Credentials{StudentID: "20231234567", Name: "张三", Phone: "13800138000", Token: "secret-token-value"}
+	if err := Save(path, []byte("pw"), creds); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, plain := range []string{"secret-token-value", "13800138000", "20231234567"} {
+		if strings.Contains(string(data), plain) {
+			t.Fatalf("store contains plaintext %q", plain)
+		}
+	}
+
+	loaded, err := Load(path, []byte("pw"))
+	if err != nil || loaded.Token != creds.Token || loaded.Name != "张三" {
+		t.Fatalf("Load = %+v, %v", loaded, err)
+	}
+	if _, err := Load(path, []byte("wrong")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("Load with wrong passphrase err = %v, want ErrDecrypt", err)
+	}
+
+	// 更换口令：用新口令重新保存后旧口令失效
+	if err := Save(path, []byte("pw2"), loaded); err != nil {
+		t.Fatalf("Save with new passphrase: %v", err)
+	}
+	if _, err := Load(path, []byte("pw")); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("old passphrase still works after rotation: %v", err)
+	}
+}
+
+func TestApplyToKeepsUnsetFields(t *testing.T) {
+	user := &common.User{Name: "config", ImageURL: "https://x/a.jpg", Token: "old"}
+	(&Credentials{Name: "store", Token: "new"}).ApplyTo(user)
+	if user.Name != "store" || user.Token != "new" || user.ImageURL != "https://x/a.jpg" {
+		t.Fatalf("user = %+v", user)
+	}
+}
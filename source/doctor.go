@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sports_order/common"
+	"sports_order/migrations"
+	"sports_order/service"
+
+	"gorm.io/gorm"
+)
+
+// checkLevel 是一项检查的结果等级。
+type checkLevel string
+
+const (
+	checkOK   checkLevel = "OK"
+	checkWarn checkLevel = "WARN"
+	checkFail checkLevel = "FAIL" // 阻断性问题，开抢前必须处理
+)
+
+// checkResult 是一项检查的结果。
+type checkResult struct {
+	Name   string
+	Level  checkLevel
+	Detail string
+}
+
+// doctor 收集各项检查结果。
+type doctor struct {
+	results []checkResult
+}
+
+func (d *doctor) add(name string, level checkLevel, format string, args ...any) {
+	d.results = append(d.results, checkResult{Name: name, Level: level, Detail: fmt.Sprintf(format, args...)})
+}
+
+// worst 返回所有结果中最严重的等级。
+func (d *doctor) worst() checkLevel {
+	level := checkOK
+	for _, result := range d.results {
+		switch {
+		case result.Level == checkFail:
+			return checkFail
+		case result.Level == checkWarn:
+			level = checkWarn
+		}
+	}
+	return level
+}
+
+// doctorCommand 在开抢前检查配置、数据库、网络、时钟、token、图片与待处理订单。
+// 有阻断性问题时退出码为 1，只有告警时为 3。
+func doctorCommand(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("doctor")
+	probeToken := fs.Bool("probe-token", false, "携带 token 查询本人的提交记录以验证 token（只读，不会预约）")
+	fs.Parse(args)
+
+	d := &doctor{}
+	defer d.print()
+
+	// 配置
+	config, err := readConfig(*configPath)
+	if err != nil {
+		d.add("配置", checkFail, "%v", err)
+		return 1
+	}
+	configOK := true
+	for _, err := range []error{config.Validate(), config.ValidateForBooking()} {
+		var fieldErrs common.ValidationErrors
+		if errors.As(err, &fieldErrs) {
+			for _, fieldErr := range fieldErrs {
+				d.add("配置 "+fieldErr.Field, checkFail, "%s", fieldErr.Message)
+			}
+			configOK = false
+		}
+	}
+	if configOK {
+		d.add("配置", checkOK, "%s", *configPath)
+	}
+
+	// 数据库与表结构
+	var repo *Repository
+	if _, err := os.Stat(config.Database.Path); config.Database.DriverName() == common.DriverSQLite && err != nil {
+		d.add("数据库", checkFail, "%v（请先执行 sports-order migrate up 或 make init-db）", err)
+	} else if db, err := InitDB(config); err != nil {
+		d.add("数据库", checkFail, "%v", err)
+	} else {
+		defer CloseDB(db)
+		d.checkMigrations(ctx, db)
+		if problems, err := CheckSchema(db); err != nil {
+			d.add("数据库", checkFail, "%v", err)
+		} else if len(problems) > 0 {
+			d.add("数据库", checkFail, "表结构与模型不一致: %s", strings.Join(problems, "；"))
+		} else {
+			d.add("数据库", checkOK, "%s %s", config.Database.DriverName(), config.Database.Target())
+			repo = NewRepository(db)
+			if holder, err := repo.processLockHolder(ctx, common.RunLockName); err == nil && holder != nil {
+				d.add("实例锁", checkWarn, "%v", &lockHeldError{holder: holder})
+			}
+		}
+	}
+
+	// 网络连通性、往返耗时与时钟偏差
+	httpClient := NewHTTPClient(config.HTTP)
+	seen := make(map[string]bool)
+	for _, form := range config.AllForms() {
+		if seen[form.BaseURL] {
+			continue
+		}
+		seen[form.BaseURL] = true
+		d.checkNetwork(ctx, httpClient, form.BaseURL)
+	}
+
+	// 表单状态、图片、token 与待处理订单
+	catalogs := make(map[string]*common.CatalogData)
+	for _, form := range config.AllForms() {
+		booking := service.NewBookingService(httpClient, nil, &config.User, form)
+		data, err := booking.GetCatalogData(ctx)
+		if err != nil {
+			d.add("表单 "+form.Name, checkFail, "获取目录失败: %v", err)
+			continue
+		}
+		catalogs[form.Name] = data
+		d.checkForm(config, form, data)
+		if *probeToken {
+			d.checkToken(ctx, httpClient, &config.User, form)
+		}
+	}
+	if !*probeToken && config.User.Token != "" {
+		d.add("token", checkWarn, "未验证（使用 -probe-token 验证）")
+	}
+
+	if repo != nil {
+		d.checkOrders(ctx, repo, config, catalogs)
+	}
+
+	switch d.worst() {
+	case checkFail:
+		return 1
+	case checkWarn:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// checkMigrations 检查内嵌迁移是否都已执行；未执行的迁移会在下次运行时自动执行，只告警。
+func (d *doctor) checkMigrations(ctx context.Context, db *gorm.DB) {
+	pending, err := migrations.Pending(ctx, db)
+	switch {
+	case err != nil:
+		d.add("迁移", checkFail, "%v", err)
+	case len(pending) > 0:
+		ids := make([]string, len(pending))
+		for i, m := range pending {
+			ids[i] = m.ID()
+		}
+		d.add("迁移", checkWarn, "%d 个迁移未执行（运行时自动执行）: %s", len(pending), strings.Join(ids, ", "))
+	default:
+		d.add("迁移", checkOK, "已是最新")
+	}
+}
+
+// checkNetwork 检查表单域名可达，并测量往返耗时与本机时钟偏差。
+func (d *doctor) checkNetwork(ctx context.Context, client *HTTPClient, baseURL string) {
+	rtt, skew, err := client.Probe(ctx, baseURL)
+	if err != nil {
+		d.add("网络 "+baseURL, checkFail, "不可达: %v", err)
+		return
+	}
+	d.add("网络 "+baseURL, checkOK, "往返 %v", rtt.Round(time.Millisecond))
+
+	level := checkOK
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs >= common.ClockSkewFail:
+		level = checkFail
+	case abs >= common.ClockSkewWarn:
+		level = checkWarn
+	}
+	d.add("时钟 "+baseURL, level, "本机比服务端快 %v（精度约 1s）", skew.Round(time.Millisecond))
+}
+
+// checkForm 检查表单是否仍可预约、配置的图片是否有效。
+func (d *doctor) checkForm(config *common.Config, form *common.FormConfig, data *common.CatalogData) {
+	name := "表单 " + form.Name
+	if err := data.Profile.CheckAvailable(time.Now()); err != nil {
+		d.add(name, checkFail, "%v", err)
+	} else {
+		d.add(name, checkOK, "「%s」版本 %d，%d 个场地，%d 个日期", data.Profile.Title, data.FormVersion, len(data.Venues), len(data.DateMap))
+	}
+
+	warnBefore := time.Duration(intOr(config.Run.ImageWarnDays, common.DefaultImageWarnDays)) * 24 * time.Hour
+	warning, err := data.Profile.CheckImage(config.User.ImageURL, time.Now(), warnBefore)
+	switch {
+	case err != nil:
+		d.add("图片 "+form.Name, checkFail, "%v", err)
+	case warning != "":
+		d.add("图片 "+form.Name, checkWarn, "%s", warning)
+	default:
+		status := data.Profile.ImageStatus(config.User.ImageURL, time.Now())
+		d.add("图片 "+form.Name, checkOK, "有效期至 %s", status.ExpireAt.Format(common.ProfileTimeLayout))
+	}
+}
+
+// authorizedGetter 是 checkToken 使用的只读请求，由 HTTPClient 实现。
+type authorizedGetter interface {
+	GetAuthorized(ctx context.Context, url, auth string, headers map[string]string) ([]byte, error)
+}
+
+// checkToken 携带 token 以 GET 查询本人在表单中的提交记录：只读，不会创建预约。
+// 401 说明 token 失效，请求成功说明 token 有效，其他错误无法判断。
+func (d *doctor) checkToken(ctx context.Context, client authorizedGetter, user *common.User, form *common.FormConfig) {
+	name := "token " + form.Name
+	_, err := client.GetAuthorized(ctx, form.FormDataURL(), user.Token, form.Headers())
+	if err == nil {
+		d.add(name, checkOK, "有效")
+		return
+	}
+	if httpErr, ok := common.AsHTTPError(err); ok && httpErr.StatusCode == http.StatusUnauthorized {
+		d.add(name, checkFail, "已失效，请重新抓包获取: %v", err)
+		return
+	}
+	d.add(name, checkWarn, "无法判断: %v", err)
+}
+
+// checkOrders 检查待处理订单能否在当前目录中找到对应的日期、时段与场地。
+// 已过期的订单只告警；日期尚未进入目录的订单跳过。
+func (d *doctor) checkOrders(ctx context.Context, repo *Repository, config *common.Config, catalogs map[string]*common.CatalogData) {
+	orders, err := repo.FindPendingOrders(ctx)
+	if err != nil {
+		d.add("订单", checkFail, "查询待处理订单失败: %v", err)
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+	checked, future := 0, 0
+	for _, order := range orders {
+		name := fmt.Sprintf("订单 %d", order.ID)
+		if order.Date < today {
+			d.add(name, checkWarn, "%s %d:00 已过期，仍为 %s（下次 run 或 orders expire 时置为 EXPIRED）", order.Date, order.Hour, order.Status)
+			continue
+		}
+
+		form, err := config.Form(order.Form)
+		if err != nil {
+			d.add(name, checkFail, "%v", err)
+			continue
+		}
+		data, ok := catalogs[form.Name]
+		if !ok {
+			continue // 目录获取失败已单独报告
+		}
+		if _, ok := data.DateMap[order.Date]; !ok && order.Date > latestDate(data) {
+			future++
+			continue
+		}
+
+		checked++
+		if err := service.CheckSlot(data, common.BookingSlot{Date: order.Date, Hour: order.Hour, Venue: order.Venue}); err != nil {
+			d.add(name, checkFail, "[%s] %v", form.Name, err)
+		}
+	}
+	d.add("订单", checkOK, "%d 个待处理订单，已核对 %d 个，%d 个日期尚未开放", len(orders), checked, future)
+
+	unknown, err := repo.ListOrders(ctx, OrderFilter{Status: common.OrderStatusUnknown})
+	if err != nil {
+		d.add("订单", checkFail, "查询结果未知的订单失败: %v", err)
+		return
+	}
+	for _, order := range unknown {
+		d.add(fmt.Sprintf("订单 %d", order.ID), checkWarn, "%s %d:00 场地 %d 提交结果未知（%s），不会自动提交，请核对后执行 orders resolve",
+			order.Date, order.Hour, order.Venue, order.LastError)
+	}
+}
+
+// latestDate 返回目录中最晚的日期。
+func latestDate(data *common.CatalogData) string {
+	latest := ""
+	for date := range data.DateMap {
+		if date > latest {
+			latest = date
+		}
+	}
+	return latest
+}
+
+// print 以表格输出检查结果。
+func (d *doctor) print() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, result := range d.results {
+		fmt.Fprintf(w, "[%s]\t%s\t%s\n", result.Level, result.Name, result.Detail)
+	}
+	w.Flush()
+	if d.worst() == checkFail {
+		log.Printf("存在阻断性问题，请在开抢前处理")
+	}
+}
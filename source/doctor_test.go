@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sports_order/common"
+)
+
+// result 返回名为 name 的检查结果。
+func (d *doctor) result(t *testing.T, name string) checkResult {
+	t.Helper()
+	for _, result := range d.results {
+		if result.Name == name {
+			return result
+		}
+	}
+	t.Fatalf("no result named %q in %+v", name, d.results)
+	return checkResult{}
+}
+
+// TestCheckToken token 只以 GET 验证，不会提交预约：401 为失效，成功为有效，其他错误无法判断。
+func TestCheckToken(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch r.Header.Get("Authorization") {
+		case "valid":
+			w.Write([]byte(`{"code":0,"data":[]}`))
+		case "expired":
+			http.Error(w, `{"code":401,"message":"token expired"}`, http.StatusUnauthorized)
+		default:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	form := common.DefaultFormConfig()
+	form.BaseURL = server.URL
+	client := NewHTTPClient(common.HTTPConfig{})
+	for _, tt := range []struct {
+		token string
+		want  checkLevel
+	}{
+		{"valid", checkOK},
+		{"expired", checkFail},
+		{"other", checkWarn},
+	} {
+		d := &doctor{}
+		d.checkToken(context.Background(), client, &common.User{Token: tt.token}, form)
+		if got := d.result(t, "token "+form.Name); got.Level != tt.want {
+			t.Errorf("token %s: %s %s, want %s", tt.token, got.Level, got.Detail, tt.want)
+		}
+	}
+
+	want := "GET /v1/" + form.FormID + "/form_data"
+	for _, request := range requests {
+		if request != want {
+			t.Errorf("request = %s, want only %s", request, want)
+		}
+	}
+	if len(requests) != 3 {
+		t.Errorf("requests = %v, want 3", requests)
+	}
+}
+
+// TestCheckOrders 已过期与提交结果未知（UNKNOWN）的订单告警，其余订单计入汇总。
+func TestCheckOrders(t *testing.T) {
+	ctx := context.Background()
+	a := newTestApp(t, "run-1")
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	orders := []*common.Order{
+		{Date: tomorrow, Hour: 20, Venue: 1, Status: string(common.OrderStatusPending)},
+		{Date: "2000-01-01", Hour: 20, Venue: 1, Status: string(common.OrderStatusPending)},
+		{Date: tomorrow, Hour: 21, Venue: 1, Status: string(common.OrderStatusUnknown), LastError: "提交被中断"},
+	}
+	if err := a.db.Create(orders).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	d := &doctor{}
+	d.checkOrders(ctx, a.repo, &common.Config{}, map[string]*common.CatalogData{})
+	if got := d.result(t, "订单"); got.Level != checkOK || !strings.HasPrefix(got.Detail, "2 个待处理订单") {
+		t.Errorf("summary = %s %s, want OK with 2 pending orders", got.Level, got.Detail)
+	}
+	if got := d.result(t, "订单 2"); got.Level != checkWarn || !strings.Contains(got.Detail, "已过期") {
+		t.Errorf("stale order = %s %s, want expiry warning", got.Level, got.Detail)
+	}
+	if got := d.result(t, "订单 3"); got.Level != checkWarn || !strings.Contains(got.Detail, "orders resolve") {
+		t.Errorf("unknown order = %s %s, want resolve warning", got.Level, got.Detail)
+	}
+	if d.worst() != checkWarn {
+		t.Errorf("worst = %s, want WARN", d.worst())
+	}
+}
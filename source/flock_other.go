@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// fileLockSupported 为 false 时只依赖数据库中的锁行，同主机的失效锁需等心跳超时后才能接管。
+const fileLockSupported = false
+
+// errFileLocked 表示锁文件已被其他进程持有（本平台不会返回）。
+var errFileLocked = errors.New("锁文件已被其他进程持有")
+
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
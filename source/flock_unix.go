@@ -0,0 +1,29 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// fileLockSupported 表示本平台的锁文件能可靠反映持有进程是否存活（进程退出时内核自动释放）。
+const fileLockSupported = true
+
+// errFileLocked 表示锁文件已被其他进程持有。
+var errFileLocked = errors.New("锁文件已被其他进程持有")
+
+// lockFile 以非阻塞方式对文件加排他锁。
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errFileLocked
+	}
+	return err
+}
+
+// unlockFile 释放文件锁。
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
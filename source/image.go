@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"sports_order/common"
+	"sports_order/service"
+)
+
+// imageCommand 核对 image_url 在各表单 fileLifeCycle 中的有效期。
+// 图片已过期时退出码为 1，即将过期或无法核对时为 3。
+func imageCommand(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("image")
+	formName := fs.String("form", "", "只核对指定表单，默认核对全部表单")
+	fs.Parse(args)
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	forms := app.config.AllForms()
+	if *formName != "" {
+		form, err := app.config.Form(*formName)
+		if err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+		forms = []*common.FormConfig{form}
+	}
+
+	apiClient, _, closeClient, err := buildAPIClient(ctx, app.config, app.repo, app.runID, "")
+	if err != nil {
+		log.Printf("初始化 API 客户端失败: %v", err)
+		return 1
+	}
+	defer closeClient()
+
+	warnDays := intOr(app.config.Run.ImageWarnDays, common.DefaultImageWarnDays)
+	expired, warned := false, false
+	for _, form := range forms {
+		booking := service.NewBookingService(apiClient, app.repo, &app.config.User, form)
+		profile, err := booking.GetProfile(ctx)
+		if err != nil {
+			log.Printf("获取表单 %s 信息失败: %v", form.Name, err)
+			return 1
+		}
+
+		warning, err := profile.CheckImage(app.config.User.ImageURL, time.Now(), time.Duration(warnDays)*24*time.Hour)
+		switch {
+		case err != nil:
+			expired = true
+			fmt.Printf("[%s] 错误: %v\n", form.Name, err)
+			app.repo.CreateLogf(ctx, common.LogLevelError, nil, "表单 %s: %v", form.Name, err)
+		case warning != "":
+			warned = true
+			fmt.Printf("[%s] 告警: %s\n", form.Name, warning)
+			app.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "表单 %s: %s", form.Name, warning)
+		default:
+			status := profile.ImageStatus(app.config.User.ImageURL, time.Now())
+			fmt.Printf("[%s] 正常: %s 有效期至 %s\n", form.Name, status.Key, status.ExpireAt.Format(common.ProfileTimeLayout))
+		}
+	}
+
+	switch {
+	case expired:
+		fmt.Fprintln(os.Stderr, "图片已过期，请重新上传并更新 config.yaml 中的 image_url")
+		return 1
+	case warned:
+		return 3
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sports_order/capture"
+	"sports_order/common"
+	"sports_order/credentials"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tokenLifetime 是抓包得到的 token 的大致有效期，超过后提示重新抓包。
+const tokenLifetime = 48 * time.Hour
+
+// importHARCommand 从 HAR / mitmproxy 导出中提取 token 与用户信息。
+// 默认只预览（脱敏），加 -write 才写入：配置了 credentials.path 时写入凭据存储，否则写入 config.yaml。
+func importHARCommand(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("import-har")
+	formName := fs.String("form", "", "表单名称，默认使用默认表单")
+	write := fs.Bool("write", false, "写入配置（默认只预览）")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: sports-order import-har [参数] <抓包文件.har|.json>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	// 只需要表单与凭据相关配置，宽松解析：用户信息缺失或 token 来源无效正是要导入的原因
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		log.Printf("读取配置失败: %v", err)
+		return 1
+	}
+	var config common.Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		log.Printf("解析配置失败: %v", err)
+		return 1
+	}
+	form, err := config.Form(*formName)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+
+	requests, err := capture.LoadFile(fs.Arg(0))
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	result, err := capture.Extract(requests, form)
+	if err != nil {
+		log.Printf("%v（表单 %s，共 %d 个请求）", err, form.Name, len(requests))
+		return 1
+	}
+
+	printImportPreview(result)
+	if !*write {
+		fmt.Fprintln(os.Stderr, "\n以上为预览，确认无误后加 -write 写入")
+		return 0
+	}
+
+	baseDir := filepath.Dir(*configPath)
+	if config.Credentials.Path != "" {
+		err = importToStore(&config, baseDir, result.User)
+	} else {
+		err = importToConfig(*configPath, data, &config, baseDir, result.User)
+	}
+	if err != nil {
+		log.Printf("写入失败: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// printImportPreview 脱敏输出提取结果。
+func printImportPreview(result *capture.Result) {
+	user := result.User
+	fmt.Printf("来源:       %s\n", result.Source)
+	if !result.CapturedAt.IsZero() {
+		fmt.Printf("抓包时间:   %s\n", result.CapturedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("student_id: %s\n", common.MaskSecret(user.StudentID))
+	fmt.Printf("name:       %s\n", common.MaskSecret(user.Name))
+	fmt.Printf("phone:      %s\n", common.MaskSecret(user.Phone))
+	fmt.Printf("image_url:  %s\n", user.ImageURL)
+	fmt.Printf("token:      %s\n", common.MaskSecret(user.Token))
+
+	if user.Token == "" {
+		fmt.Fprintln(os.Stderr, "警告: 抓包中没有 Authorization 请求头，未提取到 token")
+	} else if !result.CapturedAt.IsZero() && time.Since(result.CapturedAt) > tokenLifetime {
+		fmt.Fprintf(os.Stderr, "警告: 抓包已超过 %v，token 可能已过期\n", tokenLifetime)
+	}
+}
+
+// importToStore 将提取结果合并进加密凭据存储。
+func importToStore(config *common.Config, baseDir string, user common.User) error {
+	secret, err := credentialSecret(config.Credentials, baseDir)
+	if err != nil {
+		return err
+	}
+	path := resolvePath(baseDir, config.Credentials.Path)
+	creds, err := credentials.Load(path, secret)
+	if errors.Is(err, os.ErrNotExist) {
+		creds, err = &credentials.Credentials{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	creds.Merge(user)
+	if err := credentials.Save(path, secret, creds); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已写入凭据存储 %s\n", path)
+	return nil
+}
+
+// importToConfig 将提取结果写入 config.yaml 的 user 节点（保留其余内容与注释）。
+// 配置了 token_file 时 token 写入该文件；配置了 token_env 时只提示，不写入。
+func importToConfig(configPath string, data []byte, config *common.Config, baseDir string, user common.User) error {
+	values := map[string]string{
+		"student_id": user.StudentID,
+		"name":       user.Name,
+		"phone":      user.Phone,
+		"image_url":  user.ImageURL,
+	}
+	switch {
+	case user.Token == "":
+	case config.User.TokenFile != "":
+		path := resolvePath(baseDir, config.User.TokenFile)
+		if err := os.WriteFile(path, []byte(user.Token+"\n"), 0o600); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "token 已写入 %s\n", path)
+	case config.User.TokenEnv != "":
+		fmt.Fprintf(os.Stderr, "config.yaml 使用 token_env，请自行将 token 设置到环境变量 %s\n", config.User.TokenEnv)
+	default:
+		values["token"] = user.Token
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if err := setMappingValues(&doc, "user", values); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	tmp := configPath + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, configPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已更新 %s\n", configPath)
+	return nil
+}
+
+// setMappingValues 在文档顶层的 section 映射中设置非空的字符串值，缺少的键或 section 会被追加。
+func setMappingValues(doc *yaml.Node, section string, values map[string]string) error {
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.New("配置文件顶层不是映射")
+	}
+
+	target := lookupMapping(root, section)
+	if target == nil {
+		target = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, target)
+	}
+
+	for _, key := range []string{"student_id", "name", "phone", "image_url", "token"} {
+		value, ok := values[key]
+		if !ok || value == "" {
+			continue
+		}
+		if node := lookupMapping(target, key); node != nil {
+			node.Kind, node.Tag, node.Value, node.Style = yaml.ScalarNode, "!!str", value, yaml.DoubleQuotedStyle
+			continue
+		}
+		target.Content = append(target.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: yaml.DoubleQuotedStyle})
+	}
+	return nil
+}
+
+// lookupMapping 返回映射节点中 key 对应的值节点。
+func lookupMapping(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
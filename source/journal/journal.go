@@ -0,0 +1,214 @@
+// Package journal 实现订单提交结果的本地预写日志（JSON Lines）。
+//
+// 每个提交结果在写数据库前追加一条 outcome 记录，写库成功后追加一条 applied 记录；
+// 每次追加后 fsync。进程崩溃或数据库不可用时，未标记 applied 的记录在下次启动时补写到数据库。
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sports_order/common"
+)
+
+// 记录类型
+const (
+	opOutcome = "outcome"
+	opApplied = "applied"
+)
+
+// record 是日志文件中的一行。
+type record struct {
+	Op    string               `json:"op"`
+	ID    string               `json:"id,omitempty"` // applied 记录对应的 outcome 记录 ID
+	Entry *common.JournalEntry `json:"entry,omitempty"`
+	At    time.Time            `json:"at"`
+}
+
+// Journal 是一个本地结果日志文件，可并发使用。同一文件同时只应由一个进程打开（见 run 的单实例锁）。
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	seq     int
+	pending []*common.JournalEntry
+}
+
+// Open 打开（不存在时创建）结果日志，并读出尚未写入数据库的记录。
+// 末尾不完整的一行（写入时崩溃）会被忽略。
+func Open(path string) (*Journal, error) {
+	pending, seq, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建结果日志目录失败: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("打开结果日志失败: %v", err)
+	}
+	return &Journal{path: path, file: file, seq: seq, pending: pending}, nil
+}
+
+// load 读取日志文件，返回没有 applied 记录的 outcome 记录，以及已用过的最大序号（新记录从其后编号，
+// 压缩后保留的记录与新记录 ID 不会重复）。
+func load(path string) ([]*common.JournalEntry, int, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取结果日志失败: %v", err)
+	}
+	defer file.Close()
+
+	var entries []*common.JournalEntry
+	seq := 0
+	applied := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue // 写入中途崩溃留下的半行
+		}
+		switch rec.Op {
+		case opOutcome:
+			if rec.Entry != nil {
+				entries = append(entries, rec.Entry)
+				seq = max(seq, entrySeq(rec.Entry.ID))
+			}
+		case opApplied:
+			applied[rec.ID] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("读取结果日志失败: %v", err)
+	}
+
+	var pending []*common.JournalEntry
+	for _, entry := range entries {
+		if !applied[entry.ID] {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, seq, nil
+}
+
+// entrySeq 返回记录 ID 末尾的序号。
+func entrySeq(id string) int {
+	seq, _ := strconv.Atoi(id[strings.LastIndex(id, "-")+1:])
+	return seq
+}
+
+// Path 返回日志文件路径。
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Record 为 entry 分配 ID 并落盘。返回 nil 后即使进程崩溃，该结果也能在下次启动时补写。
+func (j *Journal) Record(entry *common.JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	entry.ID = fmt.Sprintf("%s-%d", entry.RunID, j.seq)
+	if entry.At.IsZero() {
+		entry.At = time.Now()
+	}
+	if err := j.append(record{Op: opOutcome, Entry: entry, At: entry.At}); err != nil {
+		return err
+	}
+	j.pending = append(j.pending, entry)
+	return nil
+}
+
+// Applied 标记记录已写入数据库。
+func (j *Journal) Applied(id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.append(record{Op: opApplied, ID: id, At: time.Now()}); err != nil {
+		return err
+	}
+	for i, entry := range j.pending {
+		if entry.ID == id {
+			j.pending = append(j.pending[:i:i], j.pending[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Pending 返回尚未写入数据库的记录，按写入顺序。
+func (j *Journal) Pending() []*common.JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]*common.JournalEntry(nil), j.pending...)
+}
+
+// append 写入一行并 fsync。
+func (j *Journal) append(rec record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入结果日志失败: %v", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("写入结果日志失败: %v", err)
+	}
+	return nil
+}
+
+// Compact 只保留尚未写入数据库的记录，先写临时文件再替换，避免日志无限增长。
+func (j *Journal) Compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	tmp := j.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("压缩结果日志失败: %v", err)
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range j.pending {
+		data, _ := json.Marshal(record{Op: opOutcome, Entry: entry, At: entry.At})
+		writer.Write(append(data, '\n'))
+	}
+	err = writer.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("压缩结果日志失败: %v", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("压缩结果日志失败: %v", err)
+	}
+
+	reopened, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("打开结果日志失败: %v", err)
+	}
+	j.file.Close()
+	j.file = reopened
+	return nil
+}
+
+// Close 关闭日志文件。
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
@@ -0,0 +1,82 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sports_order/common"
+)
+
+// TestJournalPendingSurvivesReopen 未标记 applied 的记录在重新打开后仍待补写，末尾的半行被忽略。
+func TestJournalPendingSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sports-order.db.journal")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	success := &common.JournalEntry{OrderID: 1, RunID: "run-1", Status: common.OrderStatusSuccess}
+	failed := &common.JournalEntry{OrderID: 2, RunID: "run-1", Status: common.OrderStatusFailed, Error: "已约满"}
+	for _, entry := range []*common.JournalEntry{success, failed} {
+		if err := j.Record(entry); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if err := j.Applied(failed.ID); err != nil {
+		t.Fatalf("Applied: %v", err)
+	}
+	j.Close()
+
+	// 模拟写入中途崩溃
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	file.WriteString(`{"op":"outcome","entry":{"id":"run-1-3"`)
+	file.Close()
+
+	j, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer j.Close()
+	pending := j.Pending()
+	if len(pending) != 1 || pending[0].ID != success.ID || pending[0].Status != common.OrderStatusSuccess {
+		t.Fatalf("pending = %+v, want only %s", pending, success.ID)
+	}
+}
+
+// TestJournalCompact 压缩后只保留待补写的记录，新记录的 ID 不与保留的记录重复。
+func TestJournalCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	var entries []*common.JournalEntry
+	for i := 1; i <= 3; i++ {
+		entry := &common.JournalEntry{OrderID: uint(i), Status: common.OrderStatusSuccess}
+		j.Record(entry)
+		entries = append(entries, entry)
+	}
+	j.Applied(entries[0].ID)
+	j.Applied(entries[1].ID)
+	if err := j.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	j.Close()
+
+	j, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer j.Close()
+	next := &common.JournalEntry{OrderID: 4, Status: common.OrderStatusFailed}
+	if err := j.Record(next); err != nil {
+		t.Fatalf("Record after compact: %v", err)
+	}
+	if next.ID == entries[2].ID {
+		t.Fatalf("new entry reused id %s", next.ID)
+	}
+	j.Applied(next.ID)
+	if pending := j.Pending(); len(pending) != 1 || pending[0].ID != entries[2].ID {
+		t.Fatalf("pending = %+v, want only %s", pending, entries[2].ID)
+	}
+}
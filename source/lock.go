@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sports_order/common"
+)
+
+// lockHeldError 表示单实例锁被其他进程持有。
+type lockHeldError struct {
+	holder *common.ProcessLock // 持有者信息，锁文件内容无法解析时为 nil
+	path   string              // 锁文件路径
+}
+
+func (e *lockHeldError) Error() string {
+	if e.holder == nil {
+		return fmt.Sprintf("另一个实例正在运行（锁文件 %s 被占用）", e.path)
+	}
+	h := e.holder
+	return fmt.Sprintf("另一个实例正在运行: 主机 %s PID %d（运行 ID %s），开始于 %s，最近心跳 %v 前",
+		h.Host, h.PID, h.RunID, h.StartedAt.Local().Format("2006-01-02 15:04:05"),
+		time.Since(h.HeartbeatAt).Round(time.Second))
+}
+
+// instanceLock 是 run 命令的单实例锁：数据库旁的锁文件防止同一主机重复运行，
+// 数据库中的锁行（带心跳）防止共享数据库的多台主机重复运行。
+type instanceLock struct {
+	repo    *Repository
+	file    *os.File
+	info    common.ProcessLock
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// lockFilePath 返回锁文件路径：SQLite 为数据库文件（解析符号链接后）旁的 <path>.lock，
+// PostgreSQL 为临时目录下按连接串区分的文件。
+func lockFilePath(db common.DatabaseConfig) (string, error) {
+	if db.DriverName() == common.DriverPostgres {
+		sum := sha256.Sum256([]byte(db.DSN))
+		return filepath.Join(os.TempDir(), "sports-order-"+hex.EncodeToString(sum[:8])+".lock"), nil
+	}
+	path, err := filepath.Abs(db.Path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path + ".lock", nil
+}
+
+// fileLockCoversDatabase 判断锁文件是否覆盖了同一主机上使用该数据库的所有进程。SQLite 的锁文件在数据库文件旁，
+// 成立；PostgreSQL 的锁文件按连接串区分，写法不同（主机名与 IP、参数顺序、不同的临时目录）的连接串
+// 可能指向同一数据库，不成立。
+func fileLockCoversDatabase(db common.DatabaseConfig) bool {
+	return fileLockSupported && db.DriverName() != common.DriverPostgres
+}
+
+// canTakeOver 判断数据库锁行的持有者是否已退出：心跳超时，或锁文件覆盖整个数据库时同一主机的持有者
+// （若仍存活，锁文件不可能被本进程拿到）。
+func canTakeOver(db common.DatabaseConfig, host string, holder *common.ProcessLock) bool {
+	sameHost := fileLockCoversDatabase(db) && holder.Host == host
+	return sameHost || time.Since(holder.HeartbeatAt) > common.LockStaleAfter
+}
+
+// acquireInstanceLock 获得单实例锁。锁被占用时最多等待 wait（0 表示立即返回 lockHeldError）。
+// 获得锁后定期刷新心跳；锁被其他进程接管时调用 onLost。
+func acquireInstanceLock(ctx context.Context, app *app, wait time.Duration, onLost func(error)) (*instanceLock, error) {
+	deadline := time.Now().Add(wait)
+	waiting := false
+	for {
+		lock, err := tryInstanceLock(ctx, app)
+		var held *lockHeldError
+		if !errors.As(err, &held) || !time.Now().Before(deadline) {
+			if err == nil {
+				lock.startHeartbeat(ctx, onLost)
+			}
+			return lock, err
+		}
+		if !waiting {
+			log.Printf("%v，等待其退出（最长 %v）", err, wait)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// tryInstanceLock 依次获得锁文件与数据库锁行。
+func tryInstanceLock(ctx context.Context, app *app) (*instanceLock, error) {
+	path, err := lockFilePath(app.config.Database)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("打开锁文件失败: %v", err)
+	}
+	if err := lockFile(f); err != nil {
+		held := &lockHeldError{path: path}
+		if errors.Is(err, errFileLocked) {
+			var holder common.ProcessLock
+			if data, readErr := os.ReadFile(path); readErr == nil && json.Unmarshal(data, &holder) == nil && holder.PID != 0 {
+				held.holder = &holder
+			}
+			f.Close()
+			return nil, held
+		}
+		f.Close()
+		return nil, fmt.Errorf("锁定 %s 失败: %v", path, err)
+	}
+
+	host, _ := os.Hostname()
+	l := &instanceLock{
+		repo: app.repo,
+		file: f,
+		info: common.ProcessLock{
+			Name:  common.RunLockName,
+			Owner: common.LeaseOwner(ctx),
+			Host:  host,
+			PID:   os.Getpid(),
+			RunID: app.runID,
+		},
+	}
+
+	holder, err := app.repo.AcquireProcessLock(ctx, &l.info, func(holder *common.ProcessLock) bool {
+		return canTakeOver(app.config.Database, host, holder)
+	})
+	if err != nil || holder != nil {
+		unlockFile(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("获取数据库锁失败: %v", err)
+		}
+		return nil, &lockHeldError{holder: holder, path: path}
+	}
+
+	// 记录持有者，供其他进程在锁文件被占用时输出
+	if data, err := json.Marshal(l.info); err == nil {
+		f.Truncate(0)
+		f.WriteAt(data, 0)
+	}
+	return l, nil
+}
+
+// startHeartbeat 定期刷新数据库锁行的心跳。锁被接管，或心跳持续失败超过 LockStaleAfter 时调用 onLost。
+func (l *instanceLock) startHeartbeat(ctx context.Context, onLost func(error)) {
+	l.done = make(chan struct{})
+	l.stopped = make(chan struct{})
+	go func() {
+		defer close(l.stopped)
+		ticker := time.NewTicker(common.LockHeartbeatInterval)
+		defer ticker.Stop()
+		beatAt := time.Now()
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+			}
+			err := l.repo.HeartbeatProcessLock(context.WithoutCancel(ctx), l.info.Name, l.info.Owner)
+			switch {
+			case err == nil:
+				beatAt = time.Now()
+			case errors.Is(err, common.ErrLockLost) || time.Since(beatAt) > common.LockStaleAfter:
+				log.Printf("单实例锁已失效，停止处理: %v", err)
+				onLost(common.ErrLockLost)
+				return
+			default:
+				log.Printf("刷新单实例锁心跳失败: %v", err)
+			}
+		}
+	}()
+}
+
+// Release 停止心跳并释放数据库锁行与锁文件。
+func (l *instanceLock) Release(ctx context.Context) {
+	if l.done != nil {
+		close(l.done)
+		<-l.stopped
+	}
+	if err := l.repo.ReleaseProcessLock(context.WithoutCancel(ctx), l.info.Name, l.info.Owner); err != nil {
+		log.Printf("释放单实例锁失败: %v", err)
+	}
+	unlockFile(l.file)
+	l.file.Close()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sports_order/common"
+)
+
+// newTestApp 打开临时 SQLite 数据库并执行迁移。
+func newTestApp(t *testing.T, runID string) *app {
+	t.Helper()
+	config := &common.Config{Database: common.DatabaseConfig{Path: filepath.Join(t.TempDir(), "test.db")}}
+	db, err := InitDB(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CloseDB(db) })
+	if _, err := MigrateDB(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return &app{config: config, db: db, repo: NewRepository(db), runID: runID}
+}
+
+func TestInstanceLockExclusive(t *testing.T) {
+	ctx := context.Background()
+	a := newTestApp(t, "run-1")
+
+	first, err := acquireInstanceLock(common.WithRunID(ctx, "run-1"), a, 0, func(error) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = acquireInstanceLock(common.WithRunID(ctx, "run-2"), a, 0, func(error) {})
+	var held *lockHeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("second acquire err = %v, want lockHeldError", err)
+	}
+	if fileLockSupported && (held.holder == nil || held.holder.PID != os.Getpid() || held.holder.RunID != "run-1") {
+		t.Fatalf("holder = %+v, want this process with run-1", held.holder)
+	}
+	if !strings.Contains(err.Error(), "run-1") {
+		t.Errorf("message %q should name the holder", err)
+	}
+
+	// 等待期间持有者退出即可获得锁
+	time.AfterFunc(300*time.Millisecond, func() { first.Release(ctx) })
+	second, err := acquireInstanceLock(common.WithRunID(ctx, "run-2"), a, 5*time.Second, func(error) {})
+	if err != nil {
+		t.Fatalf("acquire with -wait: %v", err)
+	}
+	second.Release(ctx)
+}
+
+func TestInstanceLockTakeover(t *testing.T) {
+	ctx := context.Background()
+	a := newTestApp(t, "run-1")
+
+	// 其他主机持有且心跳新鲜：不能接管
+	other := &common.ProcessLock{Name: common.RunLockName, Owner: "other:42:run-0", Host: "other", PID: 42, RunID: "run-0",
+		StartedAt: time.Now().UTC(), HeartbeatAt: time.Now().UTC()}
+	if err := a.db.Create(other).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, err := acquireInstanceLock(ctx, a, 0, func(error) {})
+	var held *lockHeldError
+	if !errors.As(err, &held) || held.holder == nil || held.holder.Host != "other" || held.holder.PID != 42 {
+		t.Fatalf("err = %v, want held by other/42", err)
+	}
+
+	// 心跳超时：接管
+	stale := time.Now().UTC().Add(-2 * common.LockStaleAfter)
+	if err := a.db.Model(other).Update("heartbeat_at", stale).Error; err != nil {
+		t.Fatal(err)
+	}
+	lock, err := acquireInstanceLock(ctx, a, 0, func(error) {})
+	if err != nil {
+		t.Fatalf("takeover of stale lock: %v", err)
+	}
+	defer lock.Release(ctx)
+	holder, err := a.repo.processLockHolder(ctx, common.RunLockName)
+	if err != nil || holder == nil || holder.PID != os.Getpid() {
+		t.Fatalf("holder after takeover = %+v, %v", holder, err)
+	}
+}
+
+// TestCanTakeOver 同一主机心跳新鲜的持有者只在 SQLite 下接管：PostgreSQL 的锁文件按连接串区分，
+// 同一数据库的不同连接串拿到的是不同的锁文件。
+func TestCanTakeOver(t *testing.T) {
+	sqlite := common.DatabaseConfig{Path: "test.db"}
+	postgres := common.DatabaseConfig{Driver: common.DriverPostgres, DSN: "postgres://db/sports"}
+	fresh := &common.ProcessLock{Host: "vm", HeartbeatAt: time.Now()}
+	stale := &common.ProcessLock{Host: "other", HeartbeatAt: time.Now().Add(-2 * common.LockStaleAfter)}
+
+	tests := []struct {
+		name   string
+		db     common.DatabaseConfig
+		holder *common.ProcessLock
+		want   bool
+	}{
+		{"sqlite same host", sqlite, fresh, fileLockSupported},
+		{"postgres same host", postgres, fresh, false},
+		{"postgres stale", postgres, stale, true},
+		{"sqlite other host", sqlite, &common.ProcessLock{Host: "other", HeartbeatAt: time.Now()}, false},
+	}
+	for _, tt := range tests {
+		if got := canTakeOver(tt.db, "vm", tt.holder); got != tt.want {
+			t.Errorf("%s: canTakeOver = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestLockFileFollowsSymlink 经符号链接打开同一个 SQLite 数据库时使用同一个锁文件。
+func TestLockFileFollowsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.db")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skipf("symlink: %v", err)
+	}
+	direct, err := lockFilePath(common.DatabaseConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked, err := lockFilePath(common.DatabaseConfig{Path: link})
+	if err != nil || linked != direct {
+		t.Fatalf("lock file via symlink = %s, %v; want %s", linked, err, direct)
+	}
+}
@@ -1,47 +1,157 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"sports_order/common"
-	"sports_order/service"
+
+	"gorm.io/gorm"
 )
 
-// main 使用依赖注入组装各层依赖，并处理目标日期的待预约订单
+// command 是一个子命令，返回值作为进程退出码。
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) int
+}
+
+// commands 列出所有子命令；不带子命令时执行 run。
+var commands = []*command{
+	{name: "run", usage: "处理目标日期的待预约订单（默认）", run: runCommand},
+	{name: "snapshot", usage: "拉取表单目录并保存快照，输出与上一次快照的差异", run: snapshotCommand},
+	{name: "analytics", usage: "约满时间分析：sample 开放后高频采样，report 输出报告", run: analyticsCommand},
+	{name: "image", usage: "核对 image_url 图片的有效期", run: imageCommand},
+	{name: "doctor", usage: "开抢前检查配置、数据库、网络、时钟、token 与待处理订单", run: doctorCommand},
+	{name: "orders", usage: "订单管理：list 查看，add / edit 新建与修改，cancel 取消，timeline 查看变更记录，expire 将日期已过的订单置为 EXPIRED", run: ordersCommand},
+	{name: "migrate", usage: "数据库迁移：up 执行未执行的迁移，status 查看迁移状态", run: migrateCommand},
+	{name: "credentials", usage: "加密凭据存储：set 写入、show 脱敏展示、rotate 更换口令、gen-key 生成密钥", run: credentialsCommand},
+	{name: "import-har", usage: "从 HAR / mitmproxy 抓包导出中导入 token 与用户信息", run: importHARCommand},
+}
+
+// main 解析子命令并执行，收到 SIGINT/SIGTERM 时取消 ctx。
 func main() {
-	// 加载配置
-	config, err := LoadConfig()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			code := cmd.run(ctx, args)
+			stop()
+			os.Exit(code)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
+
+// printUsage 输出子命令列表。
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "用法: sports-order [命令] [参数]")
+	fmt.Fprintln(os.Stderr, "\n可用命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\n使用 sports-order <命令> -h 查看命令参数")
+}
+
+// newFlagSet 创建子命令的参数解析器，所有命令都支持 -config。
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "配置文件路径")
+	return fs, configPath
+}
+
+// app 汇集各命令共用的依赖：配置、数据库与仓储。
+type app struct {
+	config *common.Config
+	db     *gorm.DB
+	repo   *Repository
+	runID  string
+}
+
+// newApp 加载配置、打开数据库并执行尚未执行的迁移。返回的 ctx 携带本次运行 ID。
+func newApp(ctx context.Context, configPath string) (context.Context, *app, error) {
+	config, err := LoadConfigFrom(configPath)
 	if err != nil {
-		log.Fatalf("加载配置失败: %v", err)
+		return ctx, nil, fmt.Errorf("加载配置失败: %v", err)
 	}
 
-	// 初始化数据库
 	db, err := InitDB(config)
 	if err != nil {
-		log.Fatalf("初始化数据库失败: %v", err)
+		return ctx, nil, fmt.Errorf("初始化数据库失败: %v", err)
+	}
+	applied, err := MigrateDB(ctx, db)
+	for _, m := range applied {
+		log.Printf("已执行数据库迁移 %s", m.ID())
+	}
+	if err != nil {
+		CloseDB(db)
+		return ctx, nil, fmt.Errorf("数据库迁移失败: %v", err)
 	}
-	defer CloseDB(db)
 
-	// 初始化仓储层
 	repo := NewRepository(db)
+	if err := normalizeOrderForms(ctx, repo, config); err != nil {
+		CloseDB(db)
+		return ctx, nil, err
+	}
 
-	// 记录启动日志
-	repo.CreateLog(common.LogLevelInfo, "应用启动", nil)
-
-	// 初始化服务层
-	apiClient := NewHTTPClient()
-	orderProcessor := service.NewOrderProcessor(apiClient, repo, &config.User)
-
-	// 计算目标日期
-	targetDate := time.Now().AddDate(0, 0, common.DaysAhead).Format("2006-01-02")
+	runID := newRunID()
+	return common.WithRunID(ctx, runID), &app{
+		config: config,
+		db:     db,
+		repo:   repo,
+		runID:  runID,
+	}, nil
+}
 
-	// 处理目标日期的订单
-	if err := orderProcessor.ProcessOrdersForDate(targetDate); err != nil {
-		repo.CreateLogf(common.LogLevelError, nil, "处理订单失败: %v", err)
-		log.Fatalf("处理订单失败: %v", err)
+// normalizeOrderForms 将未指定表单的订单归入默认表单（见 Repository.NormalizeOrderForms）。
+// 配置了多个表单且没有 default_form 时无法确定默认表单，这些订单保持原样。
+func normalizeOrderForms(ctx context.Context, repo *Repository, config *common.Config) error {
+	form, err := config.Form("")
+	if err != nil {
+		return nil
 	}
+	result, err := repo.NormalizeOrderForms(ctx, form.Name)
+	if err != nil {
+		return fmt.Errorf("订单归入默认表单 %s 失败: %v", form.Name, err)
+	}
+	if result.Normalized > 0 {
+		log.Printf("已将 %d 个未指定表单的订单归入默认表单 %s", result.Normalized, form.Name)
+	}
+	for _, id := range result.Cancelled {
+		log.Printf("订单 %d 与表单 %s 的同一时段场地重复，已取消", id, form.Name)
+	}
+	for _, id := range result.Conflicts {
+		log.Printf("订单 %d 与表单 %s 的同一时段场地重复且不能取消，未归入默认表单，请手动处理", id, form.Name)
+	}
+	return nil
+}
+
+// Close 释放数据库连接。
+func (a *app) Close() {
+	CloseDB(a.db)
+}
 
-	// 记录完成日志
-	repo.CreateLogf(common.LogLevelInfo, nil, "订单处理完成，目标日期: %s", targetDate)
+// newRunID 生成本次运行的唯一标识：启动时间 + 随机后缀。
+func newRunID() string {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(buf)
 }
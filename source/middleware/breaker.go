@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"sports_order/common"
+)
+
+// 熔断默认值
+const (
+	defaultFailureThreshold = 5
+	defaultCooldown         = 10 * time.Second
+)
+
+// ErrCircuitOpen 表示熔断器处于打开状态，请求被直接拒绝。
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// breakerState 表示熔断器状态。
+type breakerState int
+
+const (
+	stateClosed   breakerState = iota // 正常放行
+	stateOpen                         // 熔断中，直接拒绝
+	stateHalfOpen                     // 冷却结束，放行一个试探请求
+)
+
+// CircuitBreaker 在连续失败达到阈值后熔断一段时间，避免对已经不可用的服务持续施压。
+// 只有网络错误与 5xx 计为失败；业务拒绝（4xx）说明服务本身可用，不触发熔断。
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+// NewCircuitBreaker 按配置创建熔断器。
+func NewCircuitBreaker(cfg common.CircuitBreakerConfig) *CircuitBreaker {
+	threshold := cfg.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	cooldown := defaultCooldown
+	if cfg.CooldownSec > 0 {
+		cooldown = time.Duration(cfg.CooldownSec) * time.Second
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow 判断当前是否放行请求。
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = stateHalfOpen
+		return true
+	case stateHalfOpen:
+		// 试探请求尚未返回，其余请求继续拒绝
+		return false
+	default:
+		return true
+	}
+}
+
+// record 记录一次请求结果并更新状态。
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !countsAsFailure(err) {
+		b.state = stateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		b.state = stateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// countsAsFailure 判断错误是否说明服务不可用。
+func countsAsFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if httpErr, ok := common.AsHTTPError(err); ok {
+		return httpErr.Retryable()
+	}
+	return true
+}
+
+// Middleware 返回使用该熔断器的中间件。
+func (b *CircuitBreaker) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			if !b.allow() {
+				return nil, ErrCircuitOpen
+			}
+			resp, err := next(ctx, req)
+			b.record(err)
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"sports_order/common"
+)
+
+// defaultMaxBodySize 是日志中默认保留的响应体字节数。
+const defaultMaxBodySize = 512
+
+// Logf 是日志输出函数，签名与 log.Printf 一致。
+type Logf func(format string, args ...any)
+
+// Logging 记录每次请求与响应，token、手机号、学号等敏感值会被脱敏。
+func Logging(cfg common.LoggingConfig, redactor *common.Redactor, logf Logf) Middleware {
+	maxBody := cfg.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = defaultMaxBodySize
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			start := time.Now()
+			if len(req.Body) > 0 {
+				logf("[api] --> %s %s body=%s", req.Method, req.URL, truncate(redactor.Redact(string(req.Body)), maxBody))
+			} else {
+				logf("[api] --> %s %s", req.Method, req.URL)
+			}
+
+			resp, err := next(ctx, req)
+			elapsed := time.Since(start)
+			if err != nil {
+				logf("[api] <-- %s %s error=%s (%v)", req.Method, req.URL, redactor.Redact(err.Error()), elapsed)
+				return resp, err
+			}
+			logf("[api] <-- %s %s %d bytes (%v) body=%s", req.Method, req.URL, len(resp), elapsed,
+				truncate(redactor.Redact(string(resp)), maxBody))
+			return resp, nil
+		}
+	}
+}
+
+// truncate 将文本截断到 n 字节以内。
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "...(truncated)"
+}
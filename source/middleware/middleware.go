@@ -0,0 +1,82 @@
+// Package middleware 提供包装 common.APIClient 的中间件（日志、重试、限流、熔断、耗时统计）。
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"sports_order/common"
+)
+
+// Request 描述一次 API 调用，让中间件可以统一处理 Get 与 Post。
+type Request struct {
+	Method  string
+	URL     string
+	Body    []byte
+	Auth    string
+	Headers map[string]string
+}
+
+// Handler 执行一次 API 调用。
+type Handler func(ctx context.Context, req *Request) ([]byte, error)
+
+// Middleware 包装 Handler，在调用前后附加横切逻辑。
+type Middleware func(next Handler) Handler
+
+// Wrap 用中间件包装 APIClient。mws 中第一个中间件位于最外层。
+func Wrap(client common.APIClient, mws ...Middleware) common.APIClient {
+	handler := func(ctx context.Context, req *Request) ([]byte, error) {
+		if req.Method == http.MethodPost {
+			return client.Post(ctx, req.URL, req.Body, req.Auth, req.Headers)
+		}
+		return client.Get(ctx, req.URL, req.Headers)
+	}
+
+	var h Handler = handler
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return &wrappedClient{handler: h}
+}
+
+// wrappedClient 将 Handler 适配回 APIClient 接口。
+type wrappedClient struct {
+	handler Handler
+}
+
+func (c *wrappedClient) Get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	return c.handler(ctx, &Request{Method: http.MethodGet, URL: url, Headers: headers})
+}
+
+func (c *wrappedClient) Post(ctx context.Context, url string, data []byte, auth string, headers map[string]string) ([]byte, error) {
+	return c.handler(ctx, &Request{Method: http.MethodPost, URL: url, Body: data, Auth: auth, Headers: headers})
+}
+
+// Build 按配置组装中间件链：耗时统计 → 重试 → 熔断 → 限流 → 日志 → inner → client。
+// 重试位于熔断之外，熔断期间的重试会被立即拒绝；限流与日志作用于每一次实际请求。
+// inner 放在最内层（如请求录制），同样作用于每一次实际请求。
+// 未启用耗时统计时返回的 TimingRecorder 为 nil。
+func Build(client common.APIClient, cfg common.MiddlewareConfig, redactor *common.Redactor, logf Logf, inner ...Middleware) (common.APIClient, *TimingRecorder) {
+	var (
+		mws      []Middleware
+		recorder *TimingRecorder
+	)
+	if cfg.Timing.Enabled {
+		recorder = NewTimingRecorder()
+		mws = append(mws, recorder.Middleware())
+	}
+	if cfg.Retry.Enabled {
+		mws = append(mws, Retry(cfg.Retry))
+	}
+	if cfg.CircuitBreaker.Enabled {
+		mws = append(mws, NewCircuitBreaker(cfg.CircuitBreaker).Middleware())
+	}
+	if cfg.RateLimit.Enabled {
+		mws = append(mws, NewRateLimit(cfg.RateLimit))
+	}
+	if cfg.Logging.Enabled {
+		mws = append(mws, Logging(cfg.Logging, redactor, logf))
+	}
+	mws = append(mws, inner...)
+	return Wrap(client, mws...), recorder
+}
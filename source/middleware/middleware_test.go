@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sports_order/common"
+)
+
+// fakeClient 按顺序返回预设的结果，并记录调用次数。
+type fakeClient struct {
+	mu      sync.Mutex
+	calls   []string
+	results []error
+	body    []byte
+}
+
+func (c *fakeClient) next(method string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := len(c.calls)
+	c.calls = append(c.calls, method)
+	if i < len(c.results) && c.results[i] != nil {
+		return nil, c.results[i]
+	}
+	return c.body, nil
+}
+
+func (c *fakeClient) Get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	return c.next(http.MethodGet)
+}
+
+func (c *fakeClient) Post(ctx context.Context, url string, data []byte, auth string, headers map[string]string) ([]byte, error) {
+	return c.next(http.MethodPost)
+}
+
+func (c *fakeClient) callCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.calls)
+}
+
+var errServer = &common.HTTPError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}
+
+func TestWrapDispatchesByMethod(t *testing.T) {
+	client := &fakeClient{body: []byte("ok")}
+	wrapped := Wrap(client)
+
+	wrapped.Get(context.Background(), "u", nil)
+	wrapped.Post(context.Background(), "u", []byte("{}"), "token", nil)
+
+	if got := strings.Join(client.calls, ","); got != "GET,POST" {
+		t.Fatalf("calls = %s, want GET,POST", got)
+	}
+}
+
+func TestLoggingRedactsSecrets(t *testing.T) {
+	client := &fakeClient{body: []byte(`{"phone":"13800138000"}`)}
+	var lines []string
+	logf := func(format string, args ...any) { lines = append(lines, fmt.Sprintf(format, args...)) }
+
+	wrapped := Wrap(client, Logging(common.LoggingConfig{}, common.NewRedactor("secret-token", "13800138000"), logf))
+	wrapped.Post(context.Background(), "https://example.com", []byte(`{"token":"secret-token"}`), "secret-token", nil)
+
+	out := strings.Join(lines, "\n")
+	if strings.Contains(out, "secret-token") || strings.Contains(out, "13800138000") {
+		t.Fatalf("log leaked secrets:\n%s", out)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2", len(lines))
+	}
+}
+
+func TestRetryGetOnServerError(t *testing.T) {
+	client := &fakeClient{results: []error{errServer, errServer}, body: []byte("ok")}
+	wrapped := Wrap(client, Retry(common.RetryConfig{MaxAttempts: 3, BaseDelayMs: 1, MaxDelayMs: 2}))
+
+	resp, err := wrapped.Get(context.Background(), "u", nil)
+	if err != nil || string(resp) != "ok" {
+		t.Fatalf("Get = %q, %v; want ok, nil", resp, err)
+	}
+	if client.callCount() != 3 {
+		t.Fatalf("calls = %d, want 3", client.callCount())
+	}
+}
+
+func TestRetrySkipsPostAndClientErrors(t *testing.T) {
+	client := &fakeClient{results: []error{errServer}}
+	wrapped := Wrap(client, Retry(common.RetryConfig{MaxAttempts: 3, BaseDelayMs: 1}))
+	if _, err := wrapped.Post(context.Background(), "u", nil, "", nil); err == nil {
+		t.Fatal("Post should return the server error")
+	}
+	if client.callCount() != 1 {
+		t.Fatalf("POST calls = %d, want 1", client.callCount())
+	}
+
+	client = &fakeClient{results: []error{&common.HTTPError{StatusCode: http.StatusUnauthorized, Status: "401"}}}
+	wrapped = Wrap(client, Retry(common.RetryConfig{MaxAttempts: 3, BaseDelayMs: 1}))
+	wrapped.Get(context.Background(), "u", nil)
+	if client.callCount() != 1 {
+		t.Fatalf("GET 401 calls = %d, want 1", client.callCount())
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	bucket := NewTokenBucket(2, 2)
+	bucket.now = func() time.Time { return now }
+	bucket.last = now
+
+	if bucket.reserve() != 0 || bucket.reserve() != 0 {
+		t.Fatal("burst tokens should be available immediately")
+	}
+	if wait := bucket.reserve(); wait != 500*time.Millisecond {
+		t.Fatalf("third reserve wait = %v, want 500ms", wait)
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if wait := bucket.reserve(); wait != 0 {
+		t.Fatalf("reserve after refill wait = %v, want 0", wait)
+	}
+}
+
+func TestTokenBucketWaitCancelled(t *testing.T) {
+	bucket := NewTokenBucket(0.001, 1)
+	bucket.Wait(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bucket.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v, want context.Canceled", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	breaker := NewCircuitBreaker(common.CircuitBreakerConfig{FailureThreshold: 2, CooldownSec: 10})
+	breaker.now = func() time.Time { return now }
+
+	client := &fakeClient{results: []error{errServer, errServer}, body: []byte("ok")}
+	wrapped := Wrap(client, breaker.Middleware())
+
+	wrapped.Get(context.Background(), "u", nil)
+	wrapped.Get(context.Background(), "u", nil)
+	if _, err := wrapped.Get(context.Background(), "u", nil); err != ErrCircuitOpen {
+		t.Fatalf("third call err = %v, want ErrCircuitOpen", err)
+	}
+	if client.callCount() != 2 {
+		t.Fatalf("calls = %d, want 2", client.callCount())
+	}
+
+	// 冷却结束后放行试探请求，成功则恢复
+	now = now.Add(11 * time.Second)
+	if _, err := wrapped.Get(context.Background(), "u", nil); err != nil {
+		t.Fatalf("probe call err = %v, want nil", err)
+	}
+	if _, err := wrapped.Get(context.Background(), "u", nil); err != nil {
+		t.Fatalf("call after recovery err = %v, want nil", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	breaker := NewCircuitBreaker(common.CircuitBreakerConfig{FailureThreshold: 1})
+	rejected := &common.HTTPError{StatusCode: http.StatusUnprocessableEntity, Status: "422"}
+	client := &fakeClient{results: []error{rejected, rejected}}
+	wrapped := Wrap(client, breaker.Middleware())
+
+	wrapped.Post(context.Background(), "u", nil, "", nil)
+	if _, err := wrapped.Post(context.Background(), "u", nil, "", nil); err == ErrCircuitOpen {
+		t.Fatal("4xx responses should not open the breaker")
+	}
+}
+
+func TestTimingRecorder(t *testing.T) {
+	recorder := NewTimingRecorder()
+	client := &fakeClient{results: []error{nil, errServer}}
+	wrapped := Wrap(client, recorder.Middleware())
+
+	wrapped.Get(context.Background(), "u", nil)
+	wrapped.Get(context.Background(), "u", nil)
+	wrapped.Post(context.Background(), "u", nil, "", nil)
+
+	stats := recorder.Stats()
+	if stats[http.MethodGet].Count != 2 || stats[http.MethodGet].Errors != 1 {
+		t.Fatalf("GET stats = %+v, want Count 2 Errors 1", stats[http.MethodGet])
+	}
+	if stats[http.MethodPost].Count != 1 {
+		t.Fatalf("POST stats = %+v, want Count 1", stats[http.MethodPost])
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sports_order/common"
+)
+
+// 限流默认值
+const (
+	defaultRPS   = 5
+	defaultBurst = 10
+)
+
+// TokenBucket 是一个并发安全的令牌桶，所有订单协程共享同一个实例。
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64 // 桶容量
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewTokenBucket 创建令牌桶，初始为满桶。
+func NewTokenBucket(rps float64, burst int) *TokenBucket {
+	if rps <= 0 {
+		rps = defaultRPS
+	}
+	if burst <= 0 {
+		burst = defaultBurst
+	}
+	return &TokenBucket{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// reserve 取走一个令牌，返回需要等待的时长（0 表示立即可用）。
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Wait 阻塞直到取得令牌或 ctx 被取消。
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	wait := b.reserve()
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// 归还预占的令牌
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// RateLimit 使用共享令牌桶限制请求速率。
+func RateLimit(bucket *TokenBucket) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			if err := bucket.Wait(ctx); err != nil {
+				return nil, err
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// NewRateLimit 按配置创建令牌桶与对应的中间件。
+func NewRateLimit(cfg common.RateLimitConfig) Middleware {
+	return RateLimit(NewTokenBucket(cfg.RPS, cfg.Burst))
+}
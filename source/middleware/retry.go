@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"sports_order/common"
+)
+
+// 重试默认值
+const (
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 200 * time.Millisecond
+	defaultMaxDelay    = 2 * time.Second
+)
+
+// Retry 对幂等的 GET 请求按指数退避重试；POST（提交预约）从不重试，避免重复下单。
+func Retry(cfg common.RetryConfig) Middleware {
+	attempts := cfg.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultMaxAttempts
+	}
+	baseDelay := millisOr(cfg.BaseDelayMs, defaultBaseDelay)
+	maxDelay := millisOr(cfg.MaxDelayMs, defaultMaxDelay)
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			if req.Method != http.MethodGet {
+				return next(ctx, req)
+			}
+
+			var (
+				resp []byte
+				err  error
+			)
+			for attempt := 1; attempt <= attempts; attempt++ {
+				resp, err = next(ctx, req)
+				if err == nil || !retryable(err) || attempt == attempts {
+					return resp, err
+				}
+
+				select {
+				case <-time.After(backoff(attempt, baseDelay, maxDelay)):
+				case <-ctx.Done():
+					return nil, err
+				}
+			}
+			return resp, err
+		}
+	}
+}
+
+// retryable 判断错误是否值得重试：网络错误、429 与 5xx 重试，其余 4xx 与熔断错误不重试。
+func retryable(err error) bool {
+	if err == ErrCircuitOpen {
+		return false
+	}
+	if httpErr, ok := common.AsHTTPError(err); ok {
+		return httpErr.Retryable()
+	}
+	return true
+}
+
+// backoff 计算第 attempt 次失败后的等待时长（指数退避 + 随机抖动）。
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base << (attempt - 1)
+	if delay > max || delay <= 0 {
+		delay = max
+	}
+	// 在 [delay/2, delay] 区间内抖动，避免多个协程同时重试
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// millisOr 将毫秒配置转换为 time.Duration，未配置时使用默认值。
+func millisOr(ms int, def time.Duration) time.Duration {
+	if ms <= 0 {
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
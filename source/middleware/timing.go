@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timing 记录一次调用的耗时。
+type Timing struct {
+	Method   string
+	URL      string
+	Duration time.Duration
+	Err      error
+}
+
+// TimingRecorder 收集所有调用的耗时，供运行结束时输出统计。
+type TimingRecorder struct {
+	mu      sync.Mutex
+	timings []Timing
+}
+
+// NewTimingRecorder 创建耗时收集器。
+func NewTimingRecorder() *TimingRecorder {
+	return &TimingRecorder{}
+}
+
+// Middleware 返回记录耗时的中间件。
+func (r *TimingRecorder) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) ([]byte, error) {
+			start := time.Now()
+			resp, err := next(ctx, req)
+			r.mu.Lock()
+			r.timings = append(r.timings, Timing{Method: req.Method, URL: req.URL, Duration: time.Since(start), Err: err})
+			r.mu.Unlock()
+			return resp, err
+		}
+	}
+}
+
+// Timings 返回已记录耗时的副本。
+func (r *TimingRecorder) Timings() []Timing {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Timing(nil), r.timings...)
+}
+
+// TimingStats 是一组耗时的汇总。
+type TimingStats struct {
+	Count  int
+	Errors int
+	Min    time.Duration
+	Median time.Duration
+	Max    time.Duration
+}
+
+// Stats 按请求方法汇总耗时。
+func (r *TimingRecorder) Stats() map[string]TimingStats {
+	byMethod := make(map[string][]Timing)
+	for _, t := range r.Timings() {
+		byMethod[t.Method] = append(byMethod[t.Method], t)
+	}
+
+	stats := make(map[string]TimingStats, len(byMethod))
+	for method, timings := range byMethod {
+		durations := make([]time.Duration, len(timings))
+		errCount := 0
+		for i, t := range timings {
+			durations[i] = t.Duration
+			if t.Err != nil {
+				errCount++
+			}
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		stats[method] = TimingStats{
+			Count:  len(durations),
+			Errors: errCount,
+			Min:    durations[0],
+			Median: durations[len(durations)/2],
+			Max:    durations[len(durations)-1],
+		}
+	}
+	return stats
+}
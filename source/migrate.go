@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"sports_order/migrations"
+
+	"gorm.io/gorm"
+)
+
+// migrateCommand 分发 migrate 的子命令：up 执行未执行的迁移，status 查看迁移状态。
+// 其他命令启动时会自动执行迁移，migrate up 用于初始化数据库或单独升级。
+func migrateCommand(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: sports-order migrate <up|status> [参数]")
+		return 2
+	}
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, args[1:])
+	case "status":
+		return migrateStatus(ctx, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知的 migrate 子命令: %s\n", args[0])
+		return 2
+	}
+}
+
+// openMigrateDB 只读取数据库配置并打开数据库，不要求用户信息与 token 有效。
+func openMigrateDB(configPath string) (*gorm.DB, error) {
+	config, err := parseConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载配置失败: %v", err)
+	}
+	return InitDB(config)
+}
+
+// migrateUp 执行尚未执行的迁移；数据库文件不存在时新建。
+func migrateUp(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("migrate up")
+	fs.Parse(args)
+
+	db, err := openMigrateDB(*configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer CloseDB(db)
+
+	applied, err := MigrateDB(ctx, db)
+	for _, m := range applied {
+		fmt.Printf("已执行 %s\n", m.ID())
+	}
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	if len(applied) == 0 {
+		fmt.Println("数据库已是最新")
+	}
+	return 0
+}
+
+// migrateStatus 列出全部迁移及其执行时间。有未执行的迁移时退出码为 3。
+func migrateStatus(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("migrate status")
+	fs.Parse(args)
+
+	db, err := openMigrateDB(*configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer CloseDB(db)
+
+	statuses, err := migrations.StatusOf(ctx, db)
+	pending := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "版本\t名称\t执行时间")
+	for _, status := range statuses {
+		appliedAt := "未执行"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		} else {
+			pending++
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	w.Flush()
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	if pending > 0 {
+		fmt.Fprintf(os.Stderr, "%d 个迁移未执行，执行 sports-order migrate up 或任意命令启动时自动执行\n", pending)
+		return 3
+	}
+	return 0
+}
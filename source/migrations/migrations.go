@@ -0,0 +1,224 @@
+// Package migrations 管理内嵌在程序中的版本化数据库迁移。
+//
+// 迁移文件位于 <方言>/NNNN_名称.sql（方言为 GORM Dialector 名称：sqlite、postgres），
+// 按版本号顺序执行，已执行的版本记录在 schema_migrations 表中。
+// 每个版本在所有方言下都必须存在且同名；迁移一旦发布就不再修改，表结构变更一律追加新的迁移文件。
+//
+// 0001 与最早的 database/init.sql 完全一致。之后各版本的 init.sql 建好的数据库可能已有
+// orders.form、logs.run_id 列，首次迁移时由 Up 接管（见 legacyColumns）。
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// Dialects 是内嵌了迁移的数据库方言。
+var Dialects = []string{"sqlite", "postgres"}
+
+// Migration 是一个版本化的迁移。
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// ID 返回形如 0005_orders_indexes 的迁移标识。
+func (m Migration) ID() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// SchemaMigration 是 schema_migrations 表中的一行。
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName 指定表名。
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 是一个迁移的执行状态，未执行时 AppliedAt 为 nil。
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load 读取指定方言的全部迁移并按版本号排序。
+func Load(dialect string) ([]Migration, error) {
+	names, err := fs.Glob(files, dialect+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("不支持的数据库方言: %s", dialect)
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	seen := make(map[int]string)
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+		prefix, rest, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("迁移文件名无效: %s（应为 NNNN_名称.sql）", name)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("迁移版本重复: %s 与 %s", other, name)
+		}
+		seen[version] = name
+
+		data, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: rest, SQL: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// StatusOf 返回全部迁移的执行状态。数据库中存在程序不认识的版本时返回错误（程序版本过旧）。
+func StatusOf(ctx context.Context, db *gorm.DB) ([]Status, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]bool, len(migrations))
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+		status := Status{Migration: m}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for version, row := range applied {
+		if !known[version] {
+			return statuses, fmt.Errorf("数据库已执行迁移 %04d_%s，但当前程序不认识该版本，请升级程序", version, row.Name)
+		}
+	}
+	return statuses, nil
+}
+
+// Pending 返回尚未执行的迁移。
+func Pending(ctx context.Context, db *gorm.DB) ([]Migration, error) {
+	statuses, err := StatusOf(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// legacyColumn 是早期 init.sql 可能已经建好的列。
+type legacyColumn struct {
+	Table  string
+	Column string
+}
+
+// legacyColumns 按添加该列的迁移版本索引。
+var legacyColumns = map[int]legacyColumn{
+	2: {"orders", "form"},
+	3: {"logs", "run_id"},
+}
+
+// Up 按版本顺序执行尚未执行的迁移，每个迁移与其版本记录在同一事务中提交。
+// 返回本次执行的迁移；中途失败时之前的迁移保持已提交。
+// 没有 schema_migrations 表但已有 orders 表的数据库（由 init.sql 建好）先由 adopt 接管。
+func Up(ctx context.Context, db *gorm.DB) ([]Migration, error) {
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		legacy := db.Migrator().HasTable("orders")
+		if err := db.WithContext(ctx).Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return nil, fmt.Errorf("创建 schema_migrations 表失败: %w", err)
+		}
+		if legacy {
+			if err := adopt(ctx, db); err != nil {
+				return nil, err
+			}
+		}
+	}
+	pending, err := Pending(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range pending {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(m.SQL).Error; err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("迁移 %s 失败: %w", m.ID(), err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// adopt 接管 init.sql 建好的数据库：legacyColumns 中已经存在的列，其迁移直接记为已执行，
+// 不再执行 ADD COLUMN；其余迁移照常执行（CREATE TABLE IF NOT EXISTS 对已有的表不做改动）。
+func adopt(ctx context.Context, db *gorm.DB) error {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	for _, m := range migrations {
+		column, ok := legacyColumns[m.Version]
+		if !ok || !db.Migrator().HasColumn(column.Table, column.Column) {
+			continue
+		}
+		if err := db.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error; err != nil {
+			return fmt.Errorf("记录已有列 %s.%s 失败: %w", column.Table, column.Column, err)
+		}
+	}
+	return nil
+}
+
+// appliedVersions 读取已执行的迁移；schema_migrations 表不存在时视为没有执行过任何迁移。
+func appliedVersions(ctx context.Context, db *gorm.DB) (map[int]SchemaMigration, error) {
+	db = db.WithContext(ctx)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return map[int]SchemaMigration{}, nil
+	}
+
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
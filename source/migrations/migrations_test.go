@@ -0,0 +1,239 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+// TestDialectsInSync 确认每个方言都有同样的迁移版本与名称。
+func TestDialectsInSync(t *testing.T) {
+	var want []string
+	for _, dialect := range Dialects {
+		migrations, err := Load(dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, m := range migrations {
+			ids = append(ids, m.ID())
+		}
+		if want == nil {
+			want = ids
+			continue
+		}
+		if strings.Join(ids, ",") != strings.Join(want, ",") {
+			t.Errorf("%s migrations = %v, want %v", dialect, ids, want)
+		}
+	}
+}
+
+func TestUpAppliesAllOnce(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	all, err := Load("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := Up(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(all) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(all))
+	}
+
+	applied, err = Up(ctx, db)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("second Up = %v, %v; want nothing", applied, err)
+	}
+	statuses, err := StatusOf(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("%s not applied", status.ID())
+		}
+	}
+}
+
+// legacySchema 读取最早的 database/init.sql。
+func legacySchema(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/baseline_init.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestUpAdoptsLegacyDatabase 模拟最早的 init.sql 建好的数据库：没有 form、run_id 列与 schema_migrations，且有重复订单。
+func TestUpAdoptsLegacyDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	if err := db.Exec(legacySchema(t)).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.Exec("INSERT INTO orders (date, hour, venue) VALUES ('2025-12-21', 15, 4)").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Exec("INSERT INTO logs (level, message) VALUES ('INFO', 'old')").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := Up(ctx, db)
+	if err == nil || !strings.Contains(err.Error(), "0005_orders_indexes") {
+		t.Fatalf("err = %v, want 0005 to fail on duplicate orders", err)
+	}
+	if len(applied) != 4 || applied[3].Version != 4 {
+		t.Fatalf("applied = %v, want 0001 to 0004", applied)
+	}
+	pending, err := Pending(ctx, db)
+	if err != nil || len(pending) == 0 || pending[0].Version != 5 {
+		t.Fatalf("pending = %v, %v; want 0005 first", pending, err)
+	}
+
+	if err := db.Exec("DELETE FROM orders WHERE id = 2").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Up(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec("INSERT INTO orders (date, hour, venue) VALUES ('2025-12-21', 15, 4)").Error
+	if err == nil {
+		t.Fatal("duplicate order inserted after 0005")
+	}
+	if err := db.Exec("INSERT INTO orders (date, hour, venue, form) VALUES ('2025-12-21', 15, 4, 'tennis')").Error; err != nil {
+		t.Fatalf("same slot on another form rejected: %v", err)
+	}
+	if err := db.Exec("INSERT INTO logs (level, message, run_id) VALUES ('INFO', 'new', 'run')").Error; err != nil {
+		t.Fatalf("log with run_id rejected: %v", err)
+	}
+}
+
+// TestUpAdoptsLaterInitSQL 模拟之后版本的 init.sql 建好的数据库：已有 form、run_id 列与目录快照表，
+// 对应的迁移直接记为已执行，不再重复添加列。
+func TestUpAdoptsLaterInitSQL(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	all, err := Load("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := legacySchema(t) + `
+ALTER TABLE orders ADD COLUMN form TEXT NOT NULL DEFAULT '';
+ALTER TABLE logs ADD COLUMN run_id TEXT NOT NULL DEFAULT '';
+` + all[3].SQL
+	if err := db.Exec(schema).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := Up(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(all)-2 {
+		t.Fatalf("applied %d migrations, want all but 0002 and 0003", len(applied))
+	}
+	for _, m := range applied {
+		if _, ok := legacyColumns[m.Version]; ok {
+			t.Errorf("%s executed on a database that already had the column", m.ID())
+		}
+	}
+	pending, err := Pending(ctx, db)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("pending = %v, %v; want none", pending, err)
+	}
+}
+
+func TestStatusRejectsUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	if _, err := Up(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&SchemaMigration{Version: 9999, Name: "future"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Up(ctx, db); err == nil || !strings.Contains(err.Error(), "9999_future") {
+		t.Fatalf("err = %v, want unknown version error", err)
+	}
+}
+
+// TestOrderStatesBackfill 确认 0008_order_states 为已有订单补齐状态时间与尝试次数，并把未知状态落为 FAILED。
+func TestOrderStatesBackfill(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	all, err := Load("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range all {
+		if m.Name == "order_states" {
+			break
+		}
+		if err := db.Exec(m.SQL).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&SchemaMigration{Version: m.Version, Name: m.Name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for venue, status := range []string{"PENDING", "SUCCESS", "BOOKED"} {
+		if err := db.Exec("INSERT INTO orders (date, hour, venue, status) VALUES ('2025-12-21', 15, ?, ?)", venue+1, status).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := Up(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	type row struct {
+		Status          string
+		Attempts        int
+		LastError       string
+		StatusChangedAt *string
+		SucceededAt     *string
+	}
+	var rows []row
+	if err := db.Raw("SELECT status, attempts, last_error, status_changed_at, succeeded_at FROM orders ORDER BY id").Scan(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Status != "PENDING" || rows[0].Attempts != 0 || rows[0].StatusChangedAt == nil {
+		t.Errorf("pending order = %+v", rows[0])
+	}
+	if rows[1].Status != "SUCCESS" || rows[1].Attempts != 1 || rows[1].SucceededAt == nil {
+		t.Errorf("succeeded order = %+v, want succeeded_at and 1 attempt", rows[1])
+	}
+	if rows[2].Status != "FAILED" || !strings.Contains(rows[2].LastError, "BOOKED") {
+		t.Errorf("unknown status order = %+v, want FAILED naming BOOKED", rows[2])
+	}
+}
@@ -0,0 +1,21 @@
+-- 初始表结构（与 sqlite/0001_init.sql 对应）
+
+-- 订单表: 存储场地预约订单（用户信息从 config.yaml 读取）
+CREATE TABLE IF NOT EXISTS orders (
+    id BIGSERIAL PRIMARY KEY,                  -- 订单ID（主键，自增）
+    date TEXT NOT NULL,                        -- 预约日期
+    hour INTEGER NOT NULL,                     -- 预约时段（小时，如15表示15:00-16:00）
+    venue INTEGER NOT NULL DEFAULT 4,          -- 场地编号
+    status TEXT NOT NULL DEFAULT 'PENDING',    -- 订单状态: PENDING-待处理, SUCCESS-成功, FAILED-失败
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 日志表: 存储应用日志和预约记录
+CREATE TABLE IF NOT EXISTS logs (
+    id BIGSERIAL PRIMARY KEY,
+    level TEXT NOT NULL,                       -- 日志级别: INFO, WARN, ERROR等
+    message TEXT NOT NULL,                     -- 日志消息内容
+    order_id BIGINT REFERENCES orders(id),     -- 关联订单ID（可为空）
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+-- 日志所属运行: 同一次运行产生的日志共享运行 ID
+ALTER TABLE logs ADD COLUMN run_id TEXT NOT NULL DEFAULT '';
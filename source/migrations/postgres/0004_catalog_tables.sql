@@ -0,0 +1,70 @@
+-- 目录快照相关表与约满时间表（与 sqlite/0004_catalog_tables.sql 对应）
+
+-- 目录快照表: 每次拉取表单目录时保存一份规范化快照
+CREATE TABLE IF NOT EXISTS catalog_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    form TEXT NOT NULL,                        -- 表单名称
+    form_version BIGINT NOT NULL,              -- 表单版本号
+    run_id TEXT NOT NULL DEFAULT '',           -- 运行ID
+    fetched_at TIMESTAMPTZ NOT NULL            -- 拉取时间
+);
+CREATE INDEX IF NOT EXISTS idx_catalog_snapshots_form ON catalog_snapshots(form);
+
+-- 快照场地表: 快照中的场地选项
+CREATE TABLE IF NOT EXISTS catalog_snapshot_venues (
+    id BIGSERIAL PRIMARY KEY,
+    snapshot_id BIGINT NOT NULL REFERENCES catalog_snapshots(id),
+    position BIGINT NOT NULL,                  -- 场地号（从 1 开始）
+    cid TEXT NOT NULL,                         -- 场地选项 ID
+    uuid TEXT NOT NULL,                        -- 场地 UUID
+    name TEXT NOT NULL                         -- 场地名称
+);
+CREATE INDEX IF NOT EXISTS idx_catalog_snapshot_venues_snapshot_id ON catalog_snapshot_venues(snapshot_id);
+
+-- 快照时段表: 快照中每个日期/时段/场地的容量与已预约数
+CREATE TABLE IF NOT EXISTS catalog_snapshot_slots (
+    id BIGSERIAL PRIMARY KEY,
+    snapshot_id BIGINT NOT NULL REFERENCES catalog_snapshots(id),
+    date TEXT NOT NULL,                        -- 日期
+    hour BIGINT NOT NULL,                      -- 时段（小时）
+    venue_uuid TEXT NOT NULL,                  -- 场地 UUID
+    capacity BIGINT NOT NULL,                  -- 容量（catalog 中的 limit）
+    used_count BIGINT NOT NULL                 -- 已预约数
+);
+CREATE INDEX IF NOT EXISTS idx_catalog_snapshot_slots_snapshot_id ON catalog_snapshot_slots(snapshot_id);
+
+-- 目录变化事件表: 相邻两次快照之间的差异，可用于告警
+CREATE TABLE IF NOT EXISTS catalog_events (
+    id BIGSERIAL PRIMARY KEY,
+    form TEXT NOT NULL,                        -- 表单名称
+    type TEXT NOT NULL,                        -- 事件类型: DATE_ADDED, SLOT_REMOVED, VENUE_RENAMED, CAPACITY_CHANGED 等
+    date TEXT,                                 -- 相关日期
+    hour BIGINT,                               -- 相关时段
+    venue TEXT,                                -- 相关场地 UUID
+    old_value TEXT,                            -- 旧值
+    new_value TEXT,                            -- 新值
+    message TEXT NOT NULL,                     -- 描述
+    from_snapshot_id BIGINT,                   -- 旧快照
+    to_snapshot_id BIGINT,                     -- 新快照
+    run_id TEXT NOT NULL DEFAULT '',           -- 运行ID
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_catalog_events_form ON catalog_events(form);
+
+-- 约满时间表: 开放预约后高频采样得到的各时段各场地约满时间
+CREATE TABLE IF NOT EXISTS slot_fills (
+    id BIGSERIAL PRIMARY KEY,
+    form TEXT NOT NULL,                        -- 表单名称
+    run_id TEXT NOT NULL DEFAULT '',           -- 运行ID（一次采样窗口）
+    date TEXT NOT NULL,                        -- 预约日期
+    weekday BIGINT NOT NULL,                   -- 星期（0 = 周日）
+    hour BIGINT NOT NULL,                      -- 时段（小时）
+    venue_uuid TEXT NOT NULL,                  -- 场地 UUID
+    venue_name TEXT NOT NULL,                  -- 场地名称
+    opened_at TIMESTAMPTZ NOT NULL,            -- 开放时刻
+    filled_at TIMESTAMPTZ,                     -- 首次观测到约满的时间（窗口内未约满为空）
+    fill_seconds DOUBLE PRECISION,             -- 开放后多少秒约满
+    samples BIGINT NOT NULL,                   -- 采样次数
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_slot_fills_form ON slot_fills(form);
@@ -0,0 +1,4 @@
+-- 订单认领: 处理前将订单置为 IN_PROGRESS 并记录认领者与租约到期时间，租约过期后可被重新认领
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS claimed_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_orders_lease ON orders(status, lease_expires_at);
@@ -0,0 +1,10 @@
+-- 单实例锁: 每个锁名一行，持有者定期刷新心跳；心跳超时或持有者已退出时可被接管
+CREATE TABLE IF NOT EXISTS process_locks (
+    name TEXT PRIMARY KEY,                     -- 锁名称（如 run）
+    owner TEXT NOT NULL,                       -- 持有者（主机名:PID:运行 ID）
+    host TEXT NOT NULL,                        -- 持有者主机名
+    pid INTEGER NOT NULL,                      -- 持有者进程号
+    run_id TEXT NOT NULL DEFAULT '',           -- 持有者运行 ID
+    started_at TIMESTAMPTZ NOT NULL,           -- 获得锁的时间
+    heartbeat_at TIMESTAMPTZ NOT NULL          -- 最近一次心跳
+);
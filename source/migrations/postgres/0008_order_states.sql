@@ -0,0 +1,25 @@
+-- 订单状态机: 新增 SCHEDULED、RETRYING、CANCELLED、EXPIRED 状态，各状态的进入时间、提交次数与最近错误
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0; -- 提交次数
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS last_error TEXT NOT NULL DEFAULT ''; -- 最近一次失败或中断的原因
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;      -- 最近一次状态变更时间
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS retrying_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS succeeded_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS expired_at TIMESTAMPTZ;
+
+-- 早期版本不校验状态，无法识别的状态按失败处理并保留原值
+UPDATE orders SET last_error = '迁移前的未知状态: ' || status, status = 'FAILED'
+    WHERE status NOT IN ('PENDING', 'IN_PROGRESS', 'SUCCESS', 'FAILED');
+
+-- 已有订单以 updated_at 作为进入当前状态的时间
+UPDATE orders SET status_changed_at = updated_at;
+UPDATE orders SET started_at = updated_at, attempts = 1 WHERE status = 'IN_PROGRESS';
+UPDATE orders SET succeeded_at = updated_at, attempts = 1 WHERE status = 'SUCCESS';
+UPDATE orders SET failed_at = updated_at, attempts = 1 WHERE status = 'FAILED';
+
+-- 取消的订单不占用时段，可以重新添加
+DROP INDEX IF EXISTS idx_orders_slot;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_slot ON orders(form, date, hour, venue) WHERE status <> 'CANCELLED';
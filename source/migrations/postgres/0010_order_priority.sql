@@ -0,0 +1,2 @@
+-- 订单优先级: 越大越先提交，超出账号预约上限时优先保留高优先级的订单
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- 订单最近错误的分类（见 common.ErrorClass），按分类判断过期订单未被提交的原因，不再匹配错误文本
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS error_class TEXT NOT NULL DEFAULT '';
+-- 回填迁移前仍未完成的订单（按 common.ErrFormUnavailable 与 common.ErrAccountLimit 的错误文本）
+UPDATE orders SET error_class = 'FORM_UNAVAILABLE' WHERE last_error LIKE '%表单不可预约%';
+UPDATE orders SET error_class = 'LIMIT' WHERE error_class = '' AND last_error LIKE '%超出账号预约额度%';
@@ -0,0 +1,2 @@
+-- 订单所属表单: 对应 config.yaml 中 forms 的键，为空使用默认表单
+ALTER TABLE `orders` ADD COLUMN `form` TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,72 @@
+-- 目录快照相关表与约满时间表（早期 init.sql 可能已建好，已有时不会改动）
+
+-- 目录快照表: 每次拉取表单目录时保存一份规范化快照
+CREATE TABLE IF NOT EXISTS `catalog_snapshots` (
+    `id` INTEGER PRIMARY KEY AUTOINCREMENT,   -- 快照ID（主键，自增）
+    `form` TEXT NOT NULL,                      -- 表单名称
+    `form_version` INTEGER NOT NULL,           -- 表单版本号
+    `run_id` TEXT NOT NULL DEFAULT '',         -- 运行ID
+    `fetched_at` DATETIME NOT NULL             -- 拉取时间
+);
+CREATE INDEX IF NOT EXISTS `idx_catalog_snapshots_form` ON `catalog_snapshots`(`form`);
+
+-- 快照场地表: 快照中的场地选项
+CREATE TABLE IF NOT EXISTS `catalog_snapshot_venues` (
+    `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+    `snapshot_id` INTEGER NOT NULL,            -- 所属快照
+    `position` INTEGER NOT NULL,               -- 场地号（从 1 开始）
+    `cid` TEXT NOT NULL,                       -- 场地选项 ID
+    `uuid` TEXT NOT NULL,                      -- 场地 UUID
+    `name` TEXT NOT NULL,                      -- 场地名称
+    FOREIGN KEY (`snapshot_id`) REFERENCES `catalog_snapshots`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_catalog_snapshot_venues_snapshot_id` ON `catalog_snapshot_venues`(`snapshot_id`);
+
+-- 快照时段表: 快照中每个日期/时段/场地的容量与已预约数
+CREATE TABLE IF NOT EXISTS `catalog_snapshot_slots` (
+    `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+    `snapshot_id` INTEGER NOT NULL,            -- 所属快照
+    `date` TEXT NOT NULL,                      -- 日期
+    `hour` INTEGER NOT NULL,                   -- 时段（小时）
+    `venue_uuid` TEXT NOT NULL,                -- 场地 UUID
+    `capacity` INTEGER NOT NULL,               -- 容量（catalog 中的 limit）
+    `used_count` INTEGER NOT NULL,             -- 已预约数
+    FOREIGN KEY (`snapshot_id`) REFERENCES `catalog_snapshots`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_catalog_snapshot_slots_snapshot_id` ON `catalog_snapshot_slots`(`snapshot_id`);
+
+-- 目录变化事件表: 相邻两次快照之间的差异，可用于告警
+CREATE TABLE IF NOT EXISTS `catalog_events` (
+    `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+    `form` TEXT NOT NULL,                      -- 表单名称
+    `type` TEXT NOT NULL,                      -- 事件类型: DATE_ADDED, SLOT_REMOVED, VENUE_RENAMED, CAPACITY_CHANGED 等
+    `date` TEXT,                               -- 相关日期
+    `hour` INTEGER,                            -- 相关时段
+    `venue` TEXT,                              -- 相关场地 UUID
+    `old_value` TEXT,                          -- 旧值
+    `new_value` TEXT,                          -- 新值
+    `message` TEXT NOT NULL,                   -- 描述
+    `from_snapshot_id` INTEGER,                -- 旧快照
+    `to_snapshot_id` INTEGER,                  -- 新快照
+    `run_id` TEXT NOT NULL DEFAULT '',         -- 运行ID
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `idx_catalog_events_form` ON `catalog_events`(`form`);
+
+-- 约满时间表: 开放预约后高频采样得到的各时段各场地约满时间
+CREATE TABLE IF NOT EXISTS `slot_fills` (
+    `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+    `form` TEXT NOT NULL,                      -- 表单名称
+    `run_id` TEXT NOT NULL DEFAULT '',         -- 运行ID（一次采样窗口）
+    `date` TEXT NOT NULL,                      -- 预约日期
+    `weekday` INTEGER NOT NULL,                -- 星期（0 = 周日）
+    `hour` INTEGER NOT NULL,                   -- 时段（小时）
+    `venue_uuid` TEXT NOT NULL,                -- 场地 UUID
+    `venue_name` TEXT NOT NULL,                -- 场地名称
+    `opened_at` DATETIME NOT NULL,             -- 开放时刻
+    `filled_at` DATETIME,                      -- 首次观测到约满的时间（窗口内未约满为空）
+    `fill_seconds` REAL,                       -- 开放后多少秒约满
+    `samples` INTEGER NOT NULL,                -- 采样次数
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `idx_slot_fills_form` ON `slot_fills`(`form`);
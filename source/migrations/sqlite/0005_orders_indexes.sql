@@ -0,0 +1,5 @@
+-- 订单索引: 同一表单的同一日期、时段、场地只能有一个订单；按状态与日期查询待处理订单
+-- 已有重复订单时本迁移会失败，请先删除重复行再执行 migrate up
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_orders_slot` ON `orders`(`form`, `date`, `hour`, `venue`);
+CREATE INDEX IF NOT EXISTS `idx_orders_status_date` ON `orders`(`status`, `date`);
+CREATE INDEX IF NOT EXISTS `idx_logs_order_id` ON `logs`(`order_id`);
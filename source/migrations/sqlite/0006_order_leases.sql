@@ -0,0 +1,4 @@
+-- 订单认领: 处理前将订单置为 IN_PROGRESS 并记录认领者与租约到期时间（UTC），租约过期后可被重新认领
+ALTER TABLE `orders` ADD COLUMN `claimed_by` TEXT NOT NULL DEFAULT '';
+ALTER TABLE `orders` ADD COLUMN `lease_expires_at` DATETIME;
+CREATE INDEX IF NOT EXISTS `idx_orders_lease` ON `orders`(`status`, `lease_expires_at`);
@@ -0,0 +1,17 @@
+-- 订单变更记录: 创建、修改与每次状态变更各一行，只追加不修改
+CREATE TABLE IF NOT EXISTS `order_events` (
+    `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+    `order_id` INTEGER NOT NULL,               -- 订单ID
+    `type` TEXT NOT NULL,                      -- 类型: CREATED, UPDATED, STATUS
+    `actor` TEXT NOT NULL,                     -- 执行变更的进程（主机名:PID:运行 ID）
+    `run_id` TEXT NOT NULL DEFAULT '',         -- 运行ID
+    `old_status` TEXT NOT NULL DEFAULT '',     -- 变更前状态
+    `new_status` TEXT NOT NULL DEFAULT '',     -- 变更后状态
+    `changes` TEXT NOT NULL DEFAULT '{}',      -- 变化的字段 {"列名": [旧值, 新值]}
+    `reason` TEXT NOT NULL DEFAULT '',         -- 变更原因
+    `response_status` INTEGER,                 -- 服务端 HTTP 状态码
+    `response_code` INTEGER,                   -- 服务端业务码
+    `response_message` TEXT NOT NULL DEFAULT '', -- 服务端消息
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS `idx_order_events_order_id` ON `order_events`(`order_id`);
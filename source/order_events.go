@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"sports_order/common"
+)
+
+// eventColumns 是记入变更事件的订单列；各状态时间与租约到期时间由事件时间体现，不重复记录。
+var eventColumns = []string{"date", "hour", "venue", "form", "priority", "status", "claimed_by", "attempts", "last_error", "error_class"}
+
+// orderColumn 返回订单某列的当前值。
+func orderColumn(order *common.Order, column string) any {
+	switch column {
+	case "date":
+		return order.Date
+	case "hour":
+		return order.Hour
+	case "venue":
+		return order.Venue
+	case "form":
+		return order.Form
+	case "priority":
+		return order.Priority
+	case "status":
+		return order.Status
+	case "claimed_by":
+		return order.ClaimedBy
+	case "attempts":
+		return order.Attempts
+	case "last_error":
+		return order.LastError
+	case "error_class":
+		return order.ErrorClass
+	}
+	return nil
+}
+
+// newOrderEvent 根据订单的当前值与将要写入的 updates 生成变更事件。
+// actor 为空时取当前进程（见 LeaseOwner）；cause 中的服务端响应一并记录。
+func newOrderEvent(ctx context.Context, actor string, order *common.Order, eventType common.OrderEventType, updates map[string]any, cause error) *common.OrderEvent {
+	if actor == "" {
+		actor = common.LeaseOwner(ctx)
+	}
+	changes := make(map[string][2]any)
+	for _, column := range eventColumns {
+		value, ok := updates[column]
+		if old := orderColumn(order, column); ok && value != old {
+			changes[column] = [2]any{old, value}
+		}
+	}
+	data, _ := json.Marshal(changes)
+
+	event := &common.OrderEvent{
+		OrderID:   order.ID,
+		Type:      string(eventType),
+		Actor:     actor,
+		RunID:     common.RunIDFrom(ctx),
+		OldStatus: order.Status,
+		NewStatus: order.Status,
+		Changes:   string(data),
+	}
+	if status, ok := updates["status"].(string); ok {
+		event.NewStatus = status
+	}
+	if cause != nil {
+		event.Reason = cause.Error()
+		recordResponse(event, cause)
+	}
+	return event
+}
+
+// recordResponse 从错误链中取出服务端响应（拒绝受理或非 200 响应）写入事件。
+func recordResponse(event *common.OrderEvent, cause error) {
+	var rejection *common.RejectionError
+	if errors.As(cause, &rejection) {
+		event.ResponseStatus, event.ResponseCode, event.ResponseMessage = &rejection.StatusCode, &rejection.Code, rejection.Message
+		return
+	}
+	if httpErr, ok := common.AsHTTPError(cause); ok {
+		body := httpErr.Body
+		if len(body) > common.MaxErrorBodyInMessage {
+			body = body[:common.MaxErrorBodyInMessage]
+		}
+		event.ResponseStatus, event.ResponseMessage = &httpErr.StatusCode, string(body)
+	}
+}
+
+// OrderEvents 按时间顺序返回订单的全部变更事件。
+func (r *Repository) OrderEvents(ctx context.Context, orderID uint) ([]*common.OrderEvent, error) {
+	var events []*common.OrderEvent
+	return events, r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id").Find(&events).Error
+}
+
+// OrderLogs 按时间顺序返回订单的日志。
+func (r *Repository) OrderLogs(ctx context.Context, orderID uint) ([]*common.Log, error) {
+	var logs []*common.Log
+	return logs, r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id").Find(&logs).Error
+}
+
+// RunEvents 按时间顺序返回某次运行产生的订单变更事件。
+func (r *Repository) RunEvents(ctx context.Context, runID string) ([]*common.OrderEvent, error) {
+	var events []*common.OrderEvent
+	return events, r.db.WithContext(ctx).Where("run_id = ?", runID).Order("id").Find(&events).Error
+}
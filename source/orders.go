@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sports_order/common"
+	"sports_order/service"
+)
+
+// ordersCommand 分发 orders 的子命令：list 查看订单，add / edit 新建与修改订单，
+// cancel 取消订单，resolve 核对提交结果未知（UNKNOWN）的订单，timeline 查看订单的变更记录，
+// expire 将日期已过的订单置为 EXPIRED。
+func ordersCommand(ctx context.Context, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: sports-order orders <list|add|edit|cancel|resolve|timeline|expire> [参数]")
+		return 2
+	}
+	switch args[0] {
+	case "list":
+		return ordersList(ctx, args[1:])
+	case "add":
+		return ordersAdd(ctx, args[1:])
+	case "edit":
+		return ordersEdit(ctx, args[1:])
+	case "cancel":
+		return ordersCancel(ctx, args[1:])
+	case "resolve":
+		return ordersResolve(ctx, args[1:])
+	case "timeline":
+		return ordersTimeline(ctx, args[1:])
+	case "expire":
+		return ordersExpire(ctx, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知的 orders 子命令: %s\n", args[0])
+		return 2
+	}
+}
+
+// ordersList 按日期、状态列出订单及其尝试次数、最近错误与状态变更时间。
+func ordersList(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("orders list")
+	date := fs.String("date", "", "只列出该预约日期 (YYYY-MM-DD) 的订单")
+	status := fs.String("status", "", "只列出该状态的订单，如 PENDING、FAILED")
+	fs.Parse(args)
+
+	filter := OrderFilter{Date: *date, Status: common.OrderStatus(*status)}
+	if filter.Status != "" && !filter.Status.Valid() {
+		fmt.Fprintf(os.Stderr, "未知的订单状态: %s，可选 %v\n", *status, common.OrderStatuses)
+		return 2
+	}
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	orders, err := app.repo.ListOrders(ctx, filter)
+	if err != nil {
+		log.Printf("查询订单失败: %v", err)
+		return 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t表单\t日期\t时段\t场地\t优先级\t状态\t尝试\t状态变更于\t最近错误")
+	for _, order := range orders {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d:00\t%d\t%d\t%s\t%d\t%s\t%s\n", order.ID, stringOr(order.Form, "-"),
+			order.Date, order.Hour, order.Venue, order.Priority, order.Status, order.Attempts, formatTime(order.StatusChangedAt), order.LastError)
+	}
+	w.Flush()
+	return 0
+}
+
+// slotFlags 是 add / edit 共用的订单字段参数。
+type slotFlags struct {
+	date, form            *string
+	hour, venue, priority *int
+}
+
+func newSlotFlags(fs *flag.FlagSet) *slotFlags {
+	return &slotFlags{
+		date:     fs.String("date", "", "预约日期 (YYYY-MM-DD)"),
+		hour:     fs.Int("hour", -1, "预约时段（小时，如 15 表示 15:00-16:00）"),
+		venue:    fs.Int("venue", 0, "场地编号"),
+		form:     fs.String("form", "", "表单名称，为空使用默认表单"),
+		priority: fs.Int("priority", 0, "优先级，越大越先提交；超出账号预约上限时优先保留"),
+	}
+}
+
+// slot 返回命令行中出现过的字段，并校验取值。
+func (f *slotFlags) slot(fs *flag.FlagSet, config *common.Config) (OrderSlot, error) {
+	var slot OrderSlot
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "date":
+			if _, parseErr := time.Parse("2006-01-02", *f.date); parseErr != nil {
+				err = fmt.Errorf("无效的日期: %s", *f.date)
+			}
+			slot.Date = f.date
+		case "hour":
+			if *f.hour < 0 || *f.hour > 23 {
+				err = fmt.Errorf("无效的时段: %d", *f.hour)
+			}
+			slot.Hour = f.hour
+		case "venue":
+			if *f.venue < 1 {
+				err = fmt.Errorf("无效的场地编号: %d", *f.venue)
+			}
+			slot.Venue = f.venue
+		case "form":
+			// 存储解析后的表单名称，不指定时为默认表单，同一表单在数据库中只有一种写法
+			form, formErr := config.Form(*f.form)
+			if formErr != nil {
+				err = formErr
+				return
+			}
+			slot.Form = &form.Name
+		case "priority":
+			slot.Priority = f.priority
+		}
+	})
+	return slot, err
+}
+
+// ordersAdd 新建一个 PENDING 订单。
+func ordersAdd(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("orders add")
+	flags := newSlotFlags(fs)
+	fs.Parse(args)
+	if *flags.date == "" || *flags.hour < 0 {
+		fmt.Fprintln(os.Stderr, "用法: sports-order orders add -date YYYY-MM-DD -hour H [-venue N] [-form 表单] [-priority P]")
+		return 2
+	}
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	slot, err := flags.slot(fs, app.config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if slot.Form == nil {
+		form, err := app.config.Form("")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v，请用 -form 指定表单\n", err)
+			return 2
+		}
+		slot.Form = &form.Name
+	}
+	order := &common.Order{Date: *slot.Date, Hour: *slot.Hour, Venue: common.DefaultVenue, Form: *slot.Form}
+	if slot.Venue != nil {
+		order.Venue = *slot.Venue
+	}
+	if slot.Priority != nil {
+		order.Priority = *slot.Priority
+	}
+	if err := app.repo.CreateOrder(ctx, order); err != nil {
+		log.Printf("新建订单失败（同一表单的同一时段场地只能有一个未取消的订单）: %v", err)
+		return 1
+	}
+	fmt.Printf("已新建订单 %d: %s %d:00-%d:00 场地 %d\n", order.ID, order.Date, order.Hour, order.Hour+1, order.Venue)
+	return 0
+}
+
+// ordersEdit 修改待处理订单的日期、时段、场地、表单或优先级，只修改命令行中给出的字段。
+func ordersEdit(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("orders edit")
+	flags := newSlotFlags(fs)
+	id, ok := parseOrderID(fs, args, "orders edit [-date D] [-hour H] [-venue N] [-form 表单] [-priority P]")
+	if !ok {
+		return 2
+	}
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	slot, err := flags.slot(fs, app.config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if err := app.repo.UpdateOrder(ctx, id, slot); err != nil {
+		log.Printf("修改订单失败: %v", err)
+		return 1
+	}
+	fmt.Printf("订单 %d 已修改\n", id)
+	return 0
+}
+
+// ordersCancel 取消尚未处理的订单（PENDING、RETRYING、UNKNOWN），正被某个进程处理的订单不能取消。
+func ordersCancel(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("orders cancel")
+	reason := fs.String("reason", "", "取消原因，记录在订单的 last_error")
+	id, ok := parseOrderID(fs, args, "orders cancel [-reason 原因]")
+	if !ok {
+		return 2
+	}
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	var cause error
+	if *reason != "" {
+		cause = errors.New(*reason)
+	}
+	if err := app.repo.TransitionOrder(ctx, id, "", common.OrderStatusCancelled, cause); err != nil {
+		log.Printf("取消订单失败: %v", err)
+		return 1
+	}
+	fmt.Printf("订单 %d 已取消\n", id)
+	return 0
+}
+
+// resolveResults 是 orders resolve -result 的取值及对应的订单状态。
+var resolveResults = map[string]common.OrderStatus{
+	"booked": common.OrderStatusSuccess,  // 已核对预约成功
+	"failed": common.OrderStatusFailed,   // 已核对服务端拒绝受理，不再提交
+	"retry":  common.OrderStatusRetrying, // 已核对未预约，下次运行重新提交
+}
+
+// ordersResolve 核对提交结果未知（UNKNOWN）的订单后记录结果：这类订单不会被自动认领，以免重复提交。
+func ordersResolve(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("orders resolve")
+	result := fs.String("result", "", "核对结果: booked（已预约成功）、failed（未受理，不再提交）、retry（未预约，重新提交）")
+	reason := fs.String("reason", "", "核对说明，记录在订单的 last_error")
+	id, ok := parseOrderID(fs, args, "orders resolve -result booked|failed|retry [-reason 说明]")
+	if !ok {
+		return 2
+	}
+	to, ok := resolveResults[*result]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "-result 应为 booked、failed 或 retry (%q)\n", *result)
+		return 2
+	}
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	order, err := app.repo.FindOrder(ctx, id)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	if order.Status != string(common.OrderStatusUnknown) {
+		log.Printf("订单 %d 为 %s，只有 %s 的订单需要核对", id, order.Status, common.OrderStatusUnknown)
+		return 1
+	}
+	var cause error
+	if *reason != "" {
+		cause = errors.New(*reason)
+	}
+	if err := app.repo.TransitionOrder(ctx, id, "", to, cause); err != nil {
+		log.Printf("记录核对结果失败: %v", err)
+		return 1
+	}
+	fmt.Printf("订单 %d 已核对为 %s\n", id, to)
+	return 0
+}
+
+// timelineEntry 是时间线上的一行：一条变更事件或一条日志。
+type timelineEntry struct {
+	At    time.Time          `json:"at"`
+	Event *common.OrderEvent `json:"event,omitempty"`
+	Log   *common.Log        `json:"log,omitempty"`
+}
+
+// ordersTimeline 按时间顺序输出订单的全部变更事件；-logs 时穿插该订单的日志。
+func ordersTimeline(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("orders timeline")
+	withLogs := fs.Bool("logs", false, "穿插输出该订单的日志")
+	jsonOutput := fs.Bool("json", false, "以 JSON 输出")
+	id, ok := parseOrderID(fs, args, "orders timeline [-logs] [-json]")
+	if !ok {
+		return 2
+	}
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	order, err := app.repo.FindOrder(ctx, id)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	events, err := app.repo.OrderEvents(ctx, id)
+	if err != nil {
+		log.Printf("查询订单变更记录失败: %v", err)
+		return 1
+	}
+	var entries []timelineEntry
+	for _, event := range events {
+		entries = append(entries, timelineEntry{At: event.CreatedAt, Event: event})
+	}
+	if *withLogs {
+		logs, err := app.repo.OrderLogs(ctx, id)
+		if err != nil {
+			log.Printf("查询订单日志失败: %v", err)
+			return 1
+		}
+		for _, entry := range logs {
+			entries = append(entries, timelineEntry{At: entry.CreatedAt, Log: entry})
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+	}
+
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(map[string]any{"order": order, "timeline": entries}, "", "  ")
+		fmt.Println(string(data))
+		return 0
+	}
+
+	fmt.Printf("订单 %d: [%s] %s %d:00-%d:00 场地 %d，当前 %s，尝试 %d 次\n", order.ID, stringOr(order.Form, "默认表单"),
+		order.Date, order.Hour, order.Hour+1, order.Venue, order.Status, order.Attempts)
+	if len(events) == 0 || events[0].Type != string(common.OrderEventCreated) {
+		fmt.Printf("创建于 %s（早于变更记录或由 SQL 直接插入，之前的变更无记录）\n", order.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "时间\t类型\t状态\t执行者\t变化\t原因 / 服务端响应")
+	for _, entry := range entries {
+		at := entry.At.Local().Format("2006-01-02 15:04:05.000")
+		if entry.Log != nil {
+			fmt.Fprintf(w, "%s\tLOG %s\t\t%s\t\t%s\n", at, entry.Log.Level, stringOr(entry.Log.RunID, "-"), entry.Log.Message)
+			continue
+		}
+		event := entry.Event
+		status := event.NewStatus
+		if event.OldStatus != "" && event.OldStatus != event.NewStatus {
+			status = event.OldStatus + " → " + event.NewStatus
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", at, event.Type, status, event.Actor, formatChanges(event.Changes), eventDetail(event))
+	}
+	w.Flush()
+	return 0
+}
+
+// ordersExpire 将预约日期已过、仍未完成的订单置为 EXPIRED 并输出原因；run 启动时会自动执行。
+// 指定 -interval 时常驻运行，每隔 interval 清理一次，直到收到 SIGINT/SIGTERM。
+func ordersExpire(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("orders expire")
+	interval := fs.Duration("interval", 0, "常驻运行时的清理间隔（如 1h），为 0 时只清理一次")
+	fs.Parse(args)
+	if *interval < 0 {
+		fmt.Fprintln(os.Stderr, "-interval 不能为负数")
+		return 2
+	}
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	sweeper := service.NewOrderSweeper(app.repo, app.config)
+	if *interval > 0 {
+		log.Printf("每 %v 清理一次过期订单，按 Ctrl+C 退出", *interval)
+		sweeper.Run(ctx, *interval, func(expired []service.ExpiredOrder, err error) {
+			printExpired(expired, err)
+		})
+		return 0
+	}
+	if !printExpired(sweeper.Sweep(ctx, "")) {
+		return 1
+	}
+	return 0
+}
+
+// printExpired 输出一次清理置为 EXPIRED 的订单及其原因，清理失败时返回 false。
+func printExpired(expired []service.ExpiredOrder, err error) bool {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t日期\t时段\t场地\t原因\t说明")
+	for _, e := range expired {
+		fmt.Fprintf(w, "%d\t%s\t%d:00\t%d\t%s\t%s\n", e.Order.ID, e.Order.Date, e.Order.Hour, e.Order.Venue, e.Reason, e.Detail)
+	}
+	w.Flush()
+	if err != nil {
+		log.Printf("%v", err)
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%d 个订单置为 EXPIRED\n", len(expired))
+	return true
+}
+
+// parseOrderID 解析参数，要求恰好一个位置参数（订单 ID）。
+func parseOrderID(fs *flag.FlagSet, args []string, usage string) (uint, bool) {
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "用法: sports-order %s <订单 ID>\n", usage)
+		return 0, false
+	}
+	id, err := strconv.ParseUint(fs.Arg(0), 10, 0)
+	if err != nil || id == 0 {
+		fmt.Fprintf(os.Stderr, "无效的订单 ID: %s\n", fs.Arg(0))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// formatChanges 把事件的 changes 输出为 "列 旧值→新值"，状态列已单独输出。
+func formatChanges(changes string) string {
+	var parsed map[string][2]any
+	if json.Unmarshal([]byte(changes), &parsed) != nil {
+		return changes
+	}
+	var parts []string
+	for column, values := range parsed {
+		if column == "status" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %v→%v", column, formatValue(values[0]), formatValue(values[1])))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func formatValue(v any) string {
+	if s, ok := v.(string); ok && s == "" {
+		return `""`
+	}
+	return fmt.Sprint(v)
+}
+
+// eventDetail 合并事件的原因与服务端响应。
+func eventDetail(event *common.OrderEvent) string {
+	detail := event.Reason
+	if event.ResponseStatus != nil {
+		response := fmt.Sprintf("HTTP %d", *event.ResponseStatus)
+		if event.ResponseCode != nil {
+			response += fmt.Sprintf(" code %d", *event.ResponseCode)
+		}
+		if event.ResponseMessage != "" {
+			response += ": " + event.ResponseMessage
+		}
+		detail = strings.TrimPrefix(detail+" ["+response+"]", " ")
+	}
+	return detail
+}
+
+// formatTime 以本地时间输出，nil 输出 "-"。
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sports_order/common"
+	"sports_order/service"
+	"sports_order/vcr"
+)
+
+// runReplay 即 run -replay：用 cassette 代替真实请求离线复现一次运行。订单从真实数据库复制到临时 SQLite
+// 数据库后处理，不获取单实例锁、不打开结果日志、不清理过期订单，真实数据库中的订单保持不变。
+func runReplay(ctx context.Context, source *app, replayPath, date, summary string) int {
+	result, err := replayRun(ctx, source, replayPath, date)
+	if result == nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	printRunSummary(summary, result, nil)
+	if err != nil {
+		log.Printf("处理订单失败: %v", err)
+	}
+	log.Printf("回放结果只写入临时数据库，真实订单未改动")
+	return runExitCode(result, err)
+}
+
+// replayRun 在临时数据库中按 cassette 处理订单并返回结果；未能开始处理时返回的结果为 nil。
+func replayRun(ctx context.Context, source *app, replayPath, date string) (*service.RunResult, error) {
+	cassette, err := vcr.Load(replayPath)
+	if err != nil {
+		return nil, err
+	}
+	orders, targetDate, err := replayOrders(ctx, source.repo, cassette, date)
+	if err != nil {
+		return nil, fmt.Errorf("准备回放订单失败: %v", err)
+	}
+
+	dir, err := os.MkdirTemp("", "sports-order-replay-")
+	if err != nil {
+		return nil, fmt.Errorf("创建回放数据库失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	config := *source.config
+	config.Database = common.DatabaseConfig{Path: filepath.Join(dir, "replay.db")}
+	db, err := InitDB(&config)
+	if err != nil {
+		return nil, fmt.Errorf("创建回放数据库失败: %v", err)
+	}
+	defer CloseDB(db)
+	if _, err := MigrateDB(ctx, db); err != nil {
+		return nil, fmt.Errorf("回放数据库迁移失败: %v", err)
+	}
+	repo := NewRepository(db)
+	if len(orders) > 0 {
+		if err := db.WithContext(ctx).Create(orders).Error; err != nil {
+			return nil, fmt.Errorf("复制订单到回放数据库失败: %v", err)
+		}
+	}
+
+	if config.Run.TimeoutSec > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(config.Run.TimeoutSec)*time.Second)
+		defer cancel()
+	}
+	apiClient, timings, closeClient, err := buildAPIClient(ctx, &config, repo, source.runID, replayPath)
+	if err != nil {
+		return nil, fmt.Errorf("初始化 API 客户端失败: %v", err)
+	}
+	defer closeClient()
+	defer logTimings(ctx, repo, timings)
+
+	return service.NewOrderProcessor(apiClient, repo, &config).ProcessOrdersForDate(ctx, targetDate)
+}
+
+// replayOrders 返回回放使用的订单副本与目标日期。cassette 所录运行认领过的订单恢复为该运行之前的状态，
+// 目标日期取这些订单的日期（-date 指定时以其为准）；数据库中没有该运行的记录时（如 cassette 来自其他环境），
+// 取目标日期的待处理订单。另复制录制之前已预约成功的订单，使账号额度的统计与当时一致。
+func replayOrders(ctx context.Context, repo *Repository, cassette *vcr.Cassette, date string) ([]*common.Order, string, error) {
+	events, err := repo.RunEvents(ctx, cassette.RunID)
+	if err != nil {
+		return nil, "", err
+	}
+	before := make(map[uint]string) // 订单 ID -> 该运行之前的状态
+	var ids []uint
+	for _, event := range events {
+		if _, seen := before[event.OrderID]; !seen && event.Type == string(common.OrderEventStatus) {
+			before[event.OrderID] = event.OldStatus
+			ids = append(ids, event.OrderID)
+		}
+	}
+
+	var orders []*common.Order
+	if len(ids) > 0 {
+		if err := repo.db.WithContext(ctx).Where("id IN ?", ids).Order("id").Find(&orders).Error; err != nil {
+			return nil, "", err
+		}
+		for _, order := range orders {
+			order.Status, order.ClaimedBy, order.LeaseExpiresAt = before[order.ID], "", nil
+		}
+		if date == "" {
+			date = orders[0].Date
+		}
+	} else {
+		if date == "" {
+			date = time.Now().AddDate(0, 0, common.DaysAhead).Format("2006-01-02")
+		}
+		query := repo.db.WithContext(ctx).Where("date = ? AND status IN ?", date,
+			[]common.OrderStatus{common.OrderStatusPending, common.OrderStatusRetrying})
+		if err := query.Order("id").Find(&orders).Error; err != nil {
+			return nil, "", err
+		}
+	}
+
+	var succeeded []*common.Order
+	query := repo.db.WithContext(ctx).Where("status = ?", common.OrderStatusSuccess)
+	if !cassette.CreatedAt.IsZero() {
+		query = query.Where("COALESCE(succeeded_at, updated_at) < ?", cassette.CreatedAt)
+	}
+	if len(ids) > 0 {
+		query = query.Where("id NOT IN ?", ids)
+	}
+	if err := query.Order("id").Find(&succeeded).Error; err != nil {
+		return nil, "", err
+	}
+	return append(orders, succeeded...), date, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"sports_order/common"
+	"sports_order/vcr"
+)
+
+// TestReplayLeavesDatabaseUntouched 回放只处理所录运行认领过的订单（恢复为运行前的状态），且在临时数据库中进行：
+// 真实数据库的订单、变更事件与日志不变，不获取锁、不创建结果日志、不清理过期订单。
+func TestReplayLeavesDatabaseUntouched(t *testing.T) {
+	ctx := context.Background()
+	a := newTestApp(t, "run-replay")
+	const date = "2025-12-22"
+	orders := []*common.Order{
+		{Date: date, Hour: 20, Venue: 1, Form: common.DefaultFormName, Status: string(common.OrderStatusSuccess)},
+		{Date: "2000-01-01", Hour: 20, Venue: 1, Form: common.DefaultFormName, Status: string(common.OrderStatusPending)},
+		{Date: date, Hour: 21, Venue: 1, Form: common.DefaultFormName, Status: string(common.OrderStatusPending)},
+	}
+	if err := a.db.Create(orders).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 录制的运行认领并预约成功了订单 1
+	var events []*common.OrderEvent
+	for _, step := range [][2]common.OrderStatus{
+		{common.OrderStatusPending, common.OrderStatusScheduled},
+		{common.OrderStatusScheduled, common.OrderStatusInProgress},
+		{common.OrderStatusInProgress, common.OrderStatusSuccess},
+	} {
+		events = append(events, &common.OrderEvent{OrderID: orders[0].ID, Type: string(common.OrderEventStatus), Actor: "vm:1:rec-1",
+			RunID: "rec-1", OldStatus: string(step[0]), NewStatus: string(step[1])})
+	}
+	if err := a.db.Create(events).Error; err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "rec-1.json")
+	if err := (&vcr.Cassette{RunID: "rec-1", CreatedAt: time.Now()}).Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := func() ([]common.Order, int64, int64) {
+		var rows []common.Order
+		var eventCount, logCount int64
+		if err := a.db.Order("id").Find(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		a.db.Model(&common.OrderEvent{}).Count(&eventCount)
+		a.db.Model(&common.Log{}).Count(&logCount)
+		return rows, eventCount, logCount
+	}
+	beforeOrders, beforeEvents, beforeLogs := snapshot()
+
+	result, err := replayRun(ctx, a, path, "")
+	if result == nil {
+		t.Fatalf("replayRun: %v", err)
+	}
+	if result.Date != date || len(result.Orders) != 1 || result.Orders[0].OrderID != orders[0].ID {
+		t.Errorf("replayed %s %+v, want only order %d of %s", result.Date, result.Orders, orders[0].ID, date)
+	}
+
+	afterOrders, afterEvents, afterLogs := snapshot()
+	if !reflect.DeepEqual(beforeOrders, afterOrders) {
+		t.Errorf("orders changed by replay:\nbefore %+v\nafter  %+v", beforeOrders, afterOrders)
+	}
+	if afterEvents != beforeEvents || afterLogs != beforeLogs {
+		t.Errorf("events %d -> %d, logs %d -> %d; want unchanged", beforeEvents, afterEvents, beforeLogs, afterLogs)
+	}
+	if _, err := os.Stat(a.config.Database.Path + ".journal"); !os.IsNotExist(err) {
+		t.Errorf("journal file created by replay: %v", err)
+	}
+	if holder, err := a.repo.processLockHolder(ctx, common.RunLockName); err != nil || holder != nil {
+		t.Errorf("lock holder = %+v, %v; want none", holder, err)
+	}
+}
@@ -1,40 +1,164 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"sports_order/common"
+	"sports_order/credentials"
+	"sports_order/migrations"
 
 	"gopkg.in/yaml.v3"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ============================================================================
 // 配置加载
 // ============================================================================
 
-// LoadConfig 从默认路径 config.yaml 加载配置
-func LoadConfig() (*common.Config, error) {
-	return LoadConfigFrom("config.yaml")
+// LoadConfigFrom 从指定路径加载配置，并校验各配置项（见 Config.Validate）。
+func LoadConfigFrom(path string) (*common.Config, error) {
+	config, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
-// LoadConfigFrom 从指定路径加载配置
-func LoadConfigFrom(path string) (*common.Config, error) {
+// readConfig 读取配置并合并加密凭据存储中的用户信息，不做校验。
+// 优先级：环境变量覆盖 > 凭据存储 > config.yaml。
+func readConfig(path string) (*common.Config, error) {
+	config, err := parseConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if config.Credentials.Path == "" {
+		return config, nil
+	}
+
+	creds, err := loadCredentials(config, filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	creds.ApplyTo(&config.User)
+	// 再应用一次环境变量覆盖，保证其优先于凭据存储
+	if _, err := config.ApplyEnvOverrides(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadCredentials 解密配置中指定的凭据存储。
+func loadCredentials(config *common.Config, baseDir string) (*credentials.Credentials, error) {
+	secret, err := credentialSecret(config.Credentials, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.Load(resolvePath(baseDir, config.Credentials.Path), secret)
+}
+
+// credentialSecret 读取凭据存储的密钥文件或口令。
+func credentialSecret(cfg common.CredentialsConfig, baseDir string) ([]byte, error) {
+	if cfg.KeyFile != "" {
+		data, err := os.ReadFile(resolvePath(baseDir, cfg.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("读取凭据密钥文件失败: %v", err)
+		}
+		return bytes.TrimSpace(data), nil
+	}
+
+	env := cfg.PassphraseEnv
+	if env == "" {
+		env = common.DefaultPassphraseEnv
+	}
+	passphrase := os.Getenv(env)
+	if passphrase == "" {
+		return nil, fmt.Errorf("凭据存储需要口令：请设置环境变量 %s 或配置 credentials.key_file", env)
+	}
+	return []byte(passphrase), nil
+}
+
+// resolvePath 将相对路径解析为基于配置文件目录的路径。
+func resolvePath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// parseConfig 解析配置文件（拒绝未知配置项），应用 SPORTS_ORDER_* 环境变量覆盖并解析 token 来源。
+func parseConfig(path string) (*common.Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 
 	var config common.Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true) // 拼错的配置项直接报错，而不是被静默忽略
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
 	}
 
+	if _, err := config.ApplyEnvOverrides(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := resolveToken(&config.User, filepath.Dir(path)); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
+// resolveToken 按 token、token_env、token_file 的顺序确定 token，三者最多配置一个
+// （环境变量 SPORTS_ORDER_USER_TOKEN 的覆盖优先于全部来源）。
+func resolveToken(user *common.User, baseDir string) error {
+	if _, ok := os.LookupEnv(common.EnvPrefix + "USER_TOKEN"); ok {
+		return nil
+	}
+
+	sources := 0
+	for _, value := range []string{user.Token, user.TokenEnv, user.TokenFile} {
+		if value != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return common.ValidationErrors{{Field: "user.token", Message: "token、token_env、token_file 只能配置其中一个"}}
+	}
+
+	switch {
+	case user.TokenEnv != "":
+		user.Token = os.Getenv(user.TokenEnv)
+		if user.Token == "" {
+			return common.ValidationErrors{{Field: "user.token_env", Message: fmt.Sprintf("环境变量 %s 为空", user.TokenEnv)}}
+		}
+	case user.TokenFile != "":
+		path := resolvePath(baseDir, user.TokenFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return common.ValidationErrors{{Field: "user.token_file", Message: err.Error()}}
+		}
+		user.Token = strings.TrimSpace(string(data))
+		if user.Token == "" {
+			return common.ValidationErrors{{Field: "user.token_file", Message: fmt.Sprintf("%s 为空", path)}}
+		}
+	}
+	return nil
+}
+
 // ============================================================================
 // 数据库仓储
 // ============================================================================
@@ -49,43 +173,559 @@ func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{db: db}
 }
 
-// FindOrdersByDate 查询指定日期的待处理订单。
-func (r *Repository) FindOrdersByDate(date string) ([]*common.Order, error) {
+// FindPendingOrders 查询全部待处理（PENDING、RETRYING）订单，按日期、时段排序。
+func (r *Repository) FindPendingOrders(ctx context.Context) ([]*common.Order, error) {
+	var orders []*common.Order
+	return orders, r.db.WithContext(ctx).
+		Where("status IN ?", []common.OrderStatus{common.OrderStatusPending, common.OrderStatusRetrying}).
+		Order("date, hour, id").Find(&orders).Error
+}
+
+// FindStaleOrders 查询预约日期早于 before、仍未进入终态的订单，按日期、时段排序。
+func (r *Repository) FindStaleOrders(ctx context.Context, before string) ([]*common.Order, error) {
+	var orders []*common.Order
+	return orders, r.db.WithContext(ctx).
+		Where("date < ? AND status NOT IN ?", before, common.TerminalStatuses()).
+		Order("date, hour, id").Find(&orders).Error
+}
+
+// CountSucceededOrders 按条件统计表单下预约成功（SUCCESS）的订单数。没有 succeeded_at 的早期订单按 updated_at 计算成功时间。
+func (r *Repository) CountSucceededOrders(ctx context.Context, filter common.SucceededFilter) (int64, error) {
+	query := r.db.WithContext(ctx).Model(&common.Order{}).Where("status = ?", common.OrderStatusSuccess)
+	if len(filter.Forms) > 0 {
+		query = query.Where("form IN ?", filter.Forms)
+	}
+	if filter.Date != "" {
+		query = query.Where("date = ?", filter.Date)
+	}
+	if filter.Venue != 0 {
+		query = query.Where("venue = ?", filter.Venue)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("COALESCE(succeeded_at, updated_at) >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("COALESCE(succeeded_at, updated_at) < ?", filter.Until)
+	}
+	var count int64
+	return count, query.Count(&count).Error
+}
+
+// OrderFilter 是 ListOrders 的筛选条件，空值表示不限。
+type OrderFilter struct {
+	Date   string
+	Status common.OrderStatus
+}
+
+// ListOrders 按条件查询订单，按日期、时段排序。
+func (r *Repository) ListOrders(ctx context.Context, filter OrderFilter) ([]*common.Order, error) {
+	query := r.db.WithContext(ctx)
+	if filter.Date != "" {
+		query = query.Where("date = ?", filter.Date)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	var orders []*common.Order
+	return orders, query.Order("date, hour, id").Find(&orders).Error
+}
+
+// FindOrder 按 ID 查询订单。
+func (r *Repository) FindOrder(ctx context.Context, id uint) (*common.Order, error) {
+	var order common.Order
+	if err := r.db.WithContext(ctx).First(&order, id).Error; err != nil {
+		return nil, fmt.Errorf("订单 %d: %w", id, err)
+	}
+	return &order, nil
+}
+
+// CreateOrder 新建订单并记录 CREATED 事件。
+func (r *Repository) CreateOrder(ctx context.Context, order *common.Order) error {
+	if order.Status == "" {
+		order.Status = string(common.OrderStatusPending)
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		event := newOrderEvent(ctx, "", &common.Order{}, common.OrderEventCreated, map[string]any{
+			"date": order.Date, "hour": order.Hour, "venue": order.Venue, "form": order.Form, "priority": order.Priority, "status": order.Status,
+		}, nil)
+		event.OrderID = order.ID
+		return tx.Create(event).Error
+	})
+}
+
+// OrderSlot 是 UpdateOrder 可修改的字段，nil 表示不修改。
+type OrderSlot struct {
+	Date     *string
+	Hour     *int
+	Venue    *int
+	Form     *string
+	Priority *int
+}
+
+// UpdateOrder 修改待处理订单（PENDING、RETRYING）的日期、时段、场地、表单或优先级，并记录 UPDATED 事件。
+func (r *Repository) UpdateOrder(ctx context.Context, id uint, slot OrderSlot) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		if status := common.OrderStatus(order.Status); !status.CanTransitionTo(common.OrderStatusScheduled) {
+			return fmt.Errorf("订单 %d 为 %s，只能修改待处理的订单", id, status)
+		}
+		updates := map[string]any{}
+		if slot.Date != nil {
+			updates["date"] = *slot.Date
+		}
+		if slot.Hour != nil {
+			updates["hour"] = *slot.Hour
+		}
+		if slot.Venue != nil {
+			updates["venue"] = *slot.Venue
+		}
+		if slot.Form != nil {
+			updates["form"] = *slot.Form
+		}
+		if slot.Priority != nil {
+			updates["priority"] = *slot.Priority
+		}
+		event := newOrderEvent(ctx, "", order, common.OrderEventUpdated, updates, nil)
+		if event.Changes == "{}" {
+			return nil
+		}
+		if err := tx.Model(order).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+}
+
+// FormNormalization 是 NormalizeOrderForms 的结果。
+type FormNormalization struct {
+	Normalized int    // 归入默认表单的订单数
+	Cancelled  []uint // 与默认表单下同一时段场地的订单重复而被取消的订单
+	Conflicts  []uint // 重复且两个订单都不能取消（已终态或正被处理），保持原样的订单
+}
+
+// NormalizeOrderForms 将 form 为空的订单归入默认表单 name，使同一表单在数据库中只有一种写法，
+// 唯一索引 idx_orders_slot 与按表单统计的预约额度才能覆盖这些订单。每个订单记录 UPDATED 事件；
+// 与默认表单下同一时段场地的未取消订单重复时，取消其中仍待处理的一个（优先取消 form 为空的）。
+func (r *Repository) NormalizeOrderForms(ctx context.Context, name string) (FormNormalization, error) {
+	var result FormNormalization
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		orders, err := lockOrders(tx, "form = ?", "")
+		if err != nil {
+			return err
+		}
+		for _, order := range orders {
+			updates := map[string]any{"form": name}
+			var duplicate common.Order
+			err := tx.Where("form = ? AND date = ? AND hour = ? AND venue = ? AND status <> ?",
+				name, order.Date, order.Hour, order.Venue, common.OrderStatusCancelled).First(&duplicate).Error
+			switch {
+			case order.Status == string(common.OrderStatusCancelled), errors.Is(err, gorm.ErrRecordNotFound):
+			case err != nil:
+				return err
+			case cancellable(order):
+				// 取消与归入默认表单在同一次变更中完成
+				cause := fmt.Errorf("与订单 %d 重复（表单 %s 的同一时段场地）", duplicate.ID, name)
+				if err := setStatus(ctx, tx, order, "", common.OrderStatusCancelled, updates, cause); err != nil {
+					return err
+				}
+				result.Cancelled = append(result.Cancelled, order.ID)
+				result.Normalized++
+				continue
+			case cancellable(&duplicate):
+				cause := fmt.Errorf("与订单 %d 重复（表单 %s 的同一时段场地）", order.ID, name)
+				if err := setStatus(ctx, tx, &duplicate, "", common.OrderStatusCancelled, map[string]any{}, cause); err != nil {
+					return err
+				}
+				result.Cancelled = append(result.Cancelled, duplicate.ID)
+			default:
+				result.Conflicts = append(result.Conflicts, order.ID)
+				continue
+			}
+
+			event := newOrderEvent(ctx, "", order, common.OrderEventUpdated, updates, nil)
+			if err := tx.Model(order).Updates(updates).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(event).Error; err != nil {
+				return err
+			}
+			result.Normalized++
+		}
+		return nil
+	})
+	return result, err
+}
+
+// cancellable 判断订单能否由手动操作取消：未被进程持有且状态机允许变为 CANCELLED。
+func cancellable(order *common.Order) bool {
+	status := common.OrderStatus(order.Status)
+	return !status.Leased() && status.CanTransitionTo(common.OrderStatusCancelled)
+}
+
+// lockOrder 在事务中读取订单并加行锁（PostgreSQL 为 FOR UPDATE；SQLite 的事务以 IMMEDIATE 开始，见 sqliteDSN）。
+func lockOrder(tx *gorm.DB, id uint) (*common.Order, error) {
+	var order common.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+		return nil, fmt.Errorf("订单 %d: %w", id, err)
+	}
+	return &order, nil
+}
+
+// lockOrders 在事务中按条件读取订单并加行锁。
+func lockOrders(tx *gorm.DB, query string, args ...any) ([]*common.Order, error) {
 	var orders []*common.Order
-	return orders, r.db.Where("date = ? AND status = ?", date, common.OrderStatusPending).Find(&orders).Error
+	return orders, tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(query, args...).Order("id").Find(&orders).Error
+}
+
+// statusUpdates 返回变更到 to 时需要写入的列：状态、状态变更时间与该状态的进入时间。
+func statusUpdates(to common.OrderStatus, now time.Time) map[string]any {
+	updates := map[string]any{"status": string(to), "status_changed_at": now}
+	if column := to.TimestampColumn(); column != "" {
+		updates[column] = now
+	}
+	return updates
+}
+
+// setStatus 在事务中把已加锁的订单变更为 to（updates 为额外写入的列），并记录 STATUS 事件。
+// 以 id 与原状态为条件更新，原状态已被修改时返回错误。
+func setStatus(ctx context.Context, tx *gorm.DB, order *common.Order, actor string, to common.OrderStatus, updates map[string]any, cause error) error {
+	for column, value := range statusUpdates(to, time.Now().UTC()) {
+		updates[column] = value
+	}
+	if cause != nil {
+		updates["last_error"] = cause.Error()
+		updates["error_class"] = string(common.ClassifyError(cause))
+	}
+	event := newOrderEvent(ctx, actor, order, common.OrderEventStatus, updates, cause)
+	result := tx.Model(&common.Order{}).
+		Where("id = ? AND status = ? AND claimed_by = ?", order.ID, order.Status, order.ClaimedBy).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("订单 %d 的状态被并发修改，请重试", order.ID)
+	}
+	return tx.Create(event).Error
+}
+
+// TransitionOrder 按状态机变更单个订单的状态，cause 非空时写入 last_error 及其分类 error_class，
+// 并与其中的服务端响应（RejectionError、HTTPError）一起记入变更事件。
+// owner 非空表示以认领者身份变更：订单已不属于 owner 时返回 ErrLeaseLost；
+// owner 为空表示手动操作（如取消），不能变更正被某个进程持有的订单。
+// 不允许的变更返回 *TransitionError。
+func (r *Repository) TransitionOrder(ctx context.Context, id uint, owner string, to common.OrderStatus, cause error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		from := common.OrderStatus(order.Status)
+		switch {
+		case owner != "" && (!from.Leased() || order.ClaimedBy != owner):
+			return fmt.Errorf("订单 %d: %w", id, common.ErrLeaseLost)
+		case owner == "" && from.Leased():
+			return fmt.Errorf("订单 %d 正由 %s 处理（%s），请稍后再试", id, order.ClaimedBy, from)
+		case !from.CanTransitionTo(to):
+			return &common.TransitionError{OrderID: id, From: from, To: to}
+		}
+
+		updates := map[string]any{}
+		if to == common.OrderStatusInProgress {
+			updates["attempts"] = order.Attempts + 1
+		}
+		if !to.Leased() {
+			updates["lease_expires_at"] = nil
+			if !to.Terminal() {
+				updates["claimed_by"] = "" // 退回待处理，保留终态订单的最后处理者
+			}
+		}
+		return setStatus(ctx, tx, order, owner, to, updates, cause)
+	})
+}
+
+// ReconcileOrder 将本地结果日志中的提交结果补写到订单，不受状态机约束：提交结果已确定，
+// 订单此前可能因租约过期被退回 UNKNOWN 或 PENDING。订单已是 to 时返回 false；
+// 已进入其他终态时返回 *TransitionError（保留数据库中的状态）；仍由其他认领者持有有效租约时返回 ErrLeaseLost。
+func (r *Repository) ReconcileOrder(ctx context.Context, id uint, owner string, to common.OrderStatus, cause error) (bool, error) {
+	changed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		from := common.OrderStatus(order.Status)
+		switch {
+		case from == to:
+			return nil
+		case from.Terminal():
+			return &common.TransitionError{OrderID: id, From: from, To: to}
+		case from.Leased() && order.ClaimedBy != owner && order.LeaseExpiresAt != nil && order.LeaseExpiresAt.After(time.Now()):
+			return fmt.Errorf("订单 %d 正由 %s 处理: %w", id, order.ClaimedBy, common.ErrLeaseLost)
+		}
+
+		updates := map[string]any{"lease_expires_at": nil, "claimed_by": owner}
+		if !to.Terminal() {
+			updates["claimed_by"] = ""
+		}
+		changed = true
+		return setStatus(ctx, tx, order, "", to, updates, cause)
+	})
+	return changed, err
 }
 
-// UpdateOrderStatus 更新订单状态。
-func (r *Repository) UpdateOrderStatus(id uint, status common.OrderStatus) error {
-	updates := map[string]any{
-		"status": string(status),
+// releaseClaims 退回已加锁的认领：未开始的（SCHEDULED）回到 PENDING，
+// 提交中的（IN_PROGRESS）结果未知，转为 UNKNOWN 并记录 cause，不会再被认领，以免重复提交。
+func releaseClaims(ctx context.Context, tx *gorm.DB, orders []*common.Order, cause error) error {
+	for _, order := range orders {
+		to, orderCause := common.OrderStatusPending, error(nil)
+		if order.Status == string(common.OrderStatusInProgress) {
+			to, orderCause = common.OrderStatusUnknown, cause
+		}
+		updates := map[string]any{"claimed_by": "", "lease_expires_at": nil}
+		if err := setStatus(ctx, tx, order, "", to, updates, orderCause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leasedStatuses 是带租约的状态。
+var leasedStatuses = []common.OrderStatus{common.OrderStatusScheduled, common.OrderStatusInProgress}
+
+// RecoverExpiredLeases 回收指定日期租约已过期的订单（认领者崩溃或失联）：
+// SCHEDULED 退回 PENDING，IN_PROGRESS 转为 UNKNOWN。返回回收前的订单以便记录原认领者。
+func (r *Repository) RecoverExpiredLeases(ctx context.Context, date string) ([]*common.Order, error) {
+	var recovered []*common.Order
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		orders, err := lockOrders(tx, "date = ? AND status IN ? AND lease_expires_at < ?", date, leasedStatuses, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		for _, order := range orders {
+			copied := *order
+			recovered = append(recovered, &copied)
+			cause := fmt.Errorf("认领者 %s 的租约过期，提交结果未知", order.ClaimedBy)
+			if err := releaseClaims(ctx, tx, []*common.Order{order}, cause); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return recovered, err
+}
+
+// ClaimOrders 原子地认领指定日期的全部 PENDING、RETRYING 订单（置为 SCHEDULED 并写入认领者与租约），
+// 返回本次认领到的订单。并发调用时每个订单只会被一个认领者拿到。
+func (r *Repository) ClaimOrders(ctx context.Context, date, owner string, lease time.Duration) ([]*common.Order, error) {
+	var claimed []*common.Order
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		orders, err := lockOrders(tx, "date = ? AND status IN ?", date, common.StatusesBefore(common.OrderStatusScheduled))
+		if err != nil {
+			return err
+		}
+		expiresAt := time.Now().UTC().Add(lease)
+		for _, order := range orders {
+			updates := map[string]any{"claimed_by": owner, "lease_expires_at": expiresAt}
+			if err := setStatus(ctx, tx, order, owner, common.OrderStatusScheduled, updates, nil); err != nil {
+				return err
+			}
+		}
+		return tx.Where("date = ? AND status = ? AND claimed_by = ?", date, common.OrderStatusScheduled, owner).
+			Order("id").Find(&claimed).Error
+	})
+	return claimed, err
+}
+
+// RenewLeases 为 owner 持有的全部订单续期，返回续期的订单数。续期不记录变更事件。
+func (r *Repository) RenewLeases(ctx context.Context, owner string, lease time.Duration) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&common.Order{}).
+		Where("status IN ? AND claimed_by = ?", leasedStatuses, owner).
+		Update("lease_expires_at", time.Now().UTC().Add(lease))
+	return result.RowsAffected, result.Error
+}
+
+// ReleaseOrders 退回 owner 仍持有的订单，返回退回的订单数。
+func (r *Repository) ReleaseOrders(ctx context.Context, owner string) (int64, error) {
+	var released int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		orders, err := lockOrders(tx, "status IN ? AND claimed_by = ?", leasedStatuses, owner)
+		if err != nil {
+			return err
+		}
+		released = int64(len(orders))
+		return releaseClaims(ctx, tx, orders, errors.New("运行结束时仍在提交中，结果未知"))
+	})
+	return released, err
+}
+
+// AcquireProcessLock 尝试获得单实例锁 lock（Name 与持有者信息由调用方填写，时间由本方法设置）。
+// 锁空闲时直接获得；已被持有且 takeover 判定可接管时按原持有者条件更新，避免两个进程同时接管。
+// 获得锁时返回 nil, nil，否则返回当前持有者。
+func (r *Repository) AcquireProcessLock(ctx context.Context, lock *common.ProcessLock, takeover func(holder *common.ProcessLock) bool) (*common.ProcessLock, error) {
+	db := r.db.WithContext(ctx)
+	now := time.Now().UTC()
+	lock.StartedAt, lock.HeartbeatAt = now, now
+
+	var holders []*common.ProcessLock
+	if err := db.Where("name = ?", lock.Name).Limit(1).Find(&holders).Error; err != nil {
+		return nil, err
+	}
+	if len(holders) == 0 {
+		if err := db.Create(lock).Error; err != nil {
+			// 并发插入时主键冲突，以胜出者为当前持有者
+			if holder, readErr := r.processLockHolder(ctx, lock.Name); readErr == nil && holder != nil {
+				return holder, nil
+			}
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	holder := holders[0]
+	if !takeover(holder) {
+		return holder, nil
+	}
+	result := db.Model(&common.ProcessLock{}).
+		Where("name = ? AND owner = ?", lock.Name, holder.Owner).
+		Updates(map[string]any{
+			"owner": lock.Owner, "host": lock.Host, "pid": lock.PID, "run_id": lock.RunID,
+			"started_at": lock.StartedAt, "heartbeat_at": lock.HeartbeatAt,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 被其他进程抢先接管
+		if holder, err := r.processLockHolder(ctx, lock.Name); err != nil || holder != nil {
+			return holder, err
+		}
+		return nil, fmt.Errorf("锁 %s 在接管时被释放，请重试", lock.Name)
 	}
-	return r.db.Model(&common.Order{}).Where("id = ?", id).Updates(updates).Error
+	return nil, nil
 }
 
-// CreateLog 写入一条日志记录。
-func (r *Repository) CreateLog(level common.LogLevel, message string, orderID *int) error {
+// processLockHolder 返回锁的当前持有者，锁空闲时返回 nil。
+func (r *Repository) processLockHolder(ctx context.Context, name string) (*common.ProcessLock, error) {
+	var holders []*common.ProcessLock
+	if err := r.db.WithContext(ctx).Where("name = ?", name).Limit(1).Find(&holders).Error; err != nil || len(holders) == 0 {
+		return nil, err
+	}
+	return holders[0], nil
+}
+
+// HeartbeatProcessLock 刷新锁的心跳；锁已不属于 owner 时返回 ErrLockLost。
+func (r *Repository) HeartbeatProcessLock(ctx context.Context, name, owner string) error {
+	result := r.db.WithContext(ctx).Model(&common.ProcessLock{}).
+		Where("name = ? AND owner = ?", name, owner).
+		Update("heartbeat_at", time.Now().UTC())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return common.ErrLockLost
+	}
+	return nil
+}
+
+// ReleaseProcessLock 释放 owner 持有的锁。
+func (r *Repository) ReleaseProcessLock(ctx context.Context, name, owner string) error {
+	return r.db.WithContext(ctx).Where("name = ? AND owner = ?", name, owner).Delete(&common.ProcessLock{}).Error
+}
+
+// SaveCatalogSnapshot 在一个事务中保存快照及其场地、时段明细。
+func (r *Repository) SaveCatalogSnapshot(ctx context.Context, snapshot *common.CatalogSnapshot) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Session(&gorm.Session{CreateBatchSize: 200}).Create(snapshot).Error
+	})
+}
+
+// LatestCatalogSnapshot 查询某表单最近一次快照（含明细），不存在时返回 nil。
+func (r *Repository) LatestCatalogSnapshot(ctx context.Context, form string) (*common.CatalogSnapshot, error) {
+	var snapshots []*common.CatalogSnapshot
+	err := r.db.WithContext(ctx).
+		Preload("Venues").Preload("Slots").
+		Where("form = ?", form).
+		Order("id DESC").Limit(1).
+		Find(&snapshots).Error
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	return snapshots[0], nil
+}
+
+// CatalogDates 返回某表单历次快照中出现过的全部日期，没有快照时返回空。
+func (r *Repository) CatalogDates(ctx context.Context, form string) ([]string, error) {
+	var dates []string
+	return dates, r.db.WithContext(ctx).Model(&common.CatalogSnapshotSlot{}).
+		Joins("JOIN catalog_snapshots ON catalog_snapshots.id = catalog_snapshot_slots.snapshot_id").
+		Where("catalog_snapshots.form = ?", form).
+		Distinct().Order("catalog_snapshot_slots.date").Pluck("catalog_snapshot_slots.date", &dates).Error
+}
+
+// CreateCatalogEvents 批量写入快照差异事件。
+func (r *Repository) CreateCatalogEvents(ctx context.Context, events []*common.CatalogEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.db.WithContext(context.WithoutCancel(ctx)).CreateInBatches(events, 200).Error
+}
+
+// SaveSlotFills 批量保存约满时间采样结果。
+func (r *Repository) SaveSlotFills(ctx context.Context, fills []*common.SlotFill) error {
+	if len(fills) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(fills, 200).Error
+}
+
+// ListSlotFills 查询某表单的全部约满时间采样结果。
+func (r *Repository) ListSlotFills(ctx context.Context, form string) ([]*common.SlotFill, error) {
+	var fills []*common.SlotFill
+	return fills, r.db.WithContext(ctx).Where("form = ?", form).Order("id").Find(&fills).Error
+}
+
+// CreateLog 写入一条日志记录（附带 ctx 中的运行 ID）。
+// 日志写入不随 ctx 取消，以便记录中断原因。
+func (r *Repository) CreateLog(ctx context.Context, level common.LogLevel, message string, orderID *int) error {
 	entry := &common.Log{
 		Level:   string(level),
 		Message: message,
 		OrderID: orderID,
+		RunID:   common.RunIDFrom(ctx),
 	}
-	return r.db.Create(entry).Error
+	return r.db.WithContext(context.WithoutCancel(ctx)).Create(entry).Error
 }
 
 // CreateLogf 写入格式化日志记录。
-func (r *Repository) CreateLogf(level common.LogLevel, orderID *int, format string, args ...any) error {
+func (r *Repository) CreateLogf(ctx context.Context, level common.LogLevel, orderID *int, format string, args ...any) error {
 	message := fmt.Sprintf(format, args...)
-	return r.CreateLog(level, message, orderID)
+	return r.CreateLog(ctx, level, message, orderID)
 }
 
 // ============================================================================
 // 数据库初始化
 // ============================================================================
 
-// InitDB 使用 GORM 打开 SQLite 数据库并返回连接。
+// InitDB 按 database.driver 使用 GORM 打开 SQLite 或 PostgreSQL 数据库并返回连接。
 func InitDB(config *common.Config) (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open(config.Database.Path), &gorm.Config{})
+	var dialector gorm.Dialector
+	switch driver := config.Database.DriverName(); driver {
+	case common.DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(config.Database.Path))
+	case common.DriverPostgres:
+		dialector = postgres.Open(config.Database.DSN)
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
@@ -93,6 +733,58 @@ func InitDB(config *common.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
+// sqliteDSN 为数据库文件追加 busy_timeout 参数，多个进程同时写入时等待而不是立即失败；
+// 事务以 BEGIN IMMEDIATE 开始，先读后写的事务（见 lockOrder）不会因锁升级失败而报错。
+func sqliteDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%s_busy_timeout=%d&_txlock=immediate", path, sep, common.SQLiteBusyTimeoutMs)
+}
+
+// MigrateDB 执行尚未执行的内嵌迁移，返回本次执行的迁移。
+func MigrateDB(ctx context.Context, db *gorm.DB) ([]migrations.Migration, error) {
+	return migrations.Up(ctx, db)
+}
+
+// schemaModels 是数据库中应存在的全部表对应的模型。
+var schemaModels = []any{
+	&common.Order{},
+	&common.OrderEvent{},
+	&common.Log{},
+	&common.CatalogSnapshot{},
+	&common.CatalogSnapshotVenue{},
+	&common.CatalogSnapshotSlot{},
+	&common.CatalogEvent{},
+	&common.SlotFill{},
+	&common.ProcessLock{},
+	&migrations.SchemaMigration{},
+}
+
+// CheckSchema 对照模型检查数据库中缺失的表与列，返回问题描述。
+func CheckSchema(db *gorm.DB) ([]string, error) {
+	var problems []string
+	migrator := db.Migrator()
+	for _, model := range schemaModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("解析模型失败: %v", err)
+		}
+		table := stmt.Schema.Table
+		if !migrator.HasTable(table) {
+			problems = append(problems, fmt.Sprintf("缺少表 %s", table))
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !migrator.HasColumn(model, field.DBName) {
+				problems = append(problems, fmt.Sprintf("表 %s 缺少列 %s", table, field.DBName))
+			}
+		}
+	}
+	return problems, nil
+}
+
 // CloseDB 关闭数据库连接。
 func CloseDB(db *gorm.DB) {
 	if db != nil {
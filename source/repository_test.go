@@ -0,0 +1,673 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sports_order/common"
+)
+
+// postgresDSNEnv 指定一个可用的 PostgreSQL 连接串，一致性测试会在其中为每个测试建立独立的 schema。
+// 未设置时尝试用 PATH 中的 initdb / pg_ctl 启动临时实例，都不可用时跳过 PostgreSQL。
+const postgresDSNEnv = "SPORTS_ORDER_TEST_POSTGRES_DSN"
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	localPostgres.stop()
+	os.Exit(code)
+}
+
+// testBackend 是一致性测试要覆盖的数据库后端。
+type testBackend struct {
+	name string
+	open func(t *testing.T) *common.DatabaseConfig
+}
+
+var testBackends = []testBackend{
+	{name: common.DriverSQLite, open: func(t *testing.T) *common.DatabaseConfig {
+		return &common.DatabaseConfig{Driver: common.DriverSQLite, Path: filepath.Join(t.TempDir(), "test.db")}
+	}},
+	{name: common.DriverPostgres, open: openPostgresSchema},
+}
+
+// openTestRepository 打开并迁移后端数据库。
+func openTestRepository(t *testing.T, backend testBackend) *Repository {
+	t.Helper()
+	dbConfig := backend.open(t)
+	db, err := InitDB(&common.Config{Database: *dbConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CloseDB(db) })
+	if _, err := MigrateDB(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	return NewRepository(db)
+}
+
+// forEachBackend 在每个可用后端上运行同一组断言。
+func forEachBackend(t *testing.T, fn func(t *testing.T, repo *Repository)) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			fn(t, openTestRepository(t, backend))
+		})
+	}
+}
+
+// TestMigrationsMatchModels 确认迁移建出的表结构覆盖全部 GORM 模型。
+func TestMigrationsMatchModels(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo *Repository) {
+		problems, err := CheckSchema(repo.db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, problem := range problems {
+			t.Error(problem)
+		}
+	})
+}
+
+func TestRepositoryOrders(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo *Repository) {
+		ctx := context.Background()
+		orders := []*common.Order{
+			{Date: "2025-12-22", Hour: 20, Venue: 1, Status: string(common.OrderStatusPending)},
+			{Date: "2025-12-21", Hour: 19, Venue: 2, Status: string(common.OrderStatusPending)},
+			{Date: "2025-12-21", Hour: 15, Venue: 2, Status: string(common.OrderStatusPending)},
+			{Date: "2025-12-21", Hour: 15, Venue: 3, Status: string(common.OrderStatusSuccess)},
+		}
+		if err := repo.db.Create(orders).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if err := repo.TransitionOrder(ctx, orders[1].ID, "", common.OrderStatusCancelled, errors.New("不需要了")); err != nil {
+			t.Fatal(err)
+		}
+		pending, err := repo.FindPendingOrders(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, order := range pending {
+			got = append(got, fmt.Sprintf("%s %d", order.Date, order.Hour))
+		}
+		if want := "2025-12-21 15,2025-12-22 20"; strings.Join(got, ",") != want {
+			t.Fatalf("FindPendingOrders = %v, want %s", got, want)
+		}
+
+		stale, err := repo.FindStaleOrders(ctx, "2025-12-22")
+		if err != nil || len(stale) != 1 || stale[0].ID != orders[2].ID {
+			t.Fatalf("FindStaleOrders = %v, %v; want only the pending order of 2025-12-21", stale, err)
+		}
+
+		duplicate := &common.Order{Date: "2025-12-22", Hour: 20, Venue: 1, Status: string(common.OrderStatusPending)}
+		if err := repo.db.Create(duplicate).Error; err == nil {
+			t.Fatal("duplicate (form, date, hour, venue) accepted")
+		}
+		otherForm := &common.Order{Date: "2025-12-22", Hour: 20, Venue: 1, Form: "tennis", Status: string(common.OrderStatusPending)}
+		if err := repo.db.Create(otherForm).Error; err != nil {
+			t.Fatalf("same slot on another form rejected: %v", err)
+		}
+		rebooked := &common.Order{Date: "2025-12-21", Hour: 19, Venue: 2, Status: string(common.OrderStatusPending)}
+		if err := repo.db.Create(rebooked).Error; err != nil {
+			t.Fatalf("slot of cancelled order rejected: %v", err)
+		}
+
+		now := time.Now()
+		for _, tt := range []struct {
+			filter common.SucceededFilter
+			want   int64
+		}{
+			{common.SucceededFilter{Date: "2025-12-21"}, 1},
+			{common.SucceededFilter{Date: "2025-12-22"}, 0},
+			{common.SucceededFilter{}, 1},
+			{common.SucceededFilter{Forms: []string{"tennis"}}, 0},
+			{common.SucceededFilter{Forms: []string{"badminton", ""}}, 1},
+			{common.SucceededFilter{Date: "2025-12-21", Venue: 3}, 1},
+			{common.SucceededFilter{Venue: 2}, 0},
+			{common.SucceededFilter{Since: now.Add(-time.Hour), Until: now.Add(time.Hour)}, 1},
+			{common.SucceededFilter{Since: now.Add(time.Hour)}, 0},
+			{common.SucceededFilter{Until: now.Add(-time.Hour)}, 0},
+		} {
+			if n, err := repo.CountSucceededOrders(ctx, tt.filter); err != nil || n != tt.want {
+				t.Errorf("CountSucceededOrders(%+v) = %d, %v; want %d", tt.filter, n, err, tt.want)
+			}
+		}
+
+		prioritized := &common.Order{Date: "2025-12-23", Hour: 20, Venue: 1, Priority: 3}
+		if err := repo.CreateOrder(ctx, prioritized); err != nil {
+			t.Fatal(err)
+		}
+		priority := 8
+		if err := repo.UpdateOrder(ctx, prioritized.ID, OrderSlot{Priority: &priority}); err != nil {
+			t.Fatal(err)
+		}
+		if found, err := repo.FindOrder(ctx, prioritized.ID); err != nil || found.Priority != priority {
+			t.Fatalf("FindOrder = %+v, %v; want priority %d", found, err, priority)
+		}
+	})
+}
+
+// TestNormalizeOrderForms 未指定表单的订单归入默认表单；与默认表单下同一时段场地重复时取消仍待处理的一个。
+func TestNormalizeOrderForms(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo *Repository) {
+		ctx := context.Background()
+		const form = "badminton"
+		orders := []*common.Order{
+			{Date: "2025-12-22", Hour: 20, Venue: 1, Status: string(common.OrderStatusPending)},
+			// 与 3 重复，取消 form 为空的这个
+			{Date: "2025-12-22", Hour: 21, Venue: 1, Status: string(common.OrderStatusPending)},
+			{Date: "2025-12-22", Hour: 21, Venue: 1, Form: form, Status: string(common.OrderStatusPending)},
+			// 与 5 重复，form 为空的已成功，取消 5
+			{Date: "2025-12-21", Hour: 20, Venue: 1, Status: string(common.OrderStatusSuccess)},
+			{Date: "2025-12-21", Hour: 20, Venue: 1, Form: form, Status: string(common.OrderStatusPending)},
+			// 与 7 重复且都已终态，保持原样
+			{Date: "2025-12-20", Hour: 20, Venue: 1, Status: string(common.OrderStatusSuccess)},
+			{Date: "2025-12-20", Hour: 20, Venue: 1, Form: form, Status: string(common.OrderStatusFailed)},
+		}
+		if err := repo.db.Create(orders).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := repo.NormalizeOrderForms(ctx, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Normalized != 3 || fmt.Sprint(result.Cancelled) != fmt.Sprint([]uint{orders[1].ID, orders[4].ID}) ||
+			fmt.Sprint(result.Conflicts) != fmt.Sprint([]uint{orders[5].ID}) {
+			t.Fatalf("result = %+v", result)
+		}
+		want := []struct {
+			form   string
+			status common.OrderStatus
+		}{
+			{form, common.OrderStatusPending},
+			{form, common.OrderStatusCancelled},
+			{form, common.OrderStatusPending},
+			{form, common.OrderStatusSuccess},
+			{form, common.OrderStatusCancelled},
+			{"", common.OrderStatusSuccess},
+			{form, common.OrderStatusFailed},
+		}
+		for i, order := range orders {
+			got, err := repo.FindOrder(ctx, order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Form != want[i].form || got.Status != string(want[i].status) {
+				t.Errorf("order %d = %q %s, want %q %s", got.ID, got.Form, got.Status, want[i].form, want[i].status)
+			}
+		}
+		events, err := repo.OrderEvents(ctx, orders[0].ID)
+		if err != nil || len(events) != 1 || events[0].Type != string(common.OrderEventUpdated) {
+			t.Errorf("events = %+v, %v; want one UPDATED event", events, err)
+		}
+
+		// 再次执行不再变更
+		if result, err := repo.NormalizeOrderForms(ctx, form); err != nil || result.Normalized != 0 || len(result.Cancelled) != 0 {
+			t.Errorf("second run = %+v, %v", result, err)
+		}
+	})
+}
+
+func TestRepositoryClaims(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo *Repository) {
+		ctx := context.Background()
+		const date = "2025-12-22"
+		var orders []*common.Order
+		for hour := 8; hour < 22; hour++ {
+			orders = append(orders, &common.Order{Date: date, Hour: hour, Venue: 1, Status: string(common.OrderStatusPending)})
+		}
+		if err := repo.db.Create(orders).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		// 多个认领者并发认领，每个订单只能被认领一次
+		const claimers = 4
+		claimed := make([][]*common.Order, claimers)
+		var wg sync.WaitGroup
+		for i := 0; i < claimers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var err error
+				if claimed[i], err = repo.ClaimOrders(ctx, date, fmt.Sprintf("owner-%d", i), time.Minute); err != nil {
+					t.Error(err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		seen := make(map[uint]string)
+		for i, batch := range claimed {
+			for _, order := range batch {
+				owner := fmt.Sprintf("owner-%d", i)
+				if prev, dup := seen[order.ID]; dup {
+					t.Fatalf("order %d claimed by both %s and %s", order.ID, prev, owner)
+				}
+				seen[order.ID] = owner
+				if order.ClaimedBy != owner || order.Status != string(common.OrderStatusScheduled) || order.LeaseExpiresAt == nil {
+					t.Fatalf("claimed order = %+v", order)
+				}
+			}
+		}
+		if len(seen) != len(orders) {
+			t.Fatalf("claimed %d orders, want %d", len(seen), len(orders))
+		}
+
+		// 只有认领者能变更状态
+		first := orders[0]
+		if err := repo.TransitionOrder(ctx, first.ID, "intruder", common.OrderStatusInProgress, nil); !errors.Is(err, common.ErrLeaseLost) {
+			t.Fatalf("TransitionOrder by non-owner = %v, want ErrLeaseLost", err)
+		}
+		for _, to := range []common.OrderStatus{common.OrderStatusInProgress, common.OrderStatusSuccess} {
+			if err := repo.TransitionOrder(ctx, first.ID, seen[first.ID], to, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// 租约过期后可被回收并重新认领；续期只影响自己的订单
+		owner := seen[orders[1].ID]
+		if _, err := repo.RenewLeases(ctx, owner, -time.Second); err != nil {
+			t.Fatal(err)
+		}
+		recovered, err := repo.RecoverExpiredLeases(ctx, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, order := range recovered {
+			if order.ClaimedBy != owner {
+				t.Fatalf("recovered order %d of %s, want only %s", order.ID, order.ClaimedBy, owner)
+			}
+		}
+		if len(recovered) == 0 {
+			t.Fatal("no expired lease recovered")
+		}
+		reclaimed, err := repo.ClaimOrders(ctx, date, "owner-new", time.Minute)
+		if err != nil || len(reclaimed) != len(recovered) {
+			t.Fatalf("reclaimed %d orders, %v; want %d", len(reclaimed), err, len(recovered))
+		}
+
+		// 释放后回到 PENDING
+		released, err := repo.ReleaseOrders(ctx, "owner-new")
+		if err != nil || released != int64(len(reclaimed)) {
+			t.Fatalf("ReleaseOrders = %d, %v; want %d", released, err, len(reclaimed))
+		}
+		pending, err := repo.ListOrders(ctx, OrderFilter{Date: date, Status: common.OrderStatusPending})
+		if err != nil || len(pending) != len(reclaimed) {
+			t.Fatalf("pending after release = %d, %v; want %d", len(pending), err, len(reclaimed))
+		}
+	})
+}
+
+func TestRepositoryTransitions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo *Repository) {
+		ctx := context.Background()
+		const date, owner = "2025-12-22", "owner-1"
+		order := &common.Order{Date: date, Hour: 20, Venue: 1}
+		if err := repo.CreateOrder(ctx, order); err != nil {
+			t.Fatal(err)
+		}
+		load := func() *common.Order {
+			t.Helper()
+			got, err := repo.FindOrder(ctx, order.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return got
+		}
+
+		// 未认领的订单不能直接提交
+		var transitionErr *common.TransitionError
+		if err := repo.TransitionOrder(ctx, order.ID, "", common.OrderStatusInProgress, nil); !errors.As(err, &transitionErr) {
+			t.Fatalf("PENDING -> IN_PROGRESS = %v, want TransitionError", err)
+		}
+
+		if _, err := repo.ClaimOrders(ctx, date, owner, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if got := load(); got.ScheduledAt == nil || got.StatusChangedAt == nil {
+			t.Fatalf("claimed order = %+v, want scheduled_at set", got)
+		}
+		if err := repo.TransitionOrder(ctx, order.ID, "", common.OrderStatusCancelled, nil); err == nil {
+			t.Fatal("cancelled an order held by another process")
+		}
+		if err := repo.TransitionOrder(ctx, order.ID, owner, common.OrderStatusInProgress, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		// 提交中的认领者失联：订单被回收后转为 UNKNOWN，不会被再次认领
+		if _, err := repo.RenewLeases(ctx, owner, -time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.RecoverExpiredLeases(ctx, date); err != nil {
+			t.Fatal(err)
+		}
+		got := load()
+		if got.Status != string(common.OrderStatusUnknown) || got.ClaimedBy != "" || got.LastError == "" {
+			t.Fatalf("recovered order = %+v, want UNKNOWN with last_error", got)
+		}
+		if claimed, err := repo.ClaimOrders(ctx, date, "owner-2", time.Minute); err != nil || len(claimed) != 0 {
+			t.Fatalf("ClaimOrders after recovery = %v, %v; want nothing claimed", claimed, err)
+		}
+		if got := load(); got.Status != string(common.OrderStatusUnknown) {
+			t.Fatalf("order = %s after claim, want UNKNOWN", got.Status)
+		}
+
+		// 核对确认未预约后重新提交，再次认领时尝试次数累加
+		if err := repo.TransitionOrder(ctx, order.ID, "", common.OrderStatusRetrying, errors.New("核对未预约")); err != nil {
+			t.Fatal(err)
+		}
+		if got := load(); got.RetryingAt == nil {
+			t.Fatalf("resolved order = %+v, want retrying_at set", got)
+		}
+		if _, err := repo.ClaimOrders(ctx, date, owner, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.TransitionOrder(ctx, order.ID, owner, common.OrderStatusInProgress, nil); err != nil {
+			t.Fatal(err)
+		}
+		rejection := &common.RejectionError{StatusCode: http.StatusOK, Code: 1, Message: "场地已满"}
+		if err := repo.TransitionOrder(ctx, order.ID, owner, common.OrderStatusFailed, rejection); err != nil {
+			t.Fatal(err)
+		}
+		got = load()
+		if got.Status != string(common.OrderStatusFailed) || got.Attempts != 2 || got.LastError != rejection.Error() ||
+			got.ErrorClass != string(common.ErrorClassRejected) || got.StartedAt == nil || got.FailedAt == nil || got.LeaseExpiresAt != nil {
+			t.Fatalf("failed order = %+v, want FAILED after 2 attempts", got)
+		}
+
+		// 终态不能再变更
+		if err := repo.TransitionOrder(ctx, order.ID, "", common.OrderStatusCancelled, nil); !errors.As(err, &transitionErr) {
+			t.Fatalf("FAILED -> CANCELLED = %v, want TransitionError", err)
+		}
+
+		// 每次创建与状态变更各有一条事件，被拒绝的变更不留记录
+		events, err := repo.OrderEvents(ctx, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var statuses []string
+		for _, event := range events {
+			statuses = append(statuses, event.NewStatus)
+		}
+		if want := "PENDING,SCHEDULED,IN_PROGRESS,UNKNOWN,RETRYING,SCHEDULED,IN_PROGRESS,FAILED"; strings.Join(statuses, ",") != want {
+			t.Fatalf("event statuses = %v, want %s", statuses, want)
+		}
+		if events[0].Type != string(common.OrderEventCreated) || events[1].Actor != owner {
+			t.Errorf("first events = %+v, %+v", events[0], events[1])
+		}
+		if recovery := events[3]; recovery.OldStatus != "IN_PROGRESS" || !strings.Contains(recovery.Reason, owner) {
+			t.Errorf("recovery event = %+v, want reason naming %s", recovery, owner)
+		}
+		failed := events[len(events)-1]
+		if failed.ResponseCode == nil || *failed.ResponseCode != 1 || failed.ResponseMessage != "场地已满" ||
+			!strings.Contains(failed.Changes, `"last_error"`) {
+			t.Errorf("failed event = %+v, want server response and last_error change", failed)
+		}
+	})
+}
+
+func TestRepositoryReconcile(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo *Repository) {
+		ctx := context.Background()
+		const date, owner = "2025-12-22", "owner-1"
+		orders := []*common.Order{{Date: date, Hour: 19, Venue: 1}, {Date: date, Hour: 20, Venue: 1}}
+		for _, order := range orders {
+			if err := repo.CreateOrder(ctx, order); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := repo.ClaimOrders(ctx, date, owner, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		for _, order := range orders {
+			if err := repo.TransitionOrder(ctx, order.ID, owner, common.OrderStatusInProgress, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// 其他认领者仍持有有效租约时不补写
+		if _, err := repo.ReconcileOrder(ctx, orders[0].ID, "owner-2", common.OrderStatusSuccess, nil); !errors.Is(err, common.ErrLeaseLost) {
+			t.Fatalf("reconcile leased order = %v, want ErrLeaseLost", err)
+		}
+
+		// 运行结束时被退回 UNKNOWN 的订单按结果日志补写为 SUCCESS
+		if _, err := repo.ReleaseOrders(ctx, owner); err != nil {
+			t.Fatal(err)
+		}
+		changed, err := repo.ReconcileOrder(ctx, orders[0].ID, owner, common.OrderStatusSuccess, nil)
+		if err != nil || !changed {
+			t.Fatalf("reconcile = %v, %v; want changed", changed, err)
+		}
+		got, err := repo.FindOrder(ctx, orders[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != string(common.OrderStatusSuccess) || got.ClaimedBy != owner || got.SucceededAt == nil || got.Attempts != 1 {
+			t.Fatalf("reconciled order = %+v, want SUCCESS by %s", got, owner)
+		}
+		if changed, err := repo.ReconcileOrder(ctx, orders[0].ID, owner, common.OrderStatusSuccess, nil); err != nil || changed {
+			t.Fatalf("second reconcile = %v, %v; want unchanged", changed, err)
+		}
+
+		// 已手动取消的订单保留数据库中的状态
+		if err := repo.TransitionOrder(ctx, orders[1].ID, "", common.OrderStatusCancelled, nil); err != nil {
+			t.Fatal(err)
+		}
+		var transitionErr *common.TransitionError
+		if _, err := repo.ReconcileOrder(ctx, orders[1].ID, owner, common.OrderStatusFailed, errors.New("场地已满")); !errors.As(err, &transitionErr) {
+			t.Fatalf("reconcile cancelled order = %v, want TransitionError", err)
+		}
+	})
+}
+
+func TestRepositorySnapshots(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo *Repository) {
+		ctx := context.Background()
+		if latest, err := repo.LatestCatalogSnapshot(ctx, "badminton"); err != nil || latest != nil {
+			t.Fatalf("LatestCatalogSnapshot on empty db = %v, %v", latest, err)
+		}
+
+		fetchedAt := time.Date(2025, 12, 20, 8, 0, 0, 0, time.UTC)
+		for version := 245; version <= 246; version++ {
+			snapshot := &common.CatalogSnapshot{
+				Form: "badminton", FormVersion: version, FetchedAt: fetchedAt,
+				Venues: []common.CatalogSnapshotVenue{{Position: 1, Cid: "c1", UUID: "u1", Name: "1号场"}},
+				Slots: []common.CatalogSnapshotSlot{
+					{Date: "2025-12-22", Hour: 15, VenueUUID: "u1", Capacity: 1},
+					{Date: "2025-12-22", Hour: 16, VenueUUID: "u1", Capacity: 1, UsedCount: 1},
+				},
+			}
+			if err := repo.SaveCatalogSnapshot(ctx, snapshot); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		dates, err := repo.CatalogDates(ctx, "badminton")
+		if err != nil || strings.Join(dates, ",") != "2025-12-22" {
+			t.Fatalf("CatalogDates = %v, %v; want [2025-12-22]", dates, err)
+		}
+		if dates, err := repo.CatalogDates(ctx, "tennis"); err != nil || len(dates) != 0 {
+			t.Fatalf("CatalogDates of form without snapshots = %v, %v", dates, err)
+		}
+
+		latest, err := repo.LatestCatalogSnapshot(ctx, "badminton")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest.FormVersion != 246 || len(latest.Venues) != 1 || len(latest.Slots) != 2 {
+			t.Fatalf("latest = version %d, %d venues, %d slots", latest.FormVersion, len(latest.Venues), len(latest.Slots))
+		}
+		if !latest.FetchedAt.Equal(fetchedAt) {
+			t.Errorf("FetchedAt = %v, want %v", latest.FetchedAt, fetchedAt)
+		}
+
+		hour := 15
+		events := []*common.CatalogEvent{{Form: "badminton", Type: "CAPACITY_CHANGED", Date: "2025-12-22", Hour: &hour, Message: "容量变化"}}
+		if err := repo.CreateCatalogEvents(ctx, events); err != nil {
+			t.Fatal(err)
+		}
+		if events[0].ID == 0 {
+			t.Error("event ID not assigned")
+		}
+	})
+}
+
+func TestRepositorySlotFillsAndLogs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo *Repository) {
+		ctx := common.WithRunID(context.Background(), "run-1")
+		openedAt := time.Date(2025, 12, 20, 8, 0, 0, 0, time.UTC)
+		seconds := 12.5
+		fills := []*common.SlotFill{
+			{Form: "badminton", Date: "2025-12-22", Hour: 20, VenueUUID: "u1", VenueName: "1号场", OpenedAt: openedAt, FillSeconds: &seconds, Samples: 30},
+			{Form: "badminton", Date: "2025-12-22", Hour: 21, VenueUUID: "u1", VenueName: "1号场", OpenedAt: openedAt, Samples: 30},
+			{Form: "tennis", Date: "2025-12-22", Hour: 20, VenueUUID: "u9", VenueName: "9号场", OpenedAt: openedAt, Samples: 30},
+		}
+		if err := repo.SaveSlotFills(ctx, fills); err != nil {
+			t.Fatal(err)
+		}
+		got, err := repo.ListSlotFills(ctx, "badminton")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0].FillSeconds == nil || *got[0].FillSeconds != seconds || got[1].FillSeconds != nil {
+			t.Fatalf("ListSlotFills = %+v", got)
+		}
+
+		order := &common.Order{Date: "2025-12-22", Hour: 20, Venue: 1, Status: string(common.OrderStatusPending)}
+		if err := repo.db.Create(order).Error; err != nil {
+			t.Fatal(err)
+		}
+		orderID := int(order.ID)
+		if err := repo.CreateLogf(ctx, common.LogLevelInfo, &orderID, "预约成功: %s", order.Date); err != nil {
+			t.Fatal(err)
+		}
+		var logs []common.Log
+		if err := repo.db.Find(&logs).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(logs) != 1 || logs[0].RunID != "run-1" || logs[0].OrderID == nil || *logs[0].OrderID != orderID {
+			t.Fatalf("logs = %+v", logs)
+		}
+	})
+}
+
+// openPostgresSchema 在可用的 PostgreSQL 中创建独立 schema，测试结束时删除。
+func openPostgresSchema(t *testing.T) *common.DatabaseConfig {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		var err error
+		if dsn, err = localPostgres.start(); err != nil {
+			t.Skipf("PostgreSQL 不可用（设置 %s 或安装 initdb/pg_ctl）: %v", postgresDSNEnv, err)
+		}
+	}
+
+	admin, err := InitDB(&common.Config{Database: common.DatabaseConfig{Driver: common.DriverPostgres, DSN: dsn}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 6)
+	rand.Read(buf)
+	schema := "test_" + hex.EncodeToString(buf)
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		CloseDB(admin)
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		CloseDB(admin)
+	})
+	return &common.DatabaseConfig{Driver: common.DriverPostgres, DSN: withSearchPath(dsn, schema)}
+}
+
+// withSearchPath 为连接串追加 search_path，兼容 URL 与 key=value 两种形式。
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if strings.Contains(dsn, "?") {
+			return dsn + "&search_path=" + schema
+		}
+		return dsn + "?search_path=" + schema
+	}
+	return dsn + " search_path=" + schema
+}
+
+// localPostgres 是按需启动的临时 PostgreSQL 实例，所有测试共享，TestMain 结束时停止。
+var localPostgres = &tempPostgres{}
+
+type tempPostgres struct {
+	once sync.Once
+	dir  string
+	dsn  string
+	err  error
+}
+
+func (p *tempPostgres) start() (string, error) {
+	p.once.Do(func() {
+		initdb, err := exec.LookPath("initdb")
+		if err != nil {
+			p.err = err
+			return
+		}
+		pgCtl, err := exec.LookPath("pg_ctl")
+		if err != nil {
+			p.err = err
+			return
+		}
+		// unix socket 路径长度有限，不使用 t.TempDir
+		if p.dir, err = os.MkdirTemp("", "pg"); err != nil {
+			p.err = err
+			return
+		}
+		port, err := freePort()
+		if err != nil {
+			p.err = err
+			return
+		}
+
+		data := filepath.Join(p.dir, "data")
+		if out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "--auth=trust").CombinedOutput(); err != nil {
+			p.err = fmt.Errorf("initdb: %v: %s", err, out)
+			return
+		}
+		opts := fmt.Sprintf("-k %s -p %d -c listen_addresses=''", p.dir, port)
+		if out, err := exec.Command(pgCtl, "-D", data, "-o", opts, "-l", filepath.Join(p.dir, "log"), "-w", "start").CombinedOutput(); err != nil {
+			p.err = fmt.Errorf("pg_ctl start: %v: %s", err, out)
+			return
+		}
+		p.dsn = fmt.Sprintf("host=%s port=%d user=postgres dbname=postgres sslmode=disable", p.dir, port)
+	})
+	return p.dsn, p.err
+}
+
+func (p *tempPostgres) stop() {
+	if p.dir == "" {
+		return
+	}
+	if p.dsn != "" {
+		exec.Command("pg_ctl", "-D", filepath.Join(p.dir, "data"), "-m", "immediate", "-w", "stop").Run()
+	}
+	os.RemoveAll(p.dir)
+}
+
+// freePort 返回一个当前空闲的本地端口（PostgreSQL 用它命名 unix socket）。
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
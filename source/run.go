@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sports_order/common"
+	"sports_order/journal"
+	"sports_order/middleware"
+	"sports_order/service"
+	"sports_order/vcr"
+)
+
+// runCommand 使用依赖注入组装各层依赖，并处理目标日期的待预约订单，结束时在标准输出打印各订单的结果。
+// 退出码：0 全部成功（或没有订单），4 部分成功，5 全部失败，1 未能提交（配置、数据库、锁、表单不可用、被中断等）。
+func runCommand(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("run")
+	dateFlag := fs.String("date", "", "目标日期 (YYYY-MM-DD)，默认今天 + 2 天")
+	replayPath := fs.String("replay", "", "回放指定的 cassette 文件代替真实请求（离线复现，在临时数据库中进行，不改动真实订单）")
+	wait := fs.Duration("wait", 0, "已有实例在运行时最长等待多久（如 30s），0 表示立即退出")
+	summary := fs.String("summary", "table", "结果输出格式：table、json 或 none")
+	fs.Parse(args)
+	if *summary != "table" && *summary != "json" && *summary != "none" {
+		log.Printf("未知的 -summary 格式: %s", *summary)
+		return 2
+	}
+
+	// 加载配置并初始化数据库
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	// 回放在临时数据库中进行，不获取锁、不补写结果日志、不清理过期订单，也无需完整的用户信息
+	if *replayPath != "" {
+		return runReplay(ctx, app, *replayPath, *dateFlag, *summary)
+	}
+	if err := app.config.ValidateForBooking(); err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+
+	// 同一数据库同时只允许一个实例处理订单；锁被接管时停止发起新的预约
+	ctx, cancelRun := context.WithCancelCause(ctx)
+	defer cancelRun(nil)
+	lock, err := acquireInstanceLock(ctx, app, *wait, cancelRun)
+	if err != nil {
+		var held *lockHeldError
+		if errors.As(err, &held) {
+			log.Printf("%v；如需排队请使用 -wait", err)
+		} else {
+			log.Printf("%v", err)
+		}
+		return 1
+	}
+	defer lock.Release(ctx)
+
+	// 单次运行时限
+	if app.config.Run.TimeoutSec > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(app.config.Run.TimeoutSec)*time.Second)
+		defer cancel()
+	}
+
+	repo := app.repo
+
+	// 记录启动日志
+	repo.CreateLogf(ctx, common.LogLevelInfo, nil, "应用启动，运行 ID: %s", app.runID)
+
+	// 初始化服务层
+	apiClient, timings, closeClient, err := buildAPIClient(ctx, app.config, repo, app.runID, "")
+	if err != nil {
+		log.Printf("初始化 API 客户端失败: %v", err)
+		return 1
+	}
+	defer closeClient()
+	defer logTimings(ctx, repo, timings)
+	orderProcessor := service.NewOrderProcessor(apiClient, repo, app.config)
+
+	// 先补写上次运行未能写入数据库的提交结果；补写失败时不处理订单，避免重复预约
+	outcomes, err := openJournal(ctx, app)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer outcomes.Close()
+	orderProcessor.SetJournal(outcomes)
+
+	// 计算目标日期
+	targetDate := time.Now().AddDate(0, 0, common.DaysAhead).Format("2006-01-02")
+	if *dateFlag != "" {
+		targetDate = *dateFlag
+	}
+
+	// 预约日期已过的订单置为 EXPIRED（目标日期除外，-date 可能指定过去的日期）
+	expired, err := service.NewOrderSweeper(repo, app.config).Sweep(ctx, targetDate)
+	if err != nil {
+		repo.CreateLogf(ctx, common.LogLevelError, nil, "清理过期订单失败: %v", err)
+	}
+	defer reportExpired(expired)
+
+	// 处理目标日期的订单
+	result, err := orderProcessor.ProcessOrdersForDate(ctx, targetDate)
+	printRunSummary(*summary, result, expired)
+	if err != nil {
+		if errors.Is(err, common.ErrFormUnavailable) || errors.Is(err, common.ErrImageExpired) {
+			// 输出到 stderr，cron 会据此发送邮件提醒
+			log.Printf("未发起预约，相关订单保持 PENDING: %v", err)
+		} else {
+			repo.CreateLogf(ctx, common.LogLevelError, nil, "处理订单失败: %v", err)
+			log.Printf("处理订单失败: %v", err)
+		}
+	}
+
+	// 记录完成日志
+	repo.CreateLogf(ctx, common.LogLevelInfo, nil, "订单处理完成，目标日期: %s，结果: %s（成功 %d，失败 %d，共 %d）",
+		targetDate, result.Overall(), result.Count(service.OutcomeSucceeded), result.Count(service.OutcomeFailed), len(result.Orders))
+	return runExitCode(result, err)
+}
+
+// runExitCode 根据运行结果返回退出码：部分成功为 4，有提交且全部失败为 5；
+// 没有订单提交时，有错误为 1，否则为 0。
+func runExitCode(result *service.RunResult, err error) int {
+	switch result.Overall() {
+	case service.RunSucceeded:
+		return 0
+	case service.RunPartial:
+		return 4
+	case service.RunFailed:
+		return 5
+	case service.RunNotAttempted:
+		return 1
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// printRunSummary 在标准输出打印本次运行各订单的结果与置为过期的订单。
+func printRunSummary(format string, result *service.RunResult, expired []service.ExpiredOrder) {
+	switch format {
+	case "json":
+		// 空列表输出 []，便于包装脚本直接遍历
+		orders := append([]*service.OrderResult{}, result.Orders...)
+		expired = append([]service.ExpiredOrder{}, expired...)
+		data, _ := json.MarshalIndent(map[string]any{
+			"date":    result.Date,
+			"outcome": result.Overall(),
+			"orders":  orders,
+			"expired": expired,
+		}, "", "  ")
+		fmt.Println(string(data))
+	case "table":
+		fmt.Printf("%s: %s\n", result.Date, result.Overall())
+		if len(result.Orders) == 0 {
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\t表单\t时段\t场地\t结果\t状态\t耗时\t错误")
+		for _, order := range result.Orders {
+			status := string(order.Status)
+			if !order.Persisted {
+				status += "（未落库）"
+			}
+			latency := "-"
+			if order.Latency > 0 {
+				latency = order.Latency.Round(time.Millisecond).String()
+			}
+			detail := order.Error
+			if order.ErrorClass != "" {
+				detail = string(order.ErrorClass) + ": " + detail
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d:00\t%d\t%s\t%s\t%s\t%s\n", order.OrderID, order.Form, order.Hour, order.Venue,
+				order.Outcome, status, latency, detail)
+		}
+		w.Flush()
+	}
+}
+
+// openJournal 打开本地结果日志（持有单实例锁后调用），补写其中尚未写入数据库的记录后压缩。
+func openJournal(ctx context.Context, app *app) (*journal.Journal, error) {
+	path, err := journalFilePath(app.config)
+	if err != nil {
+		return nil, err
+	}
+	j, err := journal.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(j.Pending()) == 0 {
+		return j, nil
+	}
+
+	app.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "结果日志 %s 中有 %d 条提交结果未写入数据库，开始补写", path, len(j.Pending()))
+	replayed, err := service.ReplayJournal(ctx, app.repo, j)
+	for _, r := range replayed {
+		switch r.Outcome {
+		case service.ReplayConflict:
+			log.Printf("结果日志 %s：订单 %d 的 %s 未补写: %v", r.Entry.ID, r.Entry.OrderID, r.Entry.Status, r.Err)
+		case service.ReplayPending:
+			log.Printf("结果日志 %s：订单 %d 的 %s 补写失败: %v", r.Entry.ID, r.Entry.OrderID, r.Entry.Status, r.Err)
+		default:
+			app.repo.CreateLogf(ctx, common.LogLevelInfo, nil, "结果日志 %s：订单 %d 补写为 %s（%s）", r.Entry.ID, r.Entry.OrderID, r.Entry.Status, r.Outcome)
+		}
+	}
+	if err != nil {
+		j.Close()
+		return nil, fmt.Errorf("%v，未处理订单；请检查数据库后重新运行（%s）", err, path)
+	}
+	if err := j.Compact(); err != nil {
+		app.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "%v", err)
+	}
+	return j, nil
+}
+
+// journalFilePath 返回结果日志路径：未配置 run.journal_path 时，SQLite 为数据库文件旁的 <path>.journal，
+// PostgreSQL 为当前目录下的 sports-order.journal。
+func journalFilePath(config *common.Config) (string, error) {
+	switch {
+	case config.Run.JournalPath != "":
+		return filepath.Abs(config.Run.JournalPath)
+	case config.Database.DriverName() == common.DriverPostgres:
+		return filepath.Abs("sports-order.journal")
+	}
+	return filepath.Abs(config.Database.Path + ".journal")
+}
+
+// reportExpired 在运行结束时输出本次置为 EXPIRED 的订单（标准错误，cron 会以邮件发出）。
+func reportExpired(expired []service.ExpiredOrder) {
+	if len(expired) == 0 {
+		return
+	}
+	log.Printf("%d 个订单已过期（%s）:", len(expired), strings.Join(service.CountByReason(expired), "，"))
+	for _, e := range expired {
+		log.Printf("  订单 %d: %s %d:00 场地 %d，%s: %s", e.Order.ID, e.Order.Date, e.Order.Hour, e.Order.Venue, e.Reason, e.Detail)
+	}
+}
+
+// buildAPIClient 组装 API 客户端：回放模式下使用 cassette，否则使用预热过的 HTTPClient，
+// 并按配置套上中间件链与请求录制。返回的 close 在运行结束时关闭录制文件。
+func buildAPIClient(ctx context.Context, config *common.Config, repo *Repository, runID, replayPath string) (common.APIClient, *middleware.TimingRecorder, func(), error) {
+	redactor := common.UserRedactor(&config.User)
+
+	var base common.APIClient
+	if replayPath != "" {
+		replayer, err := vcr.NewReplayerFromFile(replayPath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		repo.CreateLogf(ctx, common.LogLevelInfo, nil, "回放模式，cassette: %s", replayPath)
+		base = replayer
+	} else {
+		httpCfg := config.HTTP
+		if httpCfg.MaxIdleConns <= 0 {
+			httpCfg.MaxIdleConns = config.Run.Concurrency.GlobalLimit() // 连接池与提交并发数一致
+		}
+		httpClient := NewHTTPClient(httpCfg)
+		warmupClient(ctx, httpClient, config, repo)
+		base = httpClient
+	}
+
+	var inner []middleware.Middleware
+	closeClient := func() {}
+	if config.VCR.Record && replayPath == "" {
+		dir := config.VCR.Dir
+		if dir == "" {
+			dir = common.DefaultCassetteDir
+		}
+		recorder := vcr.NewRecorder(filepath.Join(dir, runID+".jsonl"), runID, redactor)
+		repo.CreateLogf(ctx, common.LogLevelInfo, nil, "请求录制到: %s", recorder.Path())
+		inner = append(inner, recorder.Middleware())
+		closeClient = func() {
+			if err := recorder.Close(); err != nil {
+				repo.CreateLogf(ctx, common.LogLevelWarn, nil, "请求录制不完整: %v", err)
+			}
+		}
+	}
+
+	client, timings := middleware.Build(base, config.Middleware, redactor, log.Printf, inner...)
+	return client, timings, closeClient, nil
+}
+
+// warmupClient 在开抢前预解析 DNS 并为每个表单域名预建连接，失败仅记录告警。
+func warmupClient(ctx context.Context, client *HTTPClient, config *common.Config, repo *Repository) {
+	conns := config.HTTP.MaxIdleConns
+	if conns <= 0 {
+		conns = config.Run.Concurrency.GlobalLimit()
+	}
+
+	seen := make(map[string]bool)
+	for _, form := range config.AllForms() {
+		if seen[form.BaseURL] {
+			continue
+		}
+		seen[form.BaseURL] = true
+
+		start := time.Now()
+		if err := client.Warmup(ctx, form.BaseURL, conns); err != nil {
+			repo.CreateLogf(ctx, common.LogLevelWarn, nil, "连接预热失败 (%s): %v", form.BaseURL, err)
+			continue
+		}
+		repo.CreateLogf(ctx, common.LogLevelInfo, nil, "连接预热完成 (%s): %d 条连接，耗时 %v", form.BaseURL, conns, time.Since(start))
+	}
+}
+
+// logTimings 将本次运行的请求耗时统计写入日志（未启用耗时统计时跳过）。
+func logTimings(ctx context.Context, repo *Repository, timings *middleware.TimingRecorder) {
+	if timings == nil {
+		return
+	}
+	for method, stat := range timings.Stats() {
+		repo.CreateLogf(ctx, common.LogLevelInfo, nil, "请求耗时 %s: 共 %d 次（失败 %d），最小 %v，中位 %v，最大 %v",
+			method, stat.Count, stat.Errors, stat.Min, stat.Median, stat.Max)
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"sports_order/common"
+)
+
+// catalogFetcher 拉取目录数据（BookingService 满足该接口）。
+type catalogFetcher interface {
+	GetCatalogData(ctx context.Context) (*common.CatalogData, error)
+}
+
+// FillSampler 在开放预约后高频拉取目录，记录每个时段每个场地被约满的时间。
+type FillSampler struct {
+	fetcher  catalogFetcher
+	repo     common.Repository
+	interval time.Duration
+	window   time.Duration
+}
+
+// NewFillSampler 创建约满时间采样器。
+func NewFillSampler(fetcher catalogFetcher, repo common.Repository, interval, window time.Duration) *FillSampler {
+	return &FillSampler{fetcher: fetcher, repo: repo, interval: interval, window: window}
+}
+
+// Sample 从 openAt 起持续采样 date 当天的占用情况，直到窗口结束、全部约满或 ctx 取消，
+// 然后保存并返回每个时段每个场地的结果。单次拉取失败只记录告警，不中止采样。
+func (s *FillSampler) Sample(ctx context.Context, form, date string, openAt time.Time) ([]*common.SlotFill, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("无效的日期 %s: %v", date, err)
+	}
+
+	fills := make(map[venueSlotKey]*common.SlotFill)
+	deadline := openAt.Add(s.window)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		sampledAt := time.Now()
+		data, err := s.fetcher.GetCatalogData(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			s.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "采样拉取目录失败: %v", err)
+		} else if s.observe(fills, common.SlotFill{
+			Form:     form,
+			RunID:    common.RunIDFrom(ctx),
+			Date:     date,
+			Weekday:  int(day.Weekday()),
+			OpenedAt: openAt,
+		}, sampledAt, data) {
+			break
+		}
+
+		if !time.Now().Before(deadline) {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	result := make([]*common.SlotFill, 0, len(fills))
+	for _, fill := range fills {
+		result = append(result, fill)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Hour != result[j].Hour {
+			return result[i].Hour < result[j].Hour
+		}
+		return result[i].VenueName < result[j].VenueName
+	})
+
+	if err := s.repo.SaveSlotFills(context.WithoutCancel(ctx), result); err != nil {
+		return result, fmt.Errorf("保存采样结果失败: %v", err)
+	}
+	return result, nil
+}
+
+// observe 根据一次采样更新约满记录，返回是否已全部约满。
+// base 提供表单、日期、开放时刻等公共字段。
+func (s *FillSampler) observe(fills map[venueSlotKey]*common.SlotFill, base common.SlotFill,
+	sampledAt time.Time, data *common.CatalogData) bool {
+	dateInfo, ok := data.DateMap[base.Date]
+	if !ok {
+		return false
+	}
+
+	names := make(map[string]string, len(data.Venues))
+	for _, venue := range data.Venues {
+		names[venue.UUID] = venue.Name
+	}
+
+	allFull := true
+	for hour, usage := range dateInfo.Usage {
+		for venueUUID, u := range usage {
+			key := venueSlotKey{slotKey{base.Date, hour}, venueUUID}
+			fill, exists := fills[key]
+			if !exists {
+				fill = &common.SlotFill{}
+				*fill = base
+				fill.Hour = hour
+				fill.VenueUUID = venueUUID
+				fill.VenueName = names[venueUUID]
+				fills[key] = fill
+			}
+			fill.Samples++
+
+			if fill.FilledAt == nil && u.Limit > 0 && u.UsedCount >= u.Limit {
+				filledAt := sampledAt
+				seconds := filledAt.Sub(base.OpenedAt).Seconds()
+				fill.FilledAt = &filledAt
+				fill.FillSeconds = &seconds
+			}
+			if fill.FilledAt == nil {
+				allFull = false
+			}
+		}
+	}
+	return len(fills) > 0 && allFull
+}
+
+// FillReportRow 是约满时间报告中的一行（按星期、时段、场地聚合）。
+type FillReportRow struct {
+	Weekday       time.Weekday
+	Hour          int
+	Venue         string
+	Observations  int     // 采样窗口次数
+	SoldOut       int     // 窗口内约满的次数
+	SoldOutPct    float64 // 约满比例（%）
+	MedianSeconds float64 // 约满用时中位数（秒），未约满过为 -1
+}
+
+// BuildFillReport 按星期、时段、场地聚合采样结果。
+func BuildFillReport(fills []*common.SlotFill) []FillReportRow {
+	type groupKey struct {
+		weekday int
+		hour    int
+		venue   string
+	}
+	groups := make(map[groupKey][]*common.SlotFill)
+	for _, fill := range fills {
+		key := groupKey{fill.Weekday, fill.Hour, fill.VenueName}
+		groups[key] = append(groups[key], fill)
+	}
+
+	rows := make([]FillReportRow, 0, len(groups))
+	for key, group := range groups {
+		var seconds []float64
+		for _, fill := range group {
+			if fill.FillSeconds != nil {
+				seconds = append(seconds, *fill.FillSeconds)
+			}
+		}
+		row := FillReportRow{
+			Weekday:       time.Weekday(key.weekday),
+			Hour:          key.hour,
+			Venue:         key.venue,
+			Observations:  len(group),
+			SoldOut:       len(seconds),
+			SoldOutPct:    float64(len(seconds)) * 100 / float64(len(group)),
+			MedianSeconds: -1,
+		}
+		if len(seconds) > 0 {
+			row.MedianSeconds = median(seconds)
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Weekday != b.Weekday {
+			return a.Weekday < b.Weekday
+		}
+		if a.Hour != b.Hour {
+			return a.Hour < b.Hour
+		}
+		return a.Venue < b.Venue
+	})
+	return rows
+}
+
+// median 返回中位数（偶数个时取中间两数的平均）。
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sports_order/common"
+)
+
+// scriptedFetcher 依次返回预设的占用情况（小时 8，场地 a/b），用尽后重复最后一次。
+type scriptedFetcher struct {
+	used  [][2]int
+	calls int
+}
+
+func (f *scriptedFetcher) GetCatalogData(ctx context.Context) (*common.CatalogData, error) {
+	i := f.calls
+	if i >= len(f.used) {
+		i = len(f.used) - 1
+	}
+	f.calls++
+	return &common.CatalogData{
+		Venues: []common.VenueInfo{{UUID: "a", Name: "1号"}, {UUID: "b", Name: "2号"}},
+		DateMap: map[string]common.DateInfo{
+			"2025-12-15": {
+				TimeMap: map[int]string{8: "t8"},
+				Usage: map[int]map[string]common.SlotUsage{
+					8: {"a": {Limit: 1, UsedCount: f.used[i][0]}, "b": {Limit: 1, UsedCount: f.used[i][1]}},
+				},
+			},
+		},
+	}, nil
+}
+
+func TestFillSamplerStopsWhenAllFull(t *testing.T) {
+	fetcher := &scriptedFetcher{used: [][2]int{{0, 0}, {1, 0}, {1, 1}}}
+	repo := newFakeRepository()
+	sampler := NewFillSampler(fetcher, repo, time.Millisecond, time.Minute)
+
+	fills, err := sampler.Sample(context.Background(), "badminton", "2025-12-15", time.Now())
+	if err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	if fetcher.calls != 3 {
+		t.Fatalf("fetches = %d, want 3 (stop once everything is full)", fetcher.calls)
+	}
+	if len(fills) != 2 || fills[0].FilledAt == nil || fills[1].FilledAt == nil {
+		t.Fatalf("fills = %+v, want both venues filled", fills)
+	}
+	if *fills[0].FillSeconds > *fills[1].FillSeconds {
+		t.Errorf("1号 filled after 2号: %.3f > %.3f", *fills[0].FillSeconds, *fills[1].FillSeconds)
+	}
+	if fills[0].Weekday != int(time.Monday) || len(repo.fills) != 2 {
+		t.Errorf("weekday = %d, saved = %d; want Monday and 2 saved", fills[0].Weekday, len(repo.fills))
+	}
+}
+
+func TestBuildFillReport(t *testing.T) {
+	sec := func(v float64) *float64 { return &v }
+	now := time.Now()
+	fills := []*common.SlotFill{
+		{Weekday: 1, Hour: 20, VenueName: "1号", FilledAt: &now, FillSeconds: sec(3)},
+		{Weekday: 1, Hour: 20, VenueName: "1号", FilledAt: &now, FillSeconds: sec(5)},
+		{Weekday: 1, Hour: 20, VenueName: "1号"},
+		{Weekday: 1, Hour: 8, VenueName: "1号"},
+	}
+
+	rows := BuildFillReport(fills)
+	if len(rows) != 2 || rows[0].Hour != 8 {
+		t.Fatalf("rows = %+v, want 2 rows ordered by hour", rows)
+	}
+	if rows[0].MedianSeconds != -1 || rows[0].SoldOutPct != 0 {
+		t.Errorf("08:00 row = %+v, want never sold out", rows[0])
+	}
+	if rows[1].Observations != 3 || rows[1].SoldOut != 2 || rows[1].MedianSeconds != 4 {
+		t.Errorf("20:00 row = %+v, want 3 observations, 2 sold out, median 4s", rows[1])
+	}
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"sports_order/common"
 )
@@ -11,39 +13,39 @@ import (
 type BookingService struct {
 	apiClient common.APIClient
 	repo      common.Repository
-	user      *common.User // 从配置文件加载的用户信息
+	user      *common.User       // 从配置文件加载的用户信息
+	form      *common.FormConfig // 目标表单（地址、表单 ID、字段 CID）
 }
 
 // NewBookingService 创建预约服务。
-func NewBookingService(apiClient common.APIClient, repo common.Repository, user *common.User) *BookingService {
-	return &BookingService{apiClient: apiClient, repo: repo, user: user}
+func NewBookingService(apiClient common.APIClient, repo common.Repository, user *common.User, form *common.FormConfig) *BookingService {
+	return &BookingService{apiClient: apiClient, repo: repo, user: user, form: form}
 }
 
-// BookTimeSlot 针对某一天某一小时提交一次预约请求。
-func (s *BookingService) BookTimeSlot(data *common.CatalogData, slot common.BookingSlot) error {
-	dateInfo, exists := data.DateMap[slot.Date]
-	if !exists {
-		return fmt.Errorf("日期 %s 不可预约", slot.Date)
-	}
-
-	if _, exists := dateInfo.TimeMap[slot.Hour]; !exists {
-		return fmt.Errorf("时段 %d:00 在 %s 不可预约", slot.Hour, slot.Date)
-	}
+// Form 返回该服务操作的表单配置。
+func (s *BookingService) Form() *common.FormConfig {
+	return s.form
+}
 
-	if slot.Venue < 1 || slot.Venue > len(data.Options) {
-		return fmt.Errorf("无效的场地号 %d", slot.Venue)
+// BookTimeSlot 针对某一天某一小时提交一次预约请求。
+func (s *BookingService) BookTimeSlot(ctx context.Context, data *common.CatalogData, slot common.BookingSlot) error {
+	if err := CheckSlot(data, slot); err != nil {
+		return err
 	}
 
-	request := buildBookingRequest(s.user, data, slot)
+	request := buildBookingRequest(s.user, s.form, data, slot)
 
 	jsonData, err := json.Marshal(request)
 	if err != nil {
 		return fmt.Errorf("序列化请求失败: %v", err)
 	}
 
-	resp, err := s.apiClient.Post(common.FormDataURL, jsonData, s.user.Token)
+	resp, err := s.apiClient.Post(ctx, s.form.FormDataURL(), jsonData, s.user.Token, s.form.Headers())
 	if err != nil {
-		return fmt.Errorf("提交预约请求失败: %v", err)
+		if rejection := rejectionFromHTTPError(err); rejection != nil {
+			return wrapRejection(rejection)
+		}
+		return fmt.Errorf("提交预约请求失败: %w", err)
 	}
 
 	var bookingResp common.BookingResponse
@@ -52,22 +54,70 @@ func (s *BookingService) BookTimeSlot(data *common.CatalogData, slot common.Book
 	}
 
 	if bookingResp.Code != common.ResponseCodeSuccess {
-		return fmt.Errorf("预约失败: %s", bookingResp.Message)
+		return wrapRejection(&common.RejectionError{
+			StatusCode: http.StatusOK,
+			Code:       bookingResp.Code,
+			Message:    bookingResp.Message,
+		})
+	}
+
+	return nil
+}
+
+// CheckSlot 检查日期、时段与场地号能否在目录中找到对应的选项。
+func CheckSlot(data *common.CatalogData, slot common.BookingSlot) error {
+	dateInfo, exists := data.DateMap[slot.Date]
+	if !exists {
+		return &common.SlotError{Message: fmt.Sprintf("日期 %s 不可预约", slot.Date)}
+	}
+
+	if _, exists := dateInfo.TimeMap[slot.Hour]; !exists {
+		return &common.SlotError{Message: fmt.Sprintf("时段 %d:00 在 %s 不可预约", slot.Hour, slot.Date)}
 	}
 
+	if slot.Venue < 1 || slot.Venue > len(data.Options) {
+		return &common.SlotError{Message: fmt.Sprintf("无效的场地号 %d", slot.Venue)}
+	}
 	return nil
 }
 
+// rejectionFromHTTPError 将带业务码的 4xx 响应（如 422 时段未开放）还原为 RejectionError。
+// 401 等认证错误以及无法解析的响应体返回 nil。
+func rejectionFromHTTPError(err error) *common.RejectionError {
+	httpErr, ok := common.AsHTTPError(err)
+	if !ok || httpErr.StatusCode < 400 || httpErr.StatusCode >= 500 || httpErr.StatusCode == http.StatusUnauthorized {
+		return nil
+	}
+
+	var bookingResp common.BookingResponse
+	if json.Unmarshal(httpErr.Body, &bookingResp) != nil || bookingResp.Code == common.ResponseCodeSuccess {
+		return nil
+	}
+	return &common.RejectionError{
+		StatusCode: httpErr.StatusCode,
+		Code:       bookingResp.Code,
+		Message:    bookingResp.Message,
+	}
+}
+
+// wrapRejection 在拒绝原因提示表单版本过期时附加 ErrFormVersionMismatch，便于调用方重试。
+func wrapRejection(rejection *common.RejectionError) error {
+	if common.IsVersionMismatchMessage(rejection.Message) {
+		return fmt.Errorf("%w: %w", common.ErrFormVersionMismatch, rejection)
+	}
+	return rejection
+}
+
 // buildBookingRequest 构造对外 API 需要的预约请求体。
-func buildBookingRequest(user *common.User, catalog *common.CatalogData, slot common.BookingSlot) common.BookingRequest {
+func buildBookingRequest(user *common.User, form *common.FormConfig, catalog *common.CatalogData, slot common.BookingSlot) common.BookingRequest {
 	dateInfo := catalog.DateMap[slot.Date]
 	return common.BookingRequest{
 		Catalogs: []common.RequestField{
-			{Type: common.TypeWord, Cid: common.CIDName, Value: user.Name},
-			{Type: common.TypeTelephone, Cid: common.CIDPhone, Value: user.Phone},
-			{Type: common.TypeWord, Cid: common.CIDStudentID, Value: user.StudentID},
-			{Type: common.TypeImage, Cid: common.CIDImage, Value: []string{user.ImageURL}},
-			{Type: common.TypeReservation, Cid: common.CIDReservation, Value: []common.ReservationValue{
+			{Type: common.TypeWord, Cid: form.Fields.Name, Value: user.Name},
+			{Type: common.TypeTelephone, Cid: form.Fields.Phone, Value: user.Phone},
+			{Type: common.TypeWord, Cid: form.Fields.StudentID, Value: user.StudentID},
+			{Type: common.TypeImage, Cid: form.Fields.Image, Value: []string{user.ImageURL}},
+			{Type: common.TypeReservation, Cid: form.Fields.Reservation, Value: []common.ReservationValue{
 				{
 					DateID:     dateInfo.DateID,
 					TimeID:     dateInfo.TimeMap[slot.Hour],
@@ -79,7 +129,7 @@ func buildBookingRequest(user *common.User, catalog *common.CatalogData, slot co
 				},
 			}},
 		},
-		ShowQuestions: common.ShowQuestions,
+		ShowQuestions: form.ShowQuestions(),
 		FormVersion:   catalog.FormVersion,
 	}
 }
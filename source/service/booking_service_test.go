@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"sports_order/common"
+	"sports_order/vcr"
+)
+
+// sampleCassette 使用 package_data 中的抓包样本构造一次运行的录制：
+// profile、catalog 成功返回，提交预约返回 422「时段未开放预约」。
+func sampleCassette(t *testing.T, form *common.FormConfig) *vcr.Cassette {
+	t.Helper()
+	profile, err := os.ReadFile("../../package_data/profile.json")
+	if err != nil {
+		t.Fatalf("读取 profile 样本失败: %v", err)
+	}
+	catalog, err := os.ReadFile("../../package_data/catalog.json")
+	if err != nil {
+		t.Fatalf("读取 catalog 样本失败: %v", err)
+	}
+
+	return &vcr.Cassette{
+		RunID: "sample",
+		Interactions: []vcr.Interaction{
+			{Method: http.MethodGet, URL: form.ProfileURL(), StatusCode: http.StatusOK, ResponseBody: string(profile)},
+			{Method: http.MethodGet, URL: form.CatalogURL(), StatusCode: http.StatusOK, ResponseBody: string(catalog)},
+			{
+				Method:       http.MethodPost,
+				URL:          form.FormDataURL(),
+				StatusCode:   http.StatusUnprocessableEntity,
+				Status:       "422 Unprocessable Entity",
+				ResponseBody: `{"code":17936,"message":"您选择的时段未开放预约"}`,
+			},
+		},
+	}
+}
+
+// sampleNow 返回抓包样本所处的时间（表单截止时间 2025-12-31 之前）。
+func sampleNow() time.Time {
+	return time.Date(2025, 12, 20, 8, 0, 0, 0, time.Local)
+}
+
+// TestReplaySampleRun 离线回放抓包样本，确认解析与失败信息保持不变。
+func TestReplaySampleRun(t *testing.T) {
+	form := common.DefaultFormConfig()
+	replayer := vcr.NewReplayer(sampleCassette(t, form))
+	booking := NewBookingService(replayer, nil, &common.User{Name: "张三"}, form)
+
+	ctx := context.Background()
+	data, err := booking.GetCatalogData(ctx)
+	if err != nil {
+		t.Fatalf("GetCatalogData: %v", err)
+	}
+	if data.FormVersion != 245 || len(data.Options) != 6 || len(data.DateMap) != 7 {
+		t.Fatalf("catalog = version %d, %d options, %d dates; want 245, 6, 7",
+			data.FormVersion, len(data.Options), len(data.DateMap))
+	}
+
+	err = booking.BookTimeSlot(ctx, data, common.BookingSlot{Date: "2025-12-22", Hour: 21, Venue: 1})
+	if err == nil || !strings.Contains(err.Error(), "未开放预约") {
+		t.Fatalf("BookTimeSlot err = %v, want 422 未开放预约", err)
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -8,20 +9,15 @@ import (
 )
 
 // GetCatalogData 拉取并解析表单元数据（版本号、场地选项、日期/时段映射）。
-func (s *BookingService) GetCatalogData() (*common.CatalogData, error) {
+func (s *BookingService) GetCatalogData(ctx context.Context) (*common.CatalogData, error) {
 	// profile：获取表单版本等信息
-	versionResp, err := s.apiClient.Get(common.FormURL + common.ProfileEndpoint)
+	profileResp, err := s.getProfile(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("请求表单配置失败: %v", err)
-	}
-
-	var profileResp common.ProfileResponse
-	if err := json.Unmarshal(versionResp, &profileResp); err != nil {
-		return nil, fmt.Errorf("反序列化表单配置失败: %v", err)
+		return nil, err
 	}
 
 	// catalog：获取可选场地与可预约日期/时段配置
-	catalogResp, err := s.apiClient.Get(common.FormURL + common.CatalogEndpoint)
+	catalogResp, err := s.apiClient.Get(ctx, s.form.CatalogURL(), s.form.Headers())
 	if err != nil {
 		return nil, fmt.Errorf("请求场地目录失败: %v", err)
 	}
@@ -34,17 +30,14 @@ func (s *BookingService) GetCatalogData() (*common.CatalogData, error) {
 	// 组装业务侧更好用的数据结构
 	data := &common.CatalogData{
 		FormVersion: profileResp.Data.Version,
+		Profile:     &profileResp.Data,
 		DateMap:     make(map[string]common.DateInfo),
 	}
 
-	// 场地预约配置固定在 catalogs[4]
-	const venueCatalogIndex = 4
-	if len(catalogResponse.Data.Catalogs) <= venueCatalogIndex {
-		return nil, fmt.Errorf("表单长度错误，长度: %d", len(catalogResponse.Data.Catalogs))
-	}
-	venueCatalog := catalogResponse.Data.Catalogs[venueCatalogIndex]
-	if venueCatalog.Type != common.TypeReservation {
-		return nil, fmt.Errorf("表单场地项类型错误: %s", venueCatalog.Type)
+	// 按配置的预约字段 CID 定位场地预约配置
+	venueCatalog, err := findReservationCatalog(catalogResponse.Data.Catalogs, s.form.Fields.Reservation)
+	if err != nil {
+		return nil, err
 	}
 
 	// 抽取场地选项与日期/时段映射
@@ -53,6 +46,15 @@ func (s *BookingService) GetCatalogData() (*common.CatalogData, error) {
 		case common.RoleOption:
 			// 场地选项（例如 1 号场、2 号场...）
 			data.Options = append(data.Options, formCatalog.Cid)
+			venue := common.VenueInfo{
+				Cid:  formCatalog.Cid,
+				UUID: formCatalog.UUID,
+				Name: formCatalog.Content,
+			}
+			if limit := formCatalog.Config.Limit; limit != nil && limit.Active {
+				venue.Limit = limit.Attachment
+			}
+			data.Venues = append(data.Venues, venue)
 		case common.RoleReservationDate:
 			// 日期与时段映射
 			data.DateMap[formCatalog.Content] = parseDateInfo(formCatalog)
@@ -62,15 +64,71 @@ func (s *BookingService) GetCatalogData() (*common.CatalogData, error) {
 	return data, nil
 }
 
+// GetFormVersion 只拉取 profile，返回当前表单版本号（用于检测版本漂移）。
+func (s *BookingService) GetFormVersion(ctx context.Context) (int, error) {
+	profileResp, err := s.getProfile(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return profileResp.Data.Version, nil
+}
+
+// GetProfile 拉取表单信息（状态、截止时间、文件有效期等）。
+func (s *BookingService) GetProfile(ctx context.Context) (*common.FormProfile, error) {
+	profileResp, err := s.getProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &profileResp.Data, nil
+}
+
+// getProfile 请求并解析 profile 接口。
+func (s *BookingService) getProfile(ctx context.Context) (*common.ProfileResponse, error) {
+	versionResp, err := s.apiClient.Get(ctx, s.form.ProfileURL(), s.form.Headers())
+	if err != nil {
+		return nil, fmt.Errorf("请求表单配置失败: %v", err)
+	}
+
+	var profileResp common.ProfileResponse
+	if err := json.Unmarshal(versionResp, &profileResp); err != nil {
+		return nil, fmt.Errorf("反序列化表单配置失败: %v", err)
+	}
+	return &profileResp, nil
+}
+
+// findReservationCatalog 在表单目录中查找预约字段，并校验其类型。
+func findReservationCatalog(catalogs []common.Catalog, cid string) (*common.Catalog, error) {
+	for i := range catalogs {
+		if catalogs[i].Cid != cid {
+			continue
+		}
+		if catalogs[i].Type != common.TypeReservation {
+			return nil, fmt.Errorf("表单场地项类型错误: %s", catalogs[i].Type)
+		}
+		return &catalogs[i], nil
+	}
+	return nil, fmt.Errorf("表单中未找到预约字段: %s", cid)
+}
+
 // parseDateInfo 从 FormCatalog 解析出日期信息及其时段映射。
 func parseDateInfo(fc common.FormCatalog) common.DateInfo {
 	dateInfo := common.DateInfo{
 		DateID:  fc.Cid,
 		TimeMap: make(map[int]string),
+		Usage:   make(map[int]map[string]common.SlotUsage),
 	}
 	for _, child := range fc.ChildCatalogs {
 		hour := child.Content.StartTime / 100
 		dateInfo.TimeMap[hour] = child.Cid
+
+		usage := make(map[string]common.SlotUsage, len(child.ChildCatalogs))
+		for _, option := range child.ChildCatalogs {
+			usage[option.Content.UUID] = common.SlotUsage{
+				Limit:     option.Content.Limit,
+				UsedCount: option.Content.UsedCount,
+			}
+		}
+		dateInfo.Usage[hour] = usage
 	}
 	return dateInfo
 }
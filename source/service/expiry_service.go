@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"sports_order/common"
+)
+
+// ExpiryReason 说明过期订单为什么没有被提交。
+type ExpiryReason string
+
+const (
+	ExpiryFormClosed      ExpiryReason = "表单关闭"  // 运行时表单已暂停、过期或截止
+	ExpiryAccountLimit    ExpiryReason = "超出额度"  // 账号额度已被优先级更高的订单占用
+	ExpiryInterrupted     ExpiryReason = "提交未完成" // 上次提交被中断或结果未知，之后没有再运行
+	ExpiryDateNeverOpened ExpiryReason = "日期未开放" // 表单目录中从未出现过该日期
+	ExpiryMissedRun       ExpiryReason = "错过运行"  // 预约日没有运行处理该订单（cron 未触发、进程未启动等）
+)
+
+// ExpiredOrder 是一个被置为 EXPIRED 的订单及其原因。
+type ExpiredOrder struct {
+	Order  *common.Order `json:"order"`
+	Reason ExpiryReason  `json:"reason"`
+	Detail string        `json:"detail"`
+}
+
+// OrderSweeper 将预约日期已过、仍未完成的订单置为 EXPIRED。
+type OrderSweeper struct {
+	repo   common.Repository
+	config *common.Config
+	now    func() time.Time
+}
+
+// NewOrderSweeper 创建过期订单清理器。
+func NewOrderSweeper(repo common.Repository, config *common.Config) *OrderSweeper {
+	return &OrderSweeper{repo: repo, config: config, now: time.Now}
+}
+
+// Sweep 将预约日期早于今天且未进入终态的订单置为 EXPIRED，并记录未被提交的原因；except 日期的订单保留
+// （例如 run -date 指定的日期）。仍持有有效租约的订单正被其他进程处理，跳过；租约已过期的先回收。
+func (s *OrderSweeper) Sweep(ctx context.Context, except string) ([]ExpiredOrder, error) {
+	today := s.now().Format("2006-01-02")
+	orders, err := s.repo.FindStaleOrders(ctx, today)
+	if err != nil {
+		return nil, fmt.Errorf("查询过期订单失败: %v", err)
+	}
+
+	now := s.now()
+	recovered := make(map[string]bool)
+	catalogDates := make(map[string]*dateSet)
+	var expired []ExpiredOrder
+	for _, order := range orders {
+		if order.Date == except {
+			continue
+		}
+		if common.OrderStatus(order.Status).Leased() {
+			if order.LeaseExpiresAt != nil && order.LeaseExpiresAt.After(now) {
+				continue
+			}
+			if !recovered[order.Date] {
+				recovered[order.Date] = true
+				if _, err := s.repo.RecoverExpiredLeases(ctx, order.Date); err != nil {
+					return expired, fmt.Errorf("回收过期租约失败: %v", err)
+				}
+			}
+			// 回收后 SCHEDULED 为 PENDING，IN_PROGRESS 为 UNKNOWN
+			if order.Status == string(common.OrderStatusInProgress) {
+				order.Status = string(common.OrderStatusUnknown)
+				order.LastError = fmt.Sprintf("认领者 %s 的租约过期，提交结果未知", order.ClaimedBy)
+			}
+		}
+
+		reason, detail := s.classify(ctx, order, catalogDates)
+		cause := fmt.Errorf("%s: %s", reason, detail)
+		orderID := int(order.ID)
+		if err := s.repo.TransitionOrder(ctx, order.ID, "", common.OrderStatusExpired, cause); err != nil {
+			s.repo.CreateLogf(ctx, common.LogLevelError, &orderID, "订单 %d 置为 EXPIRED 失败: %v", order.ID, err)
+			continue
+		}
+		s.repo.CreateLogf(ctx, common.LogLevelWarn, &orderID, "订单 %d（%s %d:00 场地 %d）已过期，未被提交的原因: %v",
+			order.ID, order.Date, order.Hour, order.Venue, cause)
+		expired = append(expired, ExpiredOrder{Order: order, Reason: reason, Detail: detail})
+	}
+	return expired, nil
+}
+
+// Run 立即清理一次，之后每隔 interval 清理一次，直到 ctx 取消；每次的结果交给 report。
+// 单次清理失败只交给 report，不中止循环。
+func (s *OrderSweeper) Run(ctx context.Context, interval time.Duration, report func([]ExpiredOrder, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report(s.Sweep(ctx, ""))
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// classify 根据订单最近错误的分类（error_class）、状态与目录快照判断订单未被提交的原因。
+func (s *OrderSweeper) classify(ctx context.Context, order *common.Order, catalogDates map[string]*dateSet) (ExpiryReason, string) {
+	switch common.ErrorClass(order.ErrorClass) {
+	case common.ErrorClassFormUnavailable:
+		return ExpiryFormClosed, order.LastError
+	case common.ErrorClassLimit:
+		return ExpiryAccountLimit, order.LastError
+	}
+	if order.Status == string(common.OrderStatusRetrying) || order.Status == string(common.OrderStatusUnknown) {
+		return ExpiryInterrupted, order.LastError
+	}
+
+	if form, err := s.config.Form(order.Form); err == nil {
+		dates, ok := catalogDates[form.Name]
+		if !ok {
+			list, err := s.repo.CatalogDates(ctx, form.Name)
+			if err != nil {
+				s.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "查询表单 %s 的目录日期失败: %v", form.Name, err)
+			}
+			dates = newDateSet(list)
+			catalogDates[form.Name] = dates
+		}
+		if dates.neverOpened(order.Date) {
+			return ExpiryDateNeverOpened, fmt.Sprintf("表单 %s 的目录快照中从未出现 %s", form.Name, order.Date)
+		}
+	}
+
+	if order.ScheduledAt == nil {
+		return ExpiryMissedRun, "没有运行认领过该订单"
+	}
+	detail := "最近一次认领于 " + order.ScheduledAt.Local().Format("2006-01-02 15:04:05") + "，之后未提交"
+	if order.LastError != "" {
+		detail += "（" + order.LastError + "）"
+	}
+	return ExpiryMissedRun, detail
+}
+
+// dateSet 是目录快照中出现过的日期。
+type dateSet struct {
+	seen  map[string]bool
+	first string // 最早的日期
+}
+
+func newDateSet(dates []string) *dateSet {
+	set := &dateSet{seen: make(map[string]bool)}
+	for _, date := range dates {
+		set.seen[date] = true
+		if set.first == "" || date < set.first {
+			set.first = date
+		}
+	}
+	return set
+}
+
+// neverOpened 判断 date 是否确定没有开放过：只有快照已覆盖该日期（不早于最早的快照日期）时才能确定。
+func (d *dateSet) neverOpened(date string) bool {
+	return d.first != "" && date >= d.first && !d.seen[date]
+}
+
+// CountByReason 按原因统计过期订单，按原因排序输出 "原因 N" 列表。
+func CountByReason(expired []ExpiredOrder) []string {
+	counts := make(map[ExpiryReason]int)
+	for _, e := range expired {
+		counts[e.Reason]++
+	}
+	var parts []string
+	for reason, n := range counts {
+		parts = append(parts, fmt.Sprintf("%s %d", reason, n))
+	}
+	sort.Strings(parts)
+	return parts
+}
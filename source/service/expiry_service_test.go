@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sports_order/common"
+)
+
+func TestSweepExpiresStaleOrders(t *testing.T) {
+	form := common.DefaultFormConfig()
+	scheduledAt := sampleNow().AddDate(0, 0, -3)
+	expiredLease := sampleNow().Add(-time.Minute)
+	liveLease := time.Now().Add(time.Minute)
+	orders := []*common.Order{
+		{ID: 1, Date: "2025-12-18", Hour: 20, Form: form.Name, Status: string(common.OrderStatusPending)},
+		{ID: 2, Date: "2025-12-18", Hour: 21, Form: form.Name, Status: string(common.OrderStatusPending),
+			ScheduledAt: &scheduledAt, LastError: "表单不可预约: 「羽毛球」已暂停或关闭 (status=3)",
+			ErrorClass: string(common.ErrorClassFormUnavailable)},
+		{ID: 3, Date: "2025-12-18", Hour: 19, Form: form.Name, Status: string(common.OrderStatusInProgress),
+			ClaimedBy: "crashed:1:run", LeaseExpiresAt: &expiredLease},
+		{ID: 4, Date: "2025-12-19", Hour: 20, Form: form.Name, Status: string(common.OrderStatusPending)},
+		{ID: 5, Date: "2025-12-19", Hour: 21, Form: form.Name, Status: string(common.OrderStatusScheduled),
+			ClaimedBy: "other:1:run", LeaseExpiresAt: &liveLease},
+		{ID: 6, Date: "2025-12-17", Hour: 20, Form: form.Name, Status: string(common.OrderStatusPending)},
+		{ID: 7, Date: "2025-12-18", Hour: 18, Form: form.Name, Status: string(common.OrderStatusSuccess)},
+		{ID: 8, Date: "2025-12-20", Hour: 20, Form: form.Name, Status: string(common.OrderStatusPending)},
+		{ID: 9, Date: "2025-12-18", Hour: 20, Venue: 2, Form: form.Name, Status: string(common.OrderStatusPending),
+			ScheduledAt: &scheduledAt, LastError: "超出账号预约额度（每人每天最多 1 场，当日已成功 0 场），优先提交了订单 [1]",
+			ErrorClass: string(common.ErrorClassLimit)},
+		// 原因按 error_class 判断，不匹配 last_error 的文本
+		{ID: 10, Date: "2025-12-18", Hour: 20, Venue: 3, Form: form.Name, Status: string(common.OrderStatusPending),
+			ScheduledAt: &scheduledAt, LastError: "手动备注: 表单不可预约时再试", ErrorClass: string(common.ErrorClassOther)},
+	}
+	repo := newFakeRepository(orders...)
+	// 快照覆盖 12-18 起的日期，其中没有 12-19
+	repo.snapshots = append(repo.snapshots, &common.CatalogSnapshot{Form: form.Name, Slots: []common.CatalogSnapshotSlot{
+		{Date: "2025-12-18", Hour: 20}, {Date: "2025-12-20", Hour: 20},
+	}})
+	sweeper := NewOrderSweeper(repo, &common.Config{})
+	sweeper.now = sampleNow
+
+	expired, err := sweeper.Sweep(context.Background(), "2025-12-17")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint]ExpiryReason{1: ExpiryMissedRun, 2: ExpiryFormClosed, 3: ExpiryInterrupted, 4: ExpiryDateNeverOpened, 9: ExpiryAccountLimit,
+		10: ExpiryMissedRun}
+	if len(expired) != len(want) {
+		t.Fatalf("expired %d orders (%+v), want %d", len(expired), expired, len(want))
+	}
+	for _, e := range expired {
+		if want[e.Order.ID] != e.Reason {
+			t.Errorf("order %d reason = %s (%s), want %s", e.Order.ID, e.Reason, e.Detail, want[e.Order.ID])
+		}
+		if status := repo.orders[e.Order.ID].Status; status != string(common.OrderStatusExpired) {
+			t.Errorf("order %d status = %s, want EXPIRED", e.Order.ID, status)
+		}
+	}
+	// 持有有效租约、except 日期、终态与未过期的订单不受影响
+	for id, status := range map[uint]common.OrderStatus{5: common.OrderStatusScheduled, 6: common.OrderStatusPending,
+		7: common.OrderStatusSuccess, 8: common.OrderStatusPending} {
+		if got := repo.orders[id].Status; got != string(status) {
+			t.Errorf("order %d status = %s, want %s", id, got, status)
+		}
+	}
+}
+
+// TestSweeperRun 常驻运行时立即清理一次，之后按间隔清理，ctx 取消后退出。
+func TestSweeperRun(t *testing.T) {
+	repo := newFakeRepository(&common.Order{ID: 1, Date: "2025-12-18", Hour: 20, Status: string(common.OrderStatusPending)})
+	sweeper := NewOrderSweeper(repo, &common.Config{})
+	sweeper.now = sampleNow
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var sweeps, total int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sweeper.Run(ctx, 10*time.Millisecond, func(expired []ExpiredOrder, err error) {
+			if err != nil {
+				t.Errorf("sweep %d: %v", sweeps, err)
+			}
+			sweeps++
+			total += len(expired)
+			if sweeps == 3 {
+				cancel()
+			}
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after ctx was canceled")
+	}
+	if sweeps != 3 || total != 1 {
+		t.Errorf("sweeps = %d, expired = %d; want 3 sweeps expiring the order once", sweeps, total)
+	}
+}
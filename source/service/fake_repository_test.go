@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"sports_order/common"
+)
+
+// fakeRepository 是内存中的 common.Repository 实现，供服务层测试使用。
+type fakeRepository struct {
+	mu        sync.Mutex
+	orders    map[uint]*common.Order
+	logs      []string
+	snapshots []*common.CatalogSnapshot
+	events    []*common.CatalogEvent
+	fills     []*common.SlotFill
+
+	failTransitions map[common.OrderStatus]error // 变更到这些状态时返回的错误，模拟数据库写入失败
+}
+
+func newFakeRepository(orders ...*common.Order) *fakeRepository {
+	repo := &fakeRepository{orders: make(map[uint]*common.Order)}
+	for _, order := range orders {
+		repo.orders[order.ID] = order
+	}
+	return repo
+}
+
+// release 退回订单：SCHEDULED 回到 PENDING，IN_PROGRESS 转为 UNKNOWN。
+func release(order *common.Order, reason string) {
+	status := common.OrderStatusPending
+	if order.Status == string(common.OrderStatusInProgress) {
+		status, order.LastError = common.OrderStatusUnknown, reason
+	}
+	order.Status, order.ClaimedBy, order.LeaseExpiresAt = string(status), "", nil
+}
+
+func (r *fakeRepository) RecoverExpiredLeases(ctx context.Context, date string) ([]*common.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []*common.Order
+	now := time.Now()
+	for _, order := range r.orders {
+		if order.Date == date && common.OrderStatus(order.Status).Leased() && order.LeaseExpiresAt.Before(now) {
+			copied := *order
+			result = append(result, &copied)
+			release(order, "认领者的租约过期，提交结果未知")
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRepository) ClaimOrders(ctx context.Context, date, owner string, lease time.Duration) ([]*common.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []*common.Order
+	expiresAt := time.Now().Add(lease)
+	for _, order := range r.orders {
+		if order.Date == date && common.OrderStatus(order.Status).CanTransitionTo(common.OrderStatusScheduled) {
+			order.Status, order.ClaimedBy, order.LeaseExpiresAt = string(common.OrderStatusScheduled), owner, &expiresAt
+			copied := *order
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRepository) RenewLeases(ctx context.Context, owner string, lease time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var renewed int64
+	expiresAt := time.Now().Add(lease)
+	for _, order := range r.orders {
+		if common.OrderStatus(order.Status).Leased() && order.ClaimedBy == owner {
+			order.LeaseExpiresAt = &expiresAt
+			renewed++
+		}
+	}
+	return renewed, nil
+}
+
+func (r *fakeRepository) TransitionOrder(ctx context.Context, id uint, owner string, to common.OrderStatus, cause error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.failTransitions[to]; err != nil {
+		return err
+	}
+	order, ok := r.orders[id]
+	if !ok {
+		return fmt.Errorf("order %d not found", id)
+	}
+	from := common.OrderStatus(order.Status)
+	if owner != "" && (!from.Leased() || order.ClaimedBy != owner) {
+		return fmt.Errorf("订单 %d: %w", id, common.ErrLeaseLost)
+	}
+	if owner == "" && from.Leased() {
+		return fmt.Errorf("订单 %d 正由 %s 处理", id, order.ClaimedBy)
+	}
+	if !from.CanTransitionTo(to) {
+		return &common.TransitionError{OrderID: id, From: from, To: to}
+	}
+	if to == common.OrderStatusInProgress {
+		order.Attempts++
+	}
+	if cause != nil {
+		order.LastError, order.ErrorClass = cause.Error(), string(common.ClassifyError(cause))
+	}
+	if !to.Leased() {
+		order.LeaseExpiresAt = nil
+		if !to.Terminal() {
+			order.ClaimedBy = ""
+		}
+	}
+	order.Status = string(to)
+	return nil
+}
+
+func (r *fakeRepository) ReconcileOrder(ctx context.Context, id uint, owner string, to common.OrderStatus, cause error) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.failTransitions[to]; err != nil {
+		return false, err
+	}
+	order, ok := r.orders[id]
+	if !ok {
+		return false, fmt.Errorf("order %d not found", id)
+	}
+	from := common.OrderStatus(order.Status)
+	switch {
+	case from == to:
+		return false, nil
+	case from.Terminal():
+		return false, &common.TransitionError{OrderID: id, From: from, To: to}
+	case from.Leased() && order.ClaimedBy != owner && order.LeaseExpiresAt.After(time.Now()):
+		return false, fmt.Errorf("订单 %d: %w", id, common.ErrLeaseLost)
+	}
+	if cause != nil {
+		order.LastError, order.ErrorClass = cause.Error(), string(common.ClassifyError(cause))
+	}
+	order.ClaimedBy, order.LeaseExpiresAt = owner, nil
+	if !to.Terminal() {
+		order.ClaimedBy = ""
+	}
+	order.Status = string(to)
+	return true, nil
+}
+
+func (r *fakeRepository) ReleaseOrders(ctx context.Context, owner string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var released int64
+	for _, order := range r.orders {
+		if common.OrderStatus(order.Status).Leased() && order.ClaimedBy == owner {
+			release(order, "运行结束时仍在提交中，结果未知")
+			released++
+		}
+	}
+	return released, nil
+}
+
+func (r *fakeRepository) FindStaleOrders(ctx context.Context, before string) ([]*common.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []*common.Order
+	for _, order := range r.orders {
+		if order.Date < before && !common.OrderStatus(order.Status).Terminal() {
+			copied := *order
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (r *fakeRepository) CountSucceededOrders(ctx context.Context, filter common.SucceededFilter) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, order := range r.orders {
+		if (len(filter.Forms) > 0 && !slices.Contains(filter.Forms, order.Form)) || order.Status != string(common.OrderStatusSuccess) ||
+			(filter.Date != "" && order.Date != filter.Date) || (filter.Venue != 0 && order.Venue != filter.Venue) {
+			continue
+		}
+		succeededAt := order.UpdatedAt
+		if order.SucceededAt != nil {
+			succeededAt = *order.SucceededAt
+		}
+		if (!filter.Since.IsZero() && succeededAt.Before(filter.Since)) || (!filter.Until.IsZero() && !succeededAt.Before(filter.Until)) {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (r *fakeRepository) SaveCatalogSnapshot(ctx context.Context, snapshot *common.CatalogSnapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot.ID = uint(len(r.snapshots) + 1)
+	r.snapshots = append(r.snapshots, snapshot)
+	return nil
+}
+
+func (r *fakeRepository) LatestCatalogSnapshot(ctx context.Context, form string) (*common.CatalogSnapshot, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.snapshots) - 1; i >= 0; i-- {
+		if r.snapshots[i].Form == form {
+			return r.snapshots[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRepository) CatalogDates(ctx context.Context, form string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := make(map[string]bool)
+	var dates []string
+	for _, snapshot := range r.snapshots {
+		for _, slot := range snapshot.Slots {
+			if snapshot.Form == form && !seen[slot.Date] {
+				seen[slot.Date] = true
+				dates = append(dates, slot.Date)
+			}
+		}
+	}
+	sort.Strings(dates)
+	return dates, nil
+}
+
+func (r *fakeRepository) CreateCatalogEvents(ctx context.Context, events []*common.CatalogEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *fakeRepository) SaveSlotFills(ctx context.Context, fills []*common.SlotFill) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fills = append(r.fills, fills...)
+	return nil
+}
+
+func (r *fakeRepository) ListSlotFills(ctx context.Context, form string) ([]*common.SlotFill, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []*common.SlotFill
+	for _, fill := range r.fills {
+		if fill.Form == form {
+			result = append(result, fill)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeRepository) CreateLog(ctx context.Context, level common.LogLevel, message string, orderID *int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, string(level)+" "+message)
+	return nil
+}
+
+func (r *fakeRepository) CreateLogf(ctx context.Context, level common.LogLevel, orderID *int, format string, args ...any) error {
+	return r.CreateLog(ctx, level, fmt.Sprintf(format, args...), orderID)
+}
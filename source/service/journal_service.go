@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"sports_order/common"
+)
+
+// ReplayOutcome 是补写一条结果日志记录的结果。
+type ReplayOutcome string
+
+const (
+	ReplayApplied   ReplayOutcome = "APPLIED"   // 订单状态已按记录补写
+	ReplayUnchanged ReplayOutcome = "UNCHANGED" // 订单已是记录中的状态，只补写日志
+	ReplayConflict  ReplayOutcome = "CONFLICT"  // 订单已进入其他终态（如手动取消），保留数据库中的状态
+	ReplayPending   ReplayOutcome = "PENDING"   // 数据库仍不可用或订单正被其他进程处理，留待下次启动
+)
+
+// ReplayedEntry 是一条结果日志记录的补写结果。
+type ReplayedEntry struct {
+	Entry   *common.JournalEntry
+	Outcome ReplayOutcome
+	Err     error
+}
+
+// ReplayJournal 将结果日志中尚未写入数据库的记录补写到 orders 与 logs 表：
+// 订单状态按记录变更（见 Repository.ReconcileOrder），并以记录时的运行 ID 补写订单日志。
+// 有记录未能补写时返回错误：此时不应处理订单，避免已预约成功的订单被再次提交。
+func ReplayJournal(ctx context.Context, repo common.Repository, journal common.OutcomeJournal) ([]ReplayedEntry, error) {
+	var replayed []ReplayedEntry
+	pending := 0
+	for _, entry := range journal.Pending() {
+		result := ReplayedEntry{Entry: entry, Outcome: ReplayApplied}
+		orderID := int(entry.OrderID)
+		entryCtx := common.WithRunID(ctx, entry.RunID)
+
+		var cause error
+		if entry.Error != "" {
+			cause = errors.New(entry.Error)
+		}
+		changed, err := repo.ReconcileOrder(ctx, entry.OrderID, entry.Owner, entry.Status, cause)
+		var transitionErr *common.TransitionError
+		switch {
+		case errors.As(err, &transitionErr):
+			result.Outcome, result.Err = ReplayConflict, err
+			repo.CreateLogf(ctx, common.LogLevelWarn, &orderID, "结果日志 %s：订单 %d 已为 %s，未补写为 %s（%s 于 %s 记录: %s）",
+				entry.ID, entry.OrderID, transitionErr.From, entry.Status, entry.RunID, entry.At.Local().Format("2006-01-02 15:04:05"), entry.Message)
+		case err != nil:
+			result.Outcome, result.Err = ReplayPending, err
+			pending++
+			replayed = append(replayed, result)
+			continue
+		case !changed:
+			result.Outcome = ReplayUnchanged
+		}
+
+		if result.Outcome != ReplayConflict {
+			message := fmt.Sprintf("%s（由结果日志补写，记录于 %s）", entry.Message, entry.At.Local().Format("2006-01-02 15:04:05"))
+			if err := repo.CreateLog(entryCtx, entry.Level, message, &orderID); err != nil {
+				result.Outcome, result.Err = ReplayPending, err
+				pending++
+				replayed = append(replayed, result)
+				continue
+			}
+		}
+		if err := journal.Applied(entry.ID); err != nil {
+			return replayed, err
+		}
+		replayed = append(replayed, result)
+	}
+	if pending > 0 {
+		return replayed, fmt.Errorf("结果日志中有 %d 条提交结果未能补写到数据库", pending)
+	}
+	return replayed, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sports_order/common"
+	"sports_order/journal"
+	"sports_order/vcr"
+)
+
+// TestJournalReplaysFailedWrite 预约成功后数据库写入失败，订单不会停留在可再次认领的状态：
+// 结果留在结果日志中，下次启动时补写为 SUCCESS；数据库仍不可用时补写失败并保留记录。
+func TestJournalReplaysFailedWrite(t *testing.T) {
+	form := common.DefaultFormConfig()
+	cassette := sampleCassette(t, form)
+	cassette.Interactions = append(cassette.Interactions[:2:2],
+		vcr.Interaction{Method: http.MethodPost, URL: form.FormDataURL(), StatusCode: http.StatusOK, ResponseBody: `{"code":0,"message":"ok"}`})
+
+	order := &common.Order{ID: 1, Date: "2025-12-22", Hour: 21, Venue: 1, Form: form.Name, Status: string(common.OrderStatusPending)}
+	repo := newFakeRepository(order)
+	dbDown := errors.New("database is locked")
+	repo.failTransitions = map[common.OrderStatus]error{common.OrderStatusSuccess: dbDown}
+
+	path := filepath.Join(t.TempDir(), "sports-order.db.journal")
+	outcomes, err := journal.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	processor := NewOrderProcessor(vcr.NewReplayer(cassette), repo, &common.Config{})
+	processor.now = sampleNow
+	processor.SetJournal(outcomes)
+
+	ctx := common.WithRunID(context.Background(), "run-1")
+	result, err := processor.ProcessOrdersForDate(ctx, order.Date)
+	if err != nil {
+		t.Fatalf("ProcessOrdersForDate: %v", err)
+	}
+	if r := result.Orders[0]; r.Outcome != OutcomeSucceeded || r.Persisted {
+		t.Fatalf("result = %+v, want SUCCEEDED but not persisted", r)
+	}
+	// 运行结束时订单被退回 UNKNOWN（结果未知，不会被再次认领），成功结果只存在于结果日志中
+	if order.Status != string(common.OrderStatusUnknown) {
+		t.Fatalf("order status = %s, want UNKNOWN after release", order.Status)
+	}
+	outcomes.Close()
+
+	// 重启后数据库仍不可用：补写失败，记录保留
+	outcomes, err = journal.Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer outcomes.Close()
+	replayed, err := ReplayJournal(context.Background(), repo, outcomes)
+	if err == nil || len(replayed) != 1 || replayed[0].Outcome != ReplayPending {
+		t.Fatalf("replay with database down = %+v, %v; want PENDING and an error", replayed, err)
+	}
+	if len(outcomes.Pending()) != 1 {
+		t.Fatalf("pending = %d, want entry kept", len(outcomes.Pending()))
+	}
+
+	// 数据库恢复后补写
+	repo.failTransitions = nil
+	replayed, err = ReplayJournal(context.Background(), repo, outcomes)
+	if err != nil {
+		t.Fatalf("ReplayJournal: %v", err)
+	}
+	if len(replayed) != 1 || replayed[0].Outcome != ReplayApplied {
+		t.Fatalf("replayed = %+v, want APPLIED", replayed)
+	}
+	if order.Status != string(common.OrderStatusSuccess) || order.ClaimedBy == "" {
+		t.Fatalf("order = %s by %q, want SUCCESS by the original owner", order.Status, order.ClaimedBy)
+	}
+	if len(outcomes.Pending()) != 0 {
+		t.Fatalf("pending = %+v, want none", outcomes.Pending())
+	}
+	if !strings.Contains(strings.Join(repo.logs, "\n"), "订单 1 预约成功（由结果日志补写") {
+		t.Errorf("replayed log missing: %v", repo.logs)
+	}
+}
+
+// TestJournalReplayConflict 订单已被手动取消时保留数据库中的状态，记录不再补写。
+func TestJournalReplayConflict(t *testing.T) {
+	order := &common.Order{ID: 1, Date: "2025-12-22", Hour: 21, Status: string(common.OrderStatusCancelled)}
+	repo := newFakeRepository(order)
+	outcomes, err := journal.Open(filepath.Join(t.TempDir(), "journal"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer outcomes.Close()
+	outcomes.Record(&common.JournalEntry{OrderID: 1, RunID: "run-1", Status: common.OrderStatusSuccess,
+		Level: common.LogLevelInfo, Message: "订单 1 预约成功"})
+
+	replayed, err := ReplayJournal(context.Background(), repo, outcomes)
+	if err != nil {
+		t.Fatalf("ReplayJournal: %v", err)
+	}
+	if len(replayed) != 1 || replayed[0].Outcome != ReplayConflict {
+		t.Fatalf("replayed = %+v, want CONFLICT", replayed)
+	}
+	if order.Status != string(common.OrderStatusCancelled) || len(outcomes.Pending()) != 0 {
+		t.Fatalf("order = %s, pending = %d; want CANCELLED kept and entry resolved", order.Status, len(outcomes.Pending()))
+	}
+}
@@ -1,92 +1,478 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"sync"
+	"sort"
+	"time"
 
 	"sports_order/common"
 )
 
-// maxConcurrentOrders 用于限制同时处理订单的并发数。
-const maxConcurrentOrders = 10
-
 // OrderProcessor 负责从数据库取单、并发执行预约、并落订单状态与日志。
 type OrderProcessor struct {
-	repo           common.Repository
-	bookingService *BookingService
+	apiClient common.APIClient
+	repo      common.Repository
+	config    *common.Config
+	journal   common.OutcomeJournal // 提交结果先落盘再写库，为空时不使用
+	now       func() time.Time      // 当前时间，测试中可替换
 }
 
 // NewOrderProcessor 创建订单处理服务。
 func NewOrderProcessor(
 	apiClient common.APIClient,
 	repo common.Repository,
-	user *common.User,
+	config *common.Config,
 ) *OrderProcessor {
-	booking := NewBookingService(apiClient, repo, user)
 	return &OrderProcessor{
-		repo:           repo,
-		bookingService: booking,
+		apiClient: apiClient,
+		repo:      repo,
+		config:    config,
+		now:       time.Now,
 	}
 }
 
-// ProcessOrdersForDate 并发处理某一天所有待预约订单。
-func (s *OrderProcessor) ProcessOrdersForDate(targetDate string) error {
-	// 查询指定日期下待处理订单
-	orders, err := s.repo.FindOrdersByDate(targetDate)
+// SetJournal 设置本地结果日志：订单提交后的状态与日志先写入 journal，再写数据库。
+func (s *OrderProcessor) SetJournal(journal common.OutcomeJournal) {
+	s.journal = journal
+}
+
+// ProcessOrdersForDate 并发处理某一天所有待预约订单，返回每个订单的处理结果。
+// 每个表单在账号额度内按优先级选择要提交的订单（见 PlanOrders），超出额度的退回 PENDING。
+// 订单先被认领（SCHEDULED，带租约），并发运行的其他进程不会处理同一订单；处理期间定期续期。
+// 订单按所属表单分组，每个表单各自拉取一次元数据；提交前转为 IN_PROGRESS。
+// ctx 被取消时不再发起新的预约，未开始的订单退回 PENDING，提交中的转为 UNKNOWN（不再自动提交）。
+// 返回的 error 为第一个阻止订单提交的错误（认领失败、目录获取失败、表单不可用、运行被中断等），
+// 单个订单的提交失败只记录在结果中。
+func (s *OrderProcessor) ProcessOrdersForDate(ctx context.Context, targetDate string) (*RunResult, error) {
+	result := &RunResult{Date: targetDate}
+	owner := common.LeaseOwner(ctx)
+	lease := s.lease()
+
+	// 回收认领者崩溃后遗留的过期租约
+	recovered, err := s.repo.RecoverExpiredLeases(ctx, targetDate)
 	if err != nil {
-		return fmt.Errorf("查询订单失败: %v", err)
+		return result, fmt.Errorf("回收过期租约失败: %v", err)
+	}
+	for _, order := range recovered {
+		orderID := int(order.ID)
+		if order.Status == string(common.OrderStatusInProgress) {
+			s.repo.CreateLogf(ctx, common.LogLevelWarn, &orderID, "订单 %d 提交中时租约过期（原认领者 %s），转为 UNKNOWN，不再自动提交；请核对是否已预约成功后执行 orders resolve",
+				order.ID, order.ClaimedBy)
+			continue
+		}
+		s.repo.CreateLogf(ctx, common.LogLevelWarn, &orderID, "订单 %d 的租约已过期（原认领者 %s），退回 PENDING", order.ID, order.ClaimedBy)
+	}
+
+	// 认领指定日期下待处理订单
+	orders, err := s.repo.ClaimOrders(ctx, targetDate, owner, lease)
+	if err != nil {
+		return result, fmt.Errorf("认领订单失败: %v", err)
 	}
 
 	if len(orders) == 0 {
-		s.repo.CreateLogf(common.LogLevelInfo, nil, "无订单: %s", targetDate)
-		return nil
+		s.repo.CreateLogf(ctx, common.LogLevelInfo, nil, "无订单: %s", targetDate)
+		return result, nil
+	}
+
+	s.repo.CreateLogf(ctx, common.LogLevelInfo, nil, "认领 %d 个订单，日期: %s，认领者: %s", len(orders), targetDate, owner)
+
+	// 结束时仍未落状态的订单（落库失败等）退回待处理
+	defer s.releaseOrders(ctx, owner)
+	ctx, stopRenew := s.keepLeases(ctx, owner, lease)
+	defer stopRenew()
+
+	// 按解析后的表单名称分组：form 为空的订单与默认表单是同一个表单，共用目录与预约额度；
+	// 无法解析的表单按原值分组，随后整组失败
+	groups := make(map[string][]*common.Order)
+	var formNames []string
+	for _, order := range orders {
+		name := order.Form
+		if form, err := s.config.Form(order.Form); err == nil {
+			name = form.Name
+		}
+		if _, exists := groups[name]; !exists {
+			formNames = append(formNames, name)
+		}
+		groups[name] = append(groups[name], order)
+	}
+	// 最高优先级的订单所在的表单先拉取目录、先提交
+	sort.SliceStable(formNames, func(i, j int) bool {
+		return topPriority(groups[formNames[i]]) > topPriority(groups[formNames[j]])
+	})
+
+	// 所有表单共享一个 worker 池，按 run.concurrency 限制总并发及每个账号、每个场地的并发
+	pool := NewWorkerPool(s.config.Run.Concurrency)
+	pool.Start(ctx)
+
+	// 本次使用的目录（含版本漂移后重新拉取的），预约结束后保存快照（避免开抢时写库拖慢提交）
+	trackers := make(map[string]*catalogTracker)
+	var trackerNames []string
+
+	var firstErr error
+	for _, name := range formNames {
+		if ctx.Err() != nil {
+			s.interruptOrders(ctx, result, groups[name], name, owner)
+			continue
+		}
+
+		form, err := s.config.Form(name)
+		if err != nil {
+			s.failOrders(ctx, result, groups[name], name, owner, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		booking := NewBookingService(s.apiClient, s.repo, &s.config.User, form)
+
+		// 获取表单配置（版本号、日期/时段/场地映射等）。
+		catalogData, err := booking.GetCatalogData(ctx)
+		if err != nil {
+			s.repo.CreateLogf(ctx, common.LogLevelError, nil, "获取预约元数据失败 (表单 %s): %v", form.Name, err)
+			if ctx.Err() != nil {
+				s.interruptOrders(ctx, result, groups[name], form.Name, owner)
+			} else {
+				s.deferOrders(ctx, result, groups[name], form.Name, owner, err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		// 表单暂停、过期或已截止时不发起预约，订单退回 PENDING，避免全部落 FAILED
+		if err := catalogData.Profile.CheckAvailable(s.now()); err != nil {
+			s.repo.CreateLogf(ctx, common.LogLevelError, nil, "表单 %s 不可预约，跳过 %d 个订单: %v", form.Name, len(groups[name]), err)
+			s.deferOrders(ctx, result, groups[name], form.Name, owner, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		// 图片已过期时提交必然失败，同样跳过；即将过期只告警
+		warning, err := catalogData.Profile.CheckImage(s.config.User.ImageURL, s.now(), s.imageWarnBefore())
+		if err != nil {
+			s.repo.CreateLogf(ctx, common.LogLevelError, nil, "表单 %s 跳过 %d 个订单: %v", form.Name, len(groups[name]), err)
+			s.deferOrders(ctx, result, groups[name], form.Name, owner, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if warning != "" {
+			s.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "表单 %s: %s", form.Name, warning)
+		}
+
+		tracker := newCatalogTracker(booking, catalogData)
+		trackers[form.Name] = tracker
+		trackerNames = append(trackerNames, form.Name)
+
+		// 按优先级在账号额度内选择要提交的订单，超出额度的退回 PENDING 并说明原因
+		limit, err := accountLimit(ctx, s.repo, form, catalogData, targetDate)
+		if err != nil {
+			s.repo.CreateLogf(ctx, common.LogLevelError, nil, "表单 %s 跳过 %d 个订单: %v", form.Name, len(groups[name]), err)
+			s.deferOrders(ctx, result, groups[name], form.Name, owner, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		attempt, limited := PlanOrders(groups[name], limit)
+		if len(limited) > 0 {
+			cause := limitError(limit, attempt, limited)
+			s.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "表单 %s 提交 %d 个订单，跳过 %d 个: %v", form.Name, len(attempt), len(limited), cause)
+			s.limitOrders(ctx, result, limited, form.Name, owner, cause)
+		}
+
+		// 并发处理每一条订单
+		jobs := make([]*PoolJob, 0, len(attempt))
+		for _, order := range attempt {
+			jobs = append(jobs, &PoolJob{
+				Priority: order.Priority,
+				Account:  s.config.User.Identity(),
+				Venue:    fmt.Sprintf("%s/%d", form.Name, order.Venue),
+				Run: func(ctx context.Context, waited time.Duration) {
+					// 排队期间被取消的订单由 processSingleOrder 退回 PENDING
+					r := s.processSingleOrder(ctx, booking, order, owner, tracker)
+					r.QueueWait = waited
+					result.add(r)
+				},
+			})
+		}
+		pool.Submit(jobs...)
+	}
+
+	// 等待全部订单处理完成
+	pool.Close()
+	pool.Wait()
+	if stats := pool.Stats(); stats.Jobs > 0 {
+		s.repo.CreateLogf(ctx, common.LogLevelInfo, nil, "订单排队: 共 %d 个，同时提交最多 %d 个，等待中位 %v，最长 %v",
+			stats.Jobs, stats.Peak, stats.MedianWait.Round(time.Millisecond), stats.MaxWait.Round(time.Millisecond))
+	}
+	sort.Slice(result.Orders, func(i, j int) bool { return result.Orders[i].OrderID < result.Orders[j].OrderID })
+
+	// 保存目录快照并记录变化
+	history := NewCatalogHistory(s.repo)
+	for _, form := range trackerNames {
+		tracker := trackers[form]
+		for _, change := range tracker.History() {
+			s.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "表单 %s 版本在运行中变化: %d -> %d (%s，原因: %s)",
+				form, change.From, change.To, change.At.Format("15:04:05.000"), change.Reason)
+		}
+		if _, err := history.Record(context.WithoutCancel(ctx), form, tracker.Current()); err != nil {
+			s.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "记录目录快照失败 (表单 %s): %v", form, err)
+		}
+	}
+
+	var interrupted []uint
+	for _, order := range result.Orders {
+		if order.Outcome == OutcomeInterrupted {
+			interrupted = append(interrupted, order.OrderID)
+		}
+	}
+	if len(interrupted) > 0 {
+		s.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "运行被中断 (%v)，%d 个订单未完成: %v",
+			context.Cause(ctx), len(interrupted), interrupted)
+		if firstErr == nil {
+			firstErr = fmt.Errorf("运行被中断: %w", ctx.Err())
+		}
+	}
+
+	return result, firstErr
+}
+
+// imageWarnBefore 返回图片到期前开始告警的提前量。
+func (s *OrderProcessor) imageWarnBefore() time.Duration {
+	days := s.config.Run.ImageWarnDays
+	if days <= 0 {
+		days = common.DefaultImageWarnDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// lease 返回订单认领租约时长。
+func (s *OrderProcessor) lease() time.Duration {
+	sec := s.config.Run.LeaseSec
+	if sec <= 0 {
+		sec = common.DefaultLeaseSec
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// keepLeases 在处理期间每三分之一租约为 owner 持有的订单续期一次。
+// 续期持续失败直到租约即将到期时，以 ErrLeaseLost 取消返回的 ctx：订单可能已被其他进程接管，不能再提交。
+// 返回的 stop 函数停止续期。
+func (s *OrderProcessor) keepLeases(ctx context.Context, owner string, lease time.Duration) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		interval := lease / 3
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		renewedAt := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if _, err := s.repo.RenewLeases(ctx, owner, lease); err != nil {
+				s.repo.CreateLogf(ctx, common.LogLevelWarn, nil, "订单租约续期失败: %v", err)
+				if time.Since(renewedAt) >= lease-interval {
+					cancel(common.ErrLeaseLost)
+					return
+				}
+				continue
+			}
+			renewedAt = time.Now()
+		}
+	}()
+
+	return ctx, func() {
+		close(done)
+		<-stopped
+		cancel(nil)
 	}
+}
 
-	s.repo.CreateLogf(common.LogLevelInfo, nil, "找到 %d 个订单，日期: %s", len(orders), targetDate)
-	// 获取表单配置（版本号、日期/时段/场地映射等）。
-	catalogData, err := s.bookingService.GetCatalogData()
+// releaseOrders 退回 owner 仍持有的订单（状态落库失败等遗留的认领）：未开始的回到 PENDING，提交中的转为 UNKNOWN。
+func (s *OrderProcessor) releaseOrders(ctx context.Context, owner string) {
+	released, err := s.repo.ReleaseOrders(context.WithoutCancel(ctx), owner)
 	if err != nil {
-		s.repo.CreateLogf(common.LogLevelError, nil, "获取预约元数据失败: %v", err)
-		return err
+		// 租约到期后订单会被下一次运行回收
+		s.repo.CreateLogf(ctx, common.LogLevelError, nil, "释放订单认领失败: %v", err)
+		return
+	}
+	if released > 0 {
+		s.repo.CreateLogf(ctx, common.LogLevelInfo, nil, "%d 个未完成的订单退回待处理", released)
 	}
+}
 
-	// 使用信号量限制并发
-	semaphore := make(chan struct{}, maxConcurrentOrders)
-	var wg sync.WaitGroup
+// transitionOrder 变更订单状态，cause 非空时记为 last_error；状态不随 ctx 取消，
+// 变更失败（订单已被其他进程接管等）时记录错误并返回 false。
+func (s *OrderProcessor) transitionOrder(ctx context.Context, order *common.Order, owner string, status common.OrderStatus, cause error) bool {
+	if err := s.repo.TransitionOrder(context.WithoutCancel(ctx), order.ID, owner, status, cause); err != nil {
+		orderID := int(order.ID)
+		s.repo.CreateLogf(ctx, common.LogLevelError, &orderID, "订单 %d 状态 %s 落库失败: %v", order.ID, status, err)
+		return false
+	}
+	return true
+}
 
-	// 并发处理每一条订单
+// deferOrders 将本次未能提交的一组订单退回 PENDING，并把原因记为 last_error，
+// 订单过期时据此说明未被提交的原因（见 OrderSweeper）。
+func (s *OrderProcessor) deferOrders(ctx context.Context, result *RunResult, orders []*common.Order, form, owner string, cause error) {
 	for _, order := range orders {
-		wg.Add(1)
-		go func(order *common.Order, data *common.CatalogData) {
-			defer wg.Done()
-			// 获取并发令牌
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }() // 释放并发令牌
+		persisted := s.transitionOrder(ctx, order, owner, common.OrderStatusPending, cause)
+		result.add(newOrderResult(order, form).finish(OutcomeDeferred, common.OrderStatusPending, persisted, cause))
+	}
+}
 
-			s.processSingleOrder(order, data)
-		}(order, catalogData)
+// limitOrders 将因超出账号额度而不提交的订单退回 PENDING，last_error 说明额度与优先提交的订单。
+func (s *OrderProcessor) limitOrders(ctx context.Context, result *RunResult, orders []*common.Order, form, owner string, cause error) {
+	for _, order := range orders {
+		orderID := int(order.ID)
+		persisted := s.transitionOrder(ctx, order, owner, common.OrderStatusPending, cause)
+		s.repo.CreateLogf(ctx, common.LogLevelWarn, &orderID, "订单 %d（优先级 %d）未提交: %v", order.ID, order.Priority, cause)
+		result.add(newOrderResult(order, form).finish(OutcomeLimited, common.OrderStatusPending, persisted, cause))
 	}
+}
 
-	// 等待全部订单处理完成
-	wg.Wait()
+// interruptOrders 将因运行被取消而未开始的订单退回 PENDING。
+func (s *OrderProcessor) interruptOrders(ctx context.Context, result *RunResult, orders []*common.Order, form, owner string) {
+	cause := context.Cause(ctx)
+	for _, order := range orders {
+		persisted := s.transitionOrder(ctx, order, owner, common.OrderStatusPending, cause)
+		result.add(newOrderResult(order, form).finish(OutcomeInterrupted, common.OrderStatusPending, persisted, cause))
+	}
+}
 
-	return nil
+// failOrders 将无法处理的一组订单落 FAILED（例如订单引用了未配置的表单）。
+func (s *OrderProcessor) failOrders(ctx context.Context, result *RunResult, orders []*common.Order, form, owner string, cause error) {
+	for _, order := range orders {
+		orderID := int(order.ID)
+		persisted := s.transitionOrder(ctx, order, owner, common.OrderStatusFailed, cause)
+		s.repo.CreateLogf(ctx, common.LogLevelError, &orderID, "订单 %d 失败: %v", order.ID, cause)
+		result.add(newOrderResult(order, form).finish(OutcomeFailed, common.OrderStatusFailed, persisted, cause))
+	}
 }
 
-// processSingleOrder 尝试处理单条订单：失败落 FAILED，成功落 SUCCESS。
-func (s *OrderProcessor) processSingleOrder(order *common.Order, data *common.CatalogData) {
+// processSingleOrder 尝试处理单条订单：提交前转为 IN_PROGRESS，失败落 FAILED，成功落 SUCCESS。
+// 未开始即被中断的订单退回 PENDING；提交被中断时无法确认服务端是否已受理，转为 UNKNOWN。
+func (s *OrderProcessor) processSingleOrder(ctx context.Context, booking *BookingService, order *common.Order, owner string, tracker *catalogTracker) *OrderResult {
 	orderID := int(order.ID)
-	s.repo.CreateLogf(common.LogLevelInfo, &orderID, "开始预约订单 %d: %s %d:00-%d:00 场地 %d",
-		order.ID, order.Date, order.Hour, order.Hour+1, order.Venue)
+	result := newOrderResult(order, booking.Form().Name)
+	if ctx.Err() != nil {
+		s.repo.CreateLogf(ctx, common.LogLevelWarn, &orderID, "订单 %d 未开始即被中断", order.ID)
+		cause := context.Cause(ctx)
+		persisted := s.transitionOrder(ctx, order, owner, common.OrderStatusPending, cause)
+		return result.finish(OutcomeInterrupted, common.OrderStatusPending, persisted, cause)
+	}
+
+	if err := s.repo.TransitionOrder(context.WithoutCancel(ctx), order.ID, owner, common.OrderStatusInProgress, nil); err != nil {
+		s.repo.CreateLogf(ctx, common.LogLevelError, &orderID, "订单 %d 状态 %s 落库失败，不提交: %v", order.ID, common.OrderStatusInProgress, err)
+		return result.finish(OutcomeSkipped, common.OrderStatus(order.Status), false, err)
+	}
+	s.repo.CreateLogf(ctx, common.LogLevelInfo, &orderID, "开始预约订单 %d: [%s] %s %d:00-%d:00 场地 %d",
+		order.ID, booking.Form().Name, order.Date, order.Hour, order.Hour+1, order.Venue)
 
 	// 执行预约
-	err := s.bookingService.BookTimeSlot(data, common.BookingSlot{Date: order.Date, Hour: order.Hour, Venue: order.Venue})
-	if err != nil {
-		s.repo.UpdateOrderStatus(order.ID, common.OrderStatusFailed)
-		s.repo.CreateLogf(common.LogLevelError, &orderID, "订单 %d 失败: %v", order.ID, err)
-	} else {
-		// 预约成功
-		s.repo.UpdateOrderStatus(order.ID, common.OrderStatusSuccess)
-		s.repo.CreateLogf(common.LogLevelInfo, &orderID, "订单 %d 预约成功", order.ID)
+	start := time.Now()
+	err := s.bookWithVersionRetry(ctx, booking, order, tracker)
+	result.Latency = time.Since(start)
+
+	// 状态落库不随 ctx 取消，避免已完成的预约结果丢失
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// 请求被取消，无法确认服务端是否已受理，转为 UNKNOWN 留待人工核对，不会被再次认领
+		persisted := s.recordOutcome(ctx, order, owner, common.OrderStatusUnknown, err, common.LogLevelWarn,
+			"订单 %d 预约被中断，无法确认是否已受理，请核对后执行 orders resolve: %v", order.ID, err)
+		return result.finish(OutcomeInterrupted, common.OrderStatusUnknown, persisted, err)
+
+	case errors.Is(err, common.ErrFormUnavailable):
+		// 重试时发现表单已关闭，订单转为 RETRYING 等待下次运行
+		persisted := s.recordOutcome(ctx, order, owner, common.OrderStatusRetrying, err, common.LogLevelError, "订单 %d 未提交: %v", order.ID, err)
+		return result.finish(OutcomeDeferred, common.OrderStatusRetrying, persisted, err)
+
+	case err != nil:
+		persisted := s.recordOutcome(ctx, order, owner, common.OrderStatusFailed, err, common.LogLevelError, "订单 %d 失败: %v", order.ID, err)
+		return result.finish(OutcomeFailed, common.OrderStatusFailed, persisted, err)
+
+	default:
+		persisted := s.recordOutcome(ctx, order, owner, common.OrderStatusSuccess, nil, common.LogLevelInfo, "订单 %d 预约成功", order.ID)
+		return result.finish(OutcomeSucceeded, common.OrderStatusSuccess, persisted, nil)
+	}
+}
+
+// recordOutcome 落订单提交后的状态并写日志。设置了结果日志时先写入结果日志，写库成功后再标记已写入；
+// 写库失败（数据库不可用、租约被接管等）的结果在下次启动时由 ReplayJournal 补写。
+func (s *OrderProcessor) recordOutcome(ctx context.Context, order *common.Order, owner string, status common.OrderStatus, cause error,
+	level common.LogLevel, format string, args ...any) bool {
+	orderID := int(order.ID)
+	message := fmt.Sprintf(format, args...)
+
+	var entry *common.JournalEntry
+	if s.journal != nil {
+		entry = &common.JournalEntry{OrderID: order.ID, Owner: owner, RunID: common.RunIDFrom(ctx), Status: status, Level: level, Message: message}
+		if cause != nil {
+			entry.Error = cause.Error()
+		}
+		if err := s.journal.Record(entry); err != nil {
+			s.repo.CreateLogf(ctx, common.LogLevelError, &orderID, "订单 %d 的提交结果写入结果日志失败: %v", order.ID, err)
+			entry = nil
+		}
+	}
+
+	persisted := s.transitionOrder(ctx, order, owner, status, cause)
+	logErr := s.repo.CreateLog(ctx, level, message, &orderID)
+	if entry != nil && persisted && logErr == nil {
+		if err := s.journal.Applied(entry.ID); err != nil {
+			s.repo.CreateLogf(ctx, common.LogLevelWarn, &orderID, "标记结果日志 %s 已写入失败: %v", entry.ID, err)
+		}
+	}
+	return persisted
+}
+
+// bookWithVersionRetry 使用当前目录提交预约；若服务端因表单版本变化而拒绝，
+// 重新拉取目录后重试，最多 MaxVersionRetries 次。
+func (s *OrderProcessor) bookWithVersionRetry(ctx context.Context, booking *BookingService, order *common.Order, tracker *catalogTracker) error {
+	orderID := int(order.ID)
+	slot := common.BookingSlot{Date: order.Date, Hour: order.Hour, Venue: order.Venue}
+
+	for attempt := 0; ; attempt++ {
+		data := tracker.Current()
+		err := booking.BookTimeSlot(ctx, data, slot)
+		if err == nil || attempt >= common.MaxVersionRetries || ctx.Err() != nil {
+			return err
+		}
+
+		drift, rejected := isVersionDrift(err)
+		if !rejected {
+			return err
+		}
+		if !drift {
+			// 拒绝原因未提示版本问题时，向 profile 核对一次版本号
+			if drift, _ = tracker.Drifted(ctx, data, err.Error()); !drift {
+				return err
+			}
+		} else if _, refreshErr := tracker.Refresh(ctx, data, err.Error()); refreshErr != nil {
+			return fmt.Errorf("%w（%v）", err, refreshErr)
+		}
+
+		current := tracker.Current()
+		if err := current.Profile.CheckAvailable(s.now()); err != nil {
+			return err
+		}
+		s.repo.CreateLogf(ctx, common.LogLevelWarn, &orderID, "订单 %d 提交时表单版本 %d 已过期，使用版本 %d 重试",
+			order.ID, data.FormVersion, current.FormVersion)
 	}
 }
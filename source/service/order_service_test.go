@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"sports_order/common"
+	"sports_order/vcr"
+)
+
+// TestLeasedOrderNotProcessed 其他进程持有未过期租约的订单不会被再次提交。
+func TestLeasedOrderNotProcessed(t *testing.T) {
+	form := common.DefaultFormConfig()
+	expiresAt := time.Now().Add(time.Minute)
+	order := &common.Order{ID: 1, Date: "2025-12-22", Hour: 21, Venue: 1, Form: form.Name,
+		Status: string(common.OrderStatusInProgress), ClaimedBy: "other:1:run", LeaseExpiresAt: &expiresAt}
+	repo := newFakeRepository(order)
+	replayer := vcr.NewReplayer(sampleCassette(t, form))
+	processor := NewOrderProcessor(replayer, repo, &common.Config{})
+	processor.now = sampleNow
+
+	result, err := processor.ProcessOrdersForDate(context.Background(), order.Date)
+	if err != nil {
+		t.Fatalf("ProcessOrdersForDate: %v", err)
+	}
+	if got := result.Overall(); got != RunNoOrders {
+		t.Errorf("Overall() = %s, want %s", got, RunNoOrders)
+	}
+	if len(replayer.Matched) != 0 {
+		t.Fatalf("matched %d interactions, want none", len(replayer.Matched))
+	}
+	if order.Status != string(common.OrderStatusInProgress) || order.ClaimedBy != "other:1:run" {
+		t.Fatalf("order = %s by %s, want untouched", order.Status, order.ClaimedBy)
+	}
+}
+
+// TestExpiredLeaseRecovered 认领者崩溃留下的过期租约被回收：未开始的订单由本进程重新认领并完成，
+// 提交中的订单结果未知，转为 UNKNOWN，本次与之后的运行都不再提交。
+func TestExpiredLeaseRecovered(t *testing.T) {
+	form := common.DefaultFormConfig()
+	cassette := sampleCassette(t, form)
+	cassette.Interactions = append(cassette.Interactions[:2:2],
+		vcr.Interaction{Method: http.MethodPost, URL: form.FormDataURL(), StatusCode: http.StatusOK, ResponseBody: `{"code":0,"message":"ok"}`})
+
+	expiredAt := time.Now().Add(-time.Minute)
+	scheduled := &common.Order{ID: 1, Date: "2025-12-22", Hour: 20, Venue: 1, Form: form.Name,
+		Status: string(common.OrderStatusScheduled), ClaimedBy: "crashed:1:run", LeaseExpiresAt: &expiredAt}
+	submitting := &common.Order{ID: 2, Date: "2025-12-22", Hour: 21, Venue: 1, Form: form.Name,
+		Status: string(common.OrderStatusInProgress), ClaimedBy: "crashed:1:run", LeaseExpiresAt: &expiredAt}
+	repo := newFakeRepository(scheduled, submitting)
+	replayer := vcr.NewReplayer(cassette)
+	processor := NewOrderProcessor(replayer, repo, &common.Config{})
+	processor.now = sampleNow
+
+	for _, run := range []string{"run-2", "run-3"} {
+		ctx := common.WithRunID(context.Background(), run)
+		result, err := processor.ProcessOrdersForDate(ctx, scheduled.Date)
+		if err != nil {
+			t.Fatalf("%s: ProcessOrdersForDate: %v", run, err)
+		}
+		for _, r := range result.Orders {
+			if r.OrderID == submitting.ID {
+				t.Fatalf("%s: order of unknown outcome processed again: %+v", run, r)
+			}
+		}
+	}
+	if scheduled.Status != string(common.OrderStatusSuccess) || !strings.HasSuffix(scheduled.ClaimedBy, ":run-2") {
+		t.Fatalf("scheduled order = %s by %s, want SUCCESS by run-2", scheduled.Status, scheduled.ClaimedBy)
+	}
+	if submitting.Status != string(common.OrderStatusUnknown) || submitting.ClaimedBy != "" || submitting.LeaseExpiresAt != nil {
+		t.Fatalf("submitting order = %s by %q, want released UNKNOWN", submitting.Status, submitting.ClaimedBy)
+	}
+	posts := 0
+	for _, interaction := range replayer.Matched {
+		if interaction.Method == http.MethodPost {
+			posts++
+		}
+	}
+	if posts != 1 {
+		t.Errorf("posts = %d, want 1", posts)
+	}
+	if !strings.Contains(strings.Join(repo.logs, "\n"), "crashed:1:run") {
+		t.Errorf("recovery of crashed:1:run not logged: %v", repo.logs)
+	}
+}
+
+// cancelingClient 是模拟提交中收到 SIGINT 的 APIClient：GET 按 URL 回放 cassette，POST 取消运行后返回 ctx 的错误。
+type cancelingClient struct {
+	cassette *vcr.Cassette
+	cancel   context.CancelFunc
+	posts    int
+}
+
+func (c *cancelingClient) Get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	for _, interaction := range c.cassette.Interactions {
+		if interaction.URL == url {
+			return []byte(interaction.ResponseBody), nil
+		}
+	}
+	return nil, fmt.Errorf("no interaction for %s", url)
+}
+
+func (c *cancelingClient) Post(ctx context.Context, url string, data []byte, auth string, headers map[string]string) ([]byte, error) {
+	c.posts++
+	c.cancel()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestInterruptedRun 提交中被取消的订单转为 UNKNOWN，排队中未开始的订单退回 PENDING，运行返回中断错误。
+func TestInterruptedRun(t *testing.T) {
+	form := common.DefaultFormConfig()
+	first := &common.Order{ID: 1, Date: "2025-12-22", Hour: 20, Venue: 1, Form: form.Name, Status: string(common.OrderStatusPending)}
+	second := &common.Order{ID: 2, Date: "2025-12-22", Hour: 21, Venue: 2, Form: form.Name, Status: string(common.OrderStatusPending)}
+	repo := newFakeRepository(first, second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &cancelingClient{cassette: sampleCassette(t, form), cancel: cancel}
+	config := &common.Config{Run: common.RunConfig{Concurrency: common.ConcurrencyConfig{Global: 1}}}
+	processor := NewOrderProcessor(client, repo, config)
+	processor.now = sampleNow
+
+	result, err := processor.ProcessOrdersForDate(ctx, "2025-12-22")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if client.posts != 1 {
+		t.Fatalf("posts = %d, want 1", client.posts)
+	}
+	if got := result.Overall(); got != RunNotAttempted {
+		t.Errorf("Overall() = %s, want %s", got, RunNotAttempted)
+	}
+	if result.Count(OutcomeInterrupted) != 2 {
+		t.Errorf("results = %+v, want both INTERRUPTED", result.Orders)
+	}
+	if first.Status != string(common.OrderStatusUnknown) || first.ClaimedBy != "" {
+		t.Errorf("submitting order = %s by %q, want released UNKNOWN", first.Status, first.ClaimedBy)
+	}
+	if second.Status != string(common.OrderStatusPending) || second.ClaimedBy != "" {
+		t.Errorf("queued order = %s by %q, want released PENDING", second.Status, second.ClaimedBy)
+	}
+}
+
+// TestCanceledBeforeCatalog 运行开始前已被取消时不拉取目录，认领的订单全部退回 PENDING。
+func TestCanceledBeforeCatalog(t *testing.T) {
+	form := common.DefaultFormConfig()
+	order := &common.Order{ID: 1, Date: "2025-12-22", Hour: 20, Venue: 1, Form: form.Name, Status: string(common.OrderStatusPending)}
+	repo := newFakeRepository(order)
+	replayer := vcr.NewReplayer(sampleCassette(t, form))
+	processor := NewOrderProcessor(replayer, repo, &common.Config{})
+	processor.now = sampleNow
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := processor.ProcessOrdersForDate(ctx, order.Date)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(replayer.Matched) != 0 {
+		t.Errorf("matched %d interactions, want none", len(replayer.Matched))
+	}
+	if len(result.Orders) != 1 || result.Orders[0].Outcome != OutcomeInterrupted || !result.Orders[0].Persisted {
+		t.Fatalf("result = %+v, want one persisted INTERRUPTED order", result.Orders)
+	}
+	if order.Status != string(common.OrderStatusPending) {
+		t.Errorf("order status = %s, want PENDING", order.Status)
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"sports_order/common"
+)
+
+// AccountLimit 是账号在某表单上本次还能预约的场次。
+type AccountLimit struct {
+	Remaining int                // 剩余场次，-1 表示不限
+	Reason    string             // 额度的来源与已用情况，如 "每人每天最多 2 场，当日已成功 1 场"
+	Venues    map[int]VenueLimit // 场地号 -> 该场地的剩余场次（场地选项的 LIMIT），不限的场地不在其中
+}
+
+// VenueLimit 是账号当天在某个场地还能预约的场次。
+type VenueLimit struct {
+	Remaining int
+	Reason    string // 如 "场地 1号 每人每天最多 1 场，当日已成功 1 场"
+}
+
+// Unlimited 判断是否不限场次。
+func (l AccountLimit) Unlimited() bool {
+	return l.Remaining < 0
+}
+
+// accountLimit 计算账号在表单上对 date 的剩余额度：表单 profile 的每人提交次数上限（perLimit，统计活动期内
+// 预约成功的订单）与配置的每天场次上限（forms.<name>.max_per_day）取较小者；另按 catalog 中场地选项的 LIMIT
+// 限制当天每个场地的场次。已成功的订单按表单在订单中的全部写法统计（见 FormConfig.OrderForms）。
+func accountLimit(ctx context.Context, repo common.Repository, form *common.FormConfig, catalog *common.CatalogData, date string) (AccountLimit, error) {
+	limit := AccountLimit{Remaining: -1}
+	count := func(filter common.SucceededFilter) (int, error) {
+		filter.Forms = form.OrderForms()
+		succeeded, err := repo.CountSucceededOrders(ctx, filter)
+		if err != nil {
+			return 0, fmt.Errorf("统计表单 %s 已预约成功的订单失败: %v", form.Name, err)
+		}
+		return int(succeeded), nil
+	}
+	apply := func(most int, filter common.SucceededFilter, reason string) error {
+		if most <= 0 {
+			return nil
+		}
+		succeeded, err := count(filter)
+		if err != nil {
+			return err
+		}
+		remaining := max(0, most-succeeded)
+		if limit.Unlimited() || remaining < limit.Remaining {
+			limit = AccountLimit{Remaining: remaining, Reason: fmt.Sprintf(reason, most, succeeded)}
+		}
+		return nil
+	}
+
+	if profile := catalog.Profile; profile != nil {
+		begin, end := profile.ActivityWindow()
+		if err := apply(profile.Config.PerLimit, common.SucceededFilter{Since: begin, Until: end}, "表单每人最多提交 %d 次，活动期内已成功 %d 场"); err != nil {
+			return limit, err
+		}
+	}
+	if err := apply(form.MaxPerDay, common.SucceededFilter{Date: date}, "每人每天最多 %d 场，当日已成功 %d 场"); err != nil {
+		return limit, err
+	}
+
+	for i, venue := range catalog.Venues {
+		if venue.Limit <= 0 {
+			continue
+		}
+		succeeded, err := count(common.SucceededFilter{Date: date, Venue: i + 1})
+		if err != nil {
+			return limit, err
+		}
+		if limit.Venues == nil {
+			limit.Venues = make(map[int]VenueLimit)
+		}
+		limit.Venues[i+1] = VenueLimit{
+			Remaining: max(0, venue.Limit-succeeded),
+			Reason:    fmt.Sprintf("场地 %s 每人每天最多 %d 场，当日已成功 %d 场", venue.Name, venue.Limit, succeeded),
+		}
+	}
+	return limit, nil
+}
+
+// sortByPriority 按优先级从高到低排列订单，相同时按时段、订单 ID。
+func sortByPriority(orders []*common.Order) {
+	sort.SliceStable(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Hour != b.Hour {
+			return a.Hour < b.Hour
+		}
+		return a.ID < b.ID
+	})
+}
+
+// topPriority 返回一组订单中的最高优先级。
+func topPriority(orders []*common.Order) int {
+	top := 0
+	for i, order := range orders {
+		if i == 0 || order.Priority > top {
+			top = order.Priority
+		}
+	}
+	return top
+}
+
+// PlanOrders 按优先级决定本次提交哪些订单：优先级高的先占用账号与场地额度，超出额度的放入 limited。
+// 两组都按优先级排序；不限额度时 limited 为空。
+func PlanOrders(orders []*common.Order, limit AccountLimit) (attempt, limited []*common.Order) {
+	sorted := append([]*common.Order(nil), orders...)
+	sortByPriority(sorted)
+	remaining := limit.Remaining
+	venues := make(map[int]int, len(limit.Venues))
+	for venue, l := range limit.Venues {
+		venues[venue] = l.Remaining
+	}
+	for _, order := range sorted {
+		left, capped := venues[order.Venue]
+		if remaining == 0 || (capped && left == 0) {
+			limited = append(limited, order)
+			continue
+		}
+		attempt = append(attempt, order)
+		if remaining > 0 {
+			remaining--
+		}
+		if capped {
+			venues[order.Venue] = left - 1
+		}
+	}
+	return attempt, limited
+}
+
+// limitError 说明订单因超出额度未被提交，以及占用额度的订单：场地额度已用完的订单给出场地的额度，其余给出账号的额度。
+func limitError(limit AccountLimit, attempt, limited []*common.Order) error {
+	var reasons []string
+	seen := make(map[string]bool)
+	for _, order := range limited {
+		reason := limit.Reason
+		if venue, ok := limit.Venues[order.Venue]; ok && venue.Remaining <= countVenue(attempt, order.Venue) {
+			reason = venue.Reason
+		}
+		if !seen[reason] {
+			seen[reason] = true
+			reasons = append(reasons, reason)
+		}
+	}
+	reason := strings.Join(reasons, "；")
+	if len(attempt) == 0 {
+		return fmt.Errorf("%w（%s），本次不提交", common.ErrAccountLimit, reason)
+	}
+	ids := make([]uint, len(attempt))
+	for i, order := range attempt {
+		ids[i] = order.ID
+	}
+	return fmt.Errorf("%w（%s），优先提交了订单 %v", common.ErrAccountLimit, reason, ids)
+}
+
+// countVenue 统计一组订单中某个场地的订单数。
+func countVenue(orders []*common.Order, venue int) int {
+	n := 0
+	for _, order := range orders {
+		if order.Venue == venue {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"sports_order/common"
+)
+
+// orderIDs 返回订单 ID 列表，便于比较。
+func orderIDs(orders []*common.Order) []uint {
+	ids := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	return ids
+}
+
+// TestPlanOrders 优先级高的订单先占用账号与场地额度，相同优先级按时段、订单 ID。
+func TestPlanOrders(t *testing.T) {
+	orders := []*common.Order{
+		{ID: 1, Hour: 21, Venue: 1, Priority: 0},
+		{ID: 2, Hour: 20, Venue: 1, Priority: 5},
+		{ID: 3, Hour: 20, Venue: 2, Priority: 0},
+		{ID: 4, Hour: 21, Venue: 2, Priority: 5},
+	}
+	tests := []struct {
+		remaining int
+		venues    map[int]VenueLimit
+		attempt   string
+		limited   string
+	}{
+		{-1, nil, "[2 4 3 1]", "[]"},
+		{2, nil, "[2 4]", "[3 1]"},
+		{0, nil, "[]", "[2 4 3 1]"},
+		{9, nil, "[2 4 3 1]", "[]"},
+		{-1, map[int]VenueLimit{1: {Remaining: 1}}, "[2 4 3]", "[1]"},
+		{3, map[int]VenueLimit{1: {Remaining: 0}, 2: {Remaining: 2}}, "[4 3]", "[2 1]"},
+	}
+	for _, tt := range tests {
+		attempt, limited := PlanOrders(orders, AccountLimit{Remaining: tt.remaining, Venues: tt.venues})
+		if got := fmt.Sprint(orderIDs(attempt)); got != tt.attempt {
+			t.Errorf("remaining %d, venues %v: attempt = %s, want %s", tt.remaining, tt.venues, got, tt.attempt)
+		}
+		if got := fmt.Sprint(orderIDs(limited)); got != tt.limited {
+			t.Errorf("remaining %d, venues %v: limited = %s, want %s", tt.remaining, tt.venues, got, tt.limited)
+		}
+	}
+}
+
+// TestProcessorLimitsByAccount 当日已成功的订单占用 max_per_day 额度，只提交优先级最高的订单，
+// 其余退回 PENDING 并在结果与 last_error 中说明。form 为空的订单与默认表单同属一组，共用额度。
+func TestProcessorLimitsByAccount(t *testing.T) {
+	form := common.DefaultFormConfig()
+	form.MaxPerDay = 2
+	pending := func(id uint, hour, venue, priority int) *common.Order {
+		return &common.Order{ID: id, Date: "2025-12-22", Hour: hour, Venue: venue, Form: form.Name,
+			Priority: priority, Status: string(common.OrderStatusPending)}
+	}
+	unnamed := pending(4, 21, 3, 1)
+	unnamed.Form = ""
+	repo := newFakeRepository(
+		&common.Order{ID: 1, Date: "2025-12-22", Hour: 20, Venue: 1, Status: string(common.OrderStatusSuccess)},
+		pending(2, 20, 2, 0),
+		pending(3, 21, 2, 7),
+		unnamed,
+	)
+	client := &concurrencyClient{cassette: sampleCassette(t, form)}
+	config := &common.Config{Forms: map[string]*common.FormConfig{form.Name: form}}
+	processor := NewOrderProcessor(client, repo, config)
+	processor.now = sampleNow
+
+	result, err := processor.ProcessOrdersForDate(context.Background(), "2025-12-22")
+	if err != nil {
+		t.Fatalf("ProcessOrdersForDate: %v", err)
+	}
+	if client.posts != 1 {
+		t.Fatalf("posts = %d, want 1", client.posts)
+	}
+	if got := result.Overall(); got != RunSucceeded {
+		t.Errorf("Overall() = %s, want SUCCEEDED", got)
+	}
+
+	want := map[uint]Outcome{2: OutcomeLimited, 3: OutcomeSucceeded, 4: OutcomeLimited}
+	for _, r := range result.Orders {
+		if r.Outcome != want[r.OrderID] {
+			t.Errorf("order %d: outcome = %s, want %s", r.OrderID, r.Outcome, want[r.OrderID])
+		}
+		if r.Outcome == OutcomeLimited && (r.ErrorClass != common.ErrorClassLimit || !strings.Contains(r.Error, "优先提交了订单 [3]")) {
+			t.Errorf("order %d: error = %s %q, want limit explanation", r.OrderID, r.ErrorClass, r.Error)
+		}
+	}
+	for _, id := range []uint{2, 4} {
+		order := repo.orders[id]
+		if order.Status != string(common.OrderStatusPending) || !strings.Contains(order.LastError, common.ErrAccountLimit.Error()) {
+			t.Errorf("order %d: status = %s, last_error = %q; want PENDING with limit reason", id, order.Status, order.LastError)
+		}
+	}
+}
+
+// TestAccountLimit 取 profile 每人上限（只计活动期内成功的订单）与 max_per_day 扣除已成功订单后的较小者，
+// 并按场地选项的 LIMIT 计算当天每个场地的剩余场次。
+func TestAccountLimit(t *testing.T) {
+	form := common.DefaultFormConfig()
+	succeededAt := func(value string) *time.Time {
+		at, err := time.ParseInLocation(common.ProfileTimeLayout, value, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &at
+	}
+	repo := newFakeRepository(
+		&common.Order{ID: 1, Date: "2025-12-21", Venue: 1, Form: form.Name, Status: string(common.OrderStatusSuccess), SucceededAt: succeededAt("2025-12-20 10:00:00")},
+		&common.Order{ID: 2, Date: "2025-12-22", Venue: 1, Form: form.Name, Status: string(common.OrderStatusSuccess), SucceededAt: succeededAt("2025-12-21 10:00:00")},
+		&common.Order{ID: 3, Date: "2025-12-22", Venue: 2, Form: form.Name, Status: string(common.OrderStatusFailed)},
+		&common.Order{ID: 4, Date: "2025-09-01", Venue: 2, Form: form.Name, Status: string(common.OrderStatusSuccess), SucceededAt: succeededAt("2025-08-30 10:00:00")},
+	)
+	catalog := &common.CatalogData{
+		Profile: &common.FormProfile{Config: common.FormProfileConfig{
+			ActBeginTime: "2025-12-01 00:00:00", ActEndTime: "2025-12-31 00:00:00", PerLimit: -1,
+		}},
+		Venues: []common.VenueInfo{{Name: "1号", Limit: 1}, {Name: "2号", Limit: 2}, {Name: "3号"}},
+	}
+
+	tests := []struct {
+		perLimit, maxPerDay int
+		remaining           int
+	}{
+		{-1, 0, -1},
+		{-1, 3, 2},
+		{3, 0, 1},
+		{3, 3, 1},
+		{1, 0, 0},
+		{4, 0, 2}, // 活动开始前成功的订单 4 不计入
+	}
+	for _, tt := range tests {
+		catalog.Profile.Config.PerLimit, form.MaxPerDay = tt.perLimit, tt.maxPerDay
+		limit, err := accountLimit(context.Background(), repo, form, catalog, "2025-12-22")
+		if err != nil {
+			t.Fatalf("accountLimit: %v", err)
+		}
+		if limit.Remaining != tt.remaining {
+			t.Errorf("perLimit %d, max_per_day %d: remaining = %d (%s), want %d",
+				tt.perLimit, tt.maxPerDay, limit.Remaining, limit.Reason, tt.remaining)
+		}
+		if len(limit.Venues) != 2 || limit.Venues[1].Remaining != 0 || limit.Venues[2].Remaining != 2 {
+			t.Errorf("venues = %+v, want 1号 used up and 2号 with 2 left", limit.Venues)
+		}
+	}
+
+	limit := AccountLimit{Remaining: 2, Reason: "account", Venues: map[int]VenueLimit{1: {Remaining: 1, Reason: "venue 1"}}}
+	attempt := []*common.Order{{ID: 1, Venue: 1}, {ID: 2, Venue: 2}}
+	limited := []*common.Order{{ID: 3, Venue: 1}, {ID: 4, Venue: 3}}
+	err := limitError(limit, attempt, limited)
+	if !errors.Is(err, common.ErrAccountLimit) || !strings.Contains(err.Error(), "venue 1；account") {
+		t.Errorf("limitError = %v, want ErrAccountLimit with venue and account reasons", err)
+	}
+}
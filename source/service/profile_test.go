@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sports_order/common"
+	"sports_order/vcr"
+)
+
+func TestSampleProfileParsed(t *testing.T) {
+	form := common.DefaultFormConfig()
+	booking := NewBookingService(vcr.NewReplayer(sampleCassette(t, form)), nil, &common.User{}, form)
+	data, err := booking.GetCatalogData(context.Background())
+	if err != nil {
+		t.Fatalf("GetCatalogData: %v", err)
+	}
+
+	profile := data.Profile
+	if profile.Title != "羽毛球馆场地预约（个人）" || profile.Status != common.FormStatusActive || profile.Config.ActEndTime != "2025-12-31 00:11:00" {
+		t.Fatalf("profile = %+v", profile)
+	}
+	if err := profile.CheckAvailable(sampleNow()); err != nil {
+		t.Fatalf("CheckAvailable before deadline: %v", err)
+	}
+	if err := profile.CheckAvailable(time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local)); !errors.Is(err, common.ErrFormUnavailable) {
+		t.Fatalf("CheckAvailable after deadline = %v, want ErrFormUnavailable", err)
+	}
+}
+
+// TestPausedFormKeepsOrdersPending 表单暂停时不提交预约，订单保持 PENDING。
+func TestPausedFormKeepsOrdersPending(t *testing.T) {
+	form := common.DefaultFormConfig()
+	cassette := sampleCassette(t, form)
+	cassette.Interactions[0].ResponseBody = strings.Replace(cassette.Interactions[0].ResponseBody, `"status": 2`, `"status": 3`, 1)
+
+	order := &common.Order{ID: 1, Date: "2025-12-22", Hour: 21, Venue: 1, Form: form.Name, Status: string(common.OrderStatusPending)}
+	repo := newFakeRepository(order)
+	replayer := vcr.NewReplayer(cassette)
+	processor := NewOrderProcessor(replayer, repo, &common.Config{})
+	processor.now = sampleNow
+
+	result, err := processor.ProcessOrdersForDate(context.Background(), order.Date)
+	if !errors.Is(err, common.ErrFormUnavailable) {
+		t.Fatalf("ProcessOrdersForDate err = %v, want ErrFormUnavailable", err)
+	}
+	if got := result.Overall(); got != RunNotAttempted {
+		t.Errorf("Overall() = %s, want %s", got, RunNotAttempted)
+	}
+	if r := result.Orders[0]; r.Outcome != OutcomeDeferred || r.ErrorClass != common.ErrorClassFormUnavailable || r.Status != common.OrderStatusPending {
+		t.Errorf("result = %+v, want DEFERRED / FORM_UNAVAILABLE / PENDING", r)
+	}
+	if order.Status != string(common.OrderStatusPending) || order.ClaimedBy != "" || order.LeaseExpiresAt != nil {
+		t.Fatalf("order = %s by %q (lease %v), want released to PENDING", order.Status, order.ClaimedBy, order.LeaseExpiresAt)
+	}
+	if !strings.Contains(order.LastError, common.ErrFormUnavailable.Error()) {
+		t.Errorf("last_error = %q, want the form unavailable reason", order.LastError)
+	}
+	if len(replayer.Matched) != 2 {
+		t.Fatalf("matched %d interactions, want only profile+catalog", len(replayer.Matched))
+	}
+}
+
+func TestCheckImageLifeCycle(t *testing.T) {
+	form := common.DefaultFormConfig()
+	booking := NewBookingService(vcr.NewReplayer(sampleCassette(t, form)), nil, &common.User{}, form)
+	profile, err := booking.GetProfile(context.Background())
+	if err != nil {
+		t.Fatalf("GetProfile: %v", err)
+	}
+	day := 24 * time.Hour
+
+	// fileStatus -2 视为已过期
+	_, err = profile.CheckImage("https://oss2.qun100.com/ZDg5/V2/form1/qR3n_Fhrs9C6591c3b7.jpg", sampleNow(), 7*day)
+	if !errors.Is(err, common.ErrImageExpired) {
+		t.Fatalf("expired image err = %v, want ErrImageExpired", err)
+	}
+
+	valid := "https://oss2.qun100.com/x/V2/form1/qbq7lqHseqa2a68d88f.png?x-oss-process=style"
+	if warning, err := profile.CheckImage(valid, sampleNow(), 7*day); warning != "" || err != nil {
+		t.Fatalf("valid image = %q, %v; want no warning", warning, err)
+	}
+	expireAt := time.UnixMilli(1791604408000)
+	if warning, err := profile.CheckImage(valid, expireAt.Add(-3*day), 7*day); warning == "" || err != nil {
+		t.Fatalf("image expiring in 3 days = %q, %v; want warning", warning, err)
+	}
+	if _, err := profile.CheckImage(valid, expireAt.Add(time.Minute), 7*day); !errors.Is(err, common.ErrImageExpired) {
+		t.Fatalf("image past expireAt err = %v, want ErrImageExpired", err)
+	}
+
+	if warning, err := profile.CheckImage("https://example.com/unknown.jpg", sampleNow(), 7*day); warning == "" || err != nil {
+		t.Fatalf("unknown image = %q, %v; want warning", warning, err)
+	}
+}
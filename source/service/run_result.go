@@ -0,0 +1,116 @@
+package service
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"sports_order/common"
+)
+
+// Outcome 是单个订单在一次运行中的结果。
+type Outcome string
+
+const (
+	OutcomeSucceeded   Outcome = "SUCCEEDED"   // 预约成功
+	OutcomeFailed      Outcome = "FAILED"      // 提交失败或无法处理，订单落 FAILED
+	OutcomeDeferred    Outcome = "DEFERRED"    // 未提交（表单不可用、图片过期、目录获取失败），留待下次运行
+	OutcomeInterrupted Outcome = "INTERRUPTED" // 运行被取消，订单退回 PENDING 或转为 UNKNOWN
+	OutcomeSkipped     Outcome = "SKIPPED"     // 订单已被其他进程接管等原因，未提交
+	OutcomeLimited     Outcome = "LIMITED"     // 超出账号预约额度，让位于优先级更高的订单，退回 PENDING
+)
+
+// OrderResult 是单个订单在一次运行中的处理结果。
+type OrderResult struct {
+	OrderID    uint               `json:"order_id"`
+	Form       string             `json:"form"`
+	Date       string             `json:"date"`
+	Hour       int                `json:"hour"`
+	Venue      int                `json:"venue"`
+	Outcome    Outcome            `json:"outcome"`
+	Status     common.OrderStatus `json:"status"`      // 处理后的订单状态（落库失败时为预期状态）
+	Persisted  bool               `json:"persisted"`   // 状态是否已落库
+	ErrorClass common.ErrorClass  `json:"error_class"` // 成功时为空
+	Error      string             `json:"error,omitempty"`
+	Latency    time.Duration      `json:"-"` // 提交耗时（含版本漂移重试），未提交时为 0
+	QueueWait  time.Duration      `json:"-"` // 在 worker 池中排队等待的时间
+}
+
+// MarshalJSON 以毫秒输出提交耗时与排队时间。
+func (r *OrderResult) MarshalJSON() ([]byte, error) {
+	type plain OrderResult
+	return json.Marshal(struct {
+		*plain
+		LatencyMs   int64 `json:"latency_ms"`
+		QueueWaitMs int64 `json:"queue_wait_ms"`
+	}{(*plain)(r), r.Latency.Milliseconds(), r.QueueWait.Milliseconds()})
+}
+
+// RunOutcome 是一次运行的总体结果。
+type RunOutcome string
+
+const (
+	RunNoOrders     RunOutcome = "NO_ORDERS"     // 没有需要处理的订单（或都因超出账号额度未提交）
+	RunSucceeded    RunOutcome = "SUCCEEDED"     // 全部订单预约成功
+	RunPartial      RunOutcome = "PARTIAL"       // 部分订单预约成功
+	RunFailed       RunOutcome = "FAILED"        // 没有订单预约成功，且至少有一个提交失败
+	RunNotAttempted RunOutcome = "NOT_ATTEMPTED" // 订单都未提交（表单不可用、被中断等）
+)
+
+// RunResult 是 ProcessOrdersForDate 的处理结果，按订单 ID 排序。
+type RunResult struct {
+	Date   string         `json:"date"`
+	Orders []*OrderResult `json:"orders"`
+
+	mu sync.Mutex
+}
+
+// add 记录一个订单的结果，可并发调用。
+func (r *RunResult) add(result *OrderResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Orders = append(r.Orders, result)
+}
+
+// Count 返回结果为 outcome 的订单数。
+func (r *RunResult) Count(outcome Outcome) int {
+	n := 0
+	for _, order := range r.Orders {
+		if order.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Overall 汇总各订单的结果。超出账号额度（LIMITED）的订单是按计划不提交的，不计入。
+func (r *RunResult) Overall() RunOutcome {
+	succeeded, failed := r.Count(OutcomeSucceeded), r.Count(OutcomeFailed)
+	planned := len(r.Orders) - r.Count(OutcomeLimited)
+	switch {
+	case planned == 0:
+		return RunNoOrders
+	case succeeded == planned:
+		return RunSucceeded
+	case succeeded > 0:
+		return RunPartial
+	case failed > 0:
+		return RunFailed
+	default:
+		return RunNotAttempted
+	}
+}
+
+// newOrderResult 以订单信息初始化结果。
+func newOrderResult(order *common.Order, form string) *OrderResult {
+	return &OrderResult{OrderID: order.ID, Form: form, Date: order.Date, Hour: order.Hour, Venue: order.Venue}
+}
+
+// finish 填写结果、落库后的状态与错误。
+func (r *OrderResult) finish(outcome Outcome, status common.OrderStatus, persisted bool, err error) *OrderResult {
+	r.Outcome, r.Status, r.Persisted = outcome, status, persisted
+	if err != nil {
+		r.ErrorClass, r.Error = common.ClassifyError(err), err.Error()
+	}
+	return r
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"sports_order/common"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want common.ErrorClass
+	}{
+		{nil, ""},
+		{&common.RejectionError{StatusCode: 200, Code: 1, Message: "已约满"}, common.ErrorClassRejected},
+		{fmt.Errorf("提交失败: %w", &common.SlotError{Message: "场地不存在"}), common.ErrorClassSlot},
+		{fmt.Errorf("表单 a: %w", common.ErrFormUnavailable), common.ErrorClassFormUnavailable},
+		{common.ErrImageExpired, common.ErrorClassImageExpired},
+		{fmt.Errorf("订单 1: %w", common.ErrLeaseLost), common.ErrorClassLeaseLost},
+		{fmt.Errorf("%w（每人每天最多 1 场）", common.ErrAccountLimit), common.ErrorClassLimit},
+		{fmt.Errorf("请求失败: %w", context.DeadlineExceeded), common.ErrorClassCanceled},
+		{fmt.Errorf("其他"), common.ErrorClassOther},
+	}
+	for _, tt := range tests {
+		if got := common.ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRunResultOverall(t *testing.T) {
+	tests := []struct {
+		outcomes []Outcome
+		want     RunOutcome
+	}{
+		{nil, RunNoOrders},
+		{[]Outcome{OutcomeSucceeded, OutcomeSucceeded}, RunSucceeded},
+		{[]Outcome{OutcomeSucceeded, OutcomeFailed}, RunPartial},
+		{[]Outcome{OutcomeSucceeded, OutcomeDeferred}, RunPartial},
+		{[]Outcome{OutcomeFailed, OutcomeInterrupted}, RunFailed},
+		{[]Outcome{OutcomeDeferred, OutcomeSkipped}, RunNotAttempted},
+		{[]Outcome{OutcomeSucceeded, OutcomeLimited}, RunSucceeded},
+		{[]Outcome{OutcomeLimited, OutcomeLimited}, RunNoOrders},
+	}
+	for _, tt := range tests {
+		result := &RunResult{}
+		for _, outcome := range tt.outcomes {
+			result.add(&OrderResult{Outcome: outcome})
+		}
+		if got := result.Overall(); got != tt.want {
+			t.Errorf("Overall(%v) = %s, want %s", tt.outcomes, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"sports_order/common"
+)
+
+// CatalogHistory 负责保存目录快照，并与上一次快照比较生成差异事件。
+type CatalogHistory struct {
+	repo common.Repository
+}
+
+// NewCatalogHistory 创建目录快照服务。
+func NewCatalogHistory(repo common.Repository) *CatalogHistory {
+	return &CatalogHistory{repo: repo}
+}
+
+// Record 保存本次拉取的目录快照，返回与上一次快照相比的差异事件（首次快照无事件）。
+// 事件会写入 catalog_events 表，并按类型写入日志：移除/改名/容量变化记为 WARN，其余为 INFO。
+func (h *CatalogHistory) Record(ctx context.Context, form string, data *common.CatalogData) ([]*common.CatalogEvent, error) {
+	previous, err := h.repo.LatestCatalogSnapshot(ctx, form)
+	if err != nil {
+		return nil, fmt.Errorf("查询上一次目录快照失败: %v", err)
+	}
+
+	current := BuildSnapshot(form, data, common.RunIDFrom(ctx), time.Now())
+	if err := h.repo.SaveCatalogSnapshot(ctx, current); err != nil {
+		return nil, fmt.Errorf("保存目录快照失败: %v", err)
+	}
+	if previous == nil {
+		return nil, nil
+	}
+
+	events := DiffSnapshots(previous, current)
+	for _, event := range events {
+		event.RunID = current.RunID
+		level := common.LogLevelInfo
+		switch common.CatalogEventType(event.Type) {
+		case common.EventDateRemoved, common.EventSlotRemoved, common.EventVenueRemoved,
+			common.EventVenueRenamed, common.EventCapacityChanged, common.EventFormVersionChanged:
+			level = common.LogLevelWarn
+		}
+		h.repo.CreateLogf(ctx, level, nil, "[目录变化][%s] %s", form, event.Message)
+	}
+	if err := h.repo.CreateCatalogEvents(ctx, events); err != nil {
+		return events, fmt.Errorf("保存目录变化事件失败: %v", err)
+	}
+	return events, nil
+}
+
+// BuildSnapshot 将解析后的目录数据规范化为快照。
+func BuildSnapshot(form string, data *common.CatalogData, runID string, fetchedAt time.Time) *common.CatalogSnapshot {
+	snapshot := &common.CatalogSnapshot{
+		Form:        form,
+		FormVersion: data.FormVersion,
+		RunID:       runID,
+		FetchedAt:   fetchedAt,
+	}
+
+	for i, venue := range data.Venues {
+		snapshot.Venues = append(snapshot.Venues, common.CatalogSnapshotVenue{
+			Position: i + 1,
+			Cid:      venue.Cid,
+			UUID:     venue.UUID,
+			Name:     venue.Name,
+		})
+	}
+
+	for _, date := range sortedKeys(data.DateMap) {
+		dateInfo := data.DateMap[date]
+		for _, hour := range sortedKeys(dateInfo.TimeMap) {
+			usage := dateInfo.Usage[hour]
+			for _, venueUUID := range sortedKeys(usage) {
+				snapshot.Slots = append(snapshot.Slots, common.CatalogSnapshotSlot{
+					Date:      date,
+					Hour:      hour,
+					VenueUUID: venueUUID,
+					Capacity:  usage[venueUUID].Limit,
+					UsedCount: usage[venueUUID].UsedCount,
+				})
+			}
+		}
+	}
+	return snapshot
+}
+
+// slotKey 标识某日期某时段。
+type slotKey struct {
+	date string
+	hour int
+}
+
+// venueSlotKey 标识某日期某时段某场地。
+type venueSlotKey struct {
+	slotKey
+	venue string
+}
+
+// DiffSnapshots 比较两次快照，按表单版本、场地、日期、时段、容量的顺序返回差异事件。
+func DiffSnapshots(old, cur *common.CatalogSnapshot) []*common.CatalogEvent {
+	var events []*common.CatalogEvent
+	emit := func(eventType common.CatalogEventType, date string, hour *int, venue, oldValue, newValue, message string) {
+		events = append(events, &common.CatalogEvent{
+			Form:           cur.Form,
+			Type:           string(eventType),
+			Date:           date,
+			Hour:           hour,
+			Venue:          venue,
+			OldValue:       oldValue,
+			NewValue:       newValue,
+			Message:        message,
+			FromSnapshotID: old.ID,
+			ToSnapshotID:   cur.ID,
+		})
+	}
+
+	// 表单版本
+	if old.FormVersion != cur.FormVersion {
+		emit(common.EventFormVersionChanged, "", nil, "", strconv.Itoa(old.FormVersion), strconv.Itoa(cur.FormVersion),
+			fmt.Sprintf("表单版本 %d -> %d", old.FormVersion, cur.FormVersion))
+	}
+
+	// 场地：按 UUID 匹配
+	oldVenues := make(map[string]common.CatalogSnapshotVenue)
+	for _, v := range old.Venues {
+		oldVenues[v.UUID] = v
+	}
+	curVenues := make(map[string]common.CatalogSnapshotVenue)
+	for _, v := range cur.Venues {
+		curVenues[v.UUID] = v
+		prev, existed := oldVenues[v.UUID]
+		switch {
+		case !existed:
+			emit(common.EventVenueAdded, "", nil, v.UUID, "", v.Name, fmt.Sprintf("新增场地 %s", v.Name))
+		case prev.Name != v.Name:
+			emit(common.EventVenueRenamed, "", nil, v.UUID, prev.Name, v.Name, fmt.Sprintf("场地改名 %s -> %s", prev.Name, v.Name))
+		}
+	}
+	for _, v := range old.Venues {
+		if _, exists := curVenues[v.UUID]; !exists {
+			emit(common.EventVenueRemoved, "", nil, v.UUID, v.Name, "", fmt.Sprintf("场地移除 %s", v.Name))
+		}
+	}
+
+	// 日期与时段
+	oldSlots, oldDates := indexSlots(old.Slots)
+	curSlots, curDates := indexSlots(cur.Slots)
+
+	for _, date := range sortedKeys(curDates) {
+		if !oldDates[date] {
+			emit(common.EventDateAdded, date, nil, "", "", date, fmt.Sprintf("新日期开放 %s", date))
+		}
+	}
+	for _, date := range sortedKeys(oldDates) {
+		if !curDates[date] {
+			emit(common.EventDateRemoved, date, nil, "", date, "", fmt.Sprintf("日期下线 %s", date))
+		}
+	}
+
+	hoursOf := func(slots map[venueSlotKey]common.CatalogSnapshotSlot) map[slotKey]bool {
+		hours := make(map[slotKey]bool)
+		for key := range slots {
+			hours[key.slotKey] = true
+		}
+		return hours
+	}
+	oldHours, curHours := hoursOf(oldSlots), hoursOf(curSlots)
+	for _, key := range sortedSlotKeys(curHours) {
+		if oldDates[key.date] && !oldHours[key] {
+			hour := key.hour
+			emit(common.EventSlotAdded, key.date, &hour, "", "", "", fmt.Sprintf("新增时段 %s %02d:00", key.date, key.hour))
+		}
+	}
+	for _, key := range sortedSlotKeys(oldHours) {
+		if curDates[key.date] && !curHours[key] {
+			hour := key.hour
+			emit(common.EventSlotRemoved, key.date, &hour, "", "", "", fmt.Sprintf("时段移除 %s %02d:00", key.date, key.hour))
+		}
+	}
+
+	// 容量：同一日期、时段、场地的 limit 变化
+	venueName := func(uuid string) string {
+		if v, ok := curVenues[uuid]; ok {
+			return v.Name
+		}
+		return uuid
+	}
+	for _, slot := range cur.Slots {
+		key := venueSlotKey{slotKey{slot.Date, slot.Hour}, slot.VenueUUID}
+		prev, existed := oldSlots[key]
+		if !existed || prev.Capacity == slot.Capacity {
+			continue
+		}
+		hour := slot.Hour
+		emit(common.EventCapacityChanged, slot.Date, &hour, slot.VenueUUID,
+			strconv.Itoa(prev.Capacity), strconv.Itoa(slot.Capacity),
+			fmt.Sprintf("容量变化 %s %02d:00 %s: %d -> %d", slot.Date, slot.Hour, venueName(slot.VenueUUID), prev.Capacity, slot.Capacity))
+	}
+
+	return events
+}
+
+// indexSlots 按日期/时段/场地索引快照明细，并返回出现过的日期集合。
+func indexSlots(slots []common.CatalogSnapshotSlot) (map[venueSlotKey]common.CatalogSnapshotSlot, map[string]bool) {
+	index := make(map[venueSlotKey]common.CatalogSnapshotSlot, len(slots))
+	dates := make(map[string]bool)
+	for _, slot := range slots {
+		index[venueSlotKey{slotKey{slot.Date, slot.Hour}, slot.VenueUUID}] = slot
+		dates[slot.Date] = true
+	}
+	return index, dates
+}
+
+// sortedKeys 返回 map 的有序键列表。
+func sortedKeys[K string | int, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// sortedSlotKeys 返回按日期、小时排序的时段列表。
+func sortedSlotKeys(m map[slotKey]bool) []slotKey {
+	keys := make([]slotKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].date != keys[j].date {
+			return keys[i].date < keys[j].date
+		}
+		return keys[i].hour < keys[j].hour
+	})
+	return keys
+}
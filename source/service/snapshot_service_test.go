@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sports_order/common"
+	"sports_order/vcr"
+)
+
+func TestBuildSnapshotFromSample(t *testing.T) {
+	form := common.DefaultFormConfig()
+	booking := NewBookingService(vcr.NewReplayer(sampleCassette(t, form)), nil, &common.User{}, form)
+	data, err := booking.GetCatalogData(context.Background())
+	if err != nil {
+		t.Fatalf("GetCatalogData: %v", err)
+	}
+
+	snapshot := BuildSnapshot(form.Name, data, "run", time.Now())
+	if len(snapshot.Venues) != 6 || snapshot.Venues[0].Name != "1号" || snapshot.Venues[0].Position != 1 {
+		t.Fatalf("venues = %+v, want 6 venues starting with 1号", snapshot.Venues)
+	}
+	if len(snapshot.Slots) == 0 || snapshot.Slots[0].Capacity != 1 {
+		t.Fatalf("slots = %d, first = %+v; want capacity 1", len(snapshot.Slots), snapshot.Slots)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	old := &common.CatalogSnapshot{
+		ID: 1, Form: "badminton", FormVersion: 245,
+		Venues: []common.CatalogSnapshotVenue{
+			{Position: 1, UUID: "a", Name: "1号"},
+			{Position: 2, UUID: "b", Name: "2号"},
+		},
+		Slots: []common.CatalogSnapshotSlot{
+			{Date: "2025-12-15", Hour: 8, VenueUUID: "a", Capacity: 1},
+			{Date: "2025-12-15", Hour: 9, VenueUUID: "a", Capacity: 1},
+			{Date: "2025-12-14", Hour: 8, VenueUUID: "a", Capacity: 1},
+		},
+	}
+	cur := &common.CatalogSnapshot{
+		ID: 2, Form: "badminton", FormVersion: 246,
+		Venues: []common.CatalogSnapshotVenue{
+			{Position: 1, UUID: "a", Name: "1号场"},
+			{Position: 2, UUID: "c", Name: "3号"},
+		},
+		Slots: []common.CatalogSnapshotSlot{
+			{Date: "2025-12-15", Hour: 8, VenueUUID: "a", Capacity: 2},
+			{Date: "2025-12-15", Hour: 10, VenueUUID: "a", Capacity: 1},
+			{Date: "2025-12-16", Hour: 8, VenueUUID: "a", Capacity: 1},
+		},
+	}
+
+	var got []string
+	for _, event := range DiffSnapshots(old, cur) {
+		got = append(got, event.Type)
+		if event.FromSnapshotID != 1 || event.ToSnapshotID != 2 {
+			t.Errorf("event %s snapshot ids = %d -> %d, want 1 -> 2", event.Type, event.FromSnapshotID, event.ToSnapshotID)
+		}
+	}
+	want := []string{
+		string(common.EventFormVersionChanged),
+		string(common.EventVenueRenamed),
+		string(common.EventVenueAdded),
+		string(common.EventVenueRemoved),
+		string(common.EventDateAdded),
+		string(common.EventDateRemoved),
+		string(common.EventSlotAdded),
+		string(common.EventSlotRemoved),
+		string(common.EventCapacityChanged),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+
+	if events := DiffSnapshots(cur, cur); len(events) != 0 {
+		t.Fatalf("identical snapshots produced %d events", len(events))
+	}
+}
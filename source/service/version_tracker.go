@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"sports_order/common"
+)
+
+// VersionChange 记录一次运行中观察到的表单版本变化。
+type VersionChange struct {
+	From   int
+	To     int
+	At     time.Time
+	Reason string // 触发重新拉取的原因（服务端拒绝信息）
+}
+
+// catalogTracker 持有某个表单当前使用的目录，并在检测到版本漂移时重新拉取。
+// 多个订单同时发现漂移时只拉取一次：调用方传入自己使用的旧目录，
+// 若该目录已被替换则直接返回新目录，正在拉取时等待其结果。拉取在锁外进行，不阻塞 Current 等调用。
+type catalogTracker struct {
+	booking *BookingService
+
+	mu       sync.Mutex
+	data     *common.CatalogData
+	check    *versionCheck // 最近一次 profile 版本核对，同一目录只核对一次
+	inflight *refreshCall  // 正在进行的重新拉取
+	history  []VersionChange
+}
+
+// versionCheck 是对某个目录的一次 profile 版本核对，done 关闭后 drifted、err 可读。
+type versionCheck struct {
+	stale   *common.CatalogData
+	done    chan struct{}
+	drifted bool
+	err     error
+}
+
+// refreshCall 是一次重新拉取，done 关闭后 data、err 可读。
+type refreshCall struct {
+	done chan struct{}
+	data *common.CatalogData
+	err  error
+}
+
+// newCatalogTracker 以首次拉取的目录创建跟踪器。
+func newCatalogTracker(booking *BookingService, data *common.CatalogData) *catalogTracker {
+	return &catalogTracker{booking: booking, data: data}
+}
+
+// Current 返回当前使用的目录。
+func (t *catalogTracker) Current() *common.CatalogData {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.data
+}
+
+// History 返回本次运行中的版本变化记录。
+func (t *catalogTracker) History() []VersionChange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]VersionChange(nil), t.history...)
+}
+
+// Refresh 在 stale 仍是当前目录时重新拉取，否则直接返回已被其他订单刷新的目录；
+// 其他订单正在拉取时等待其结果。
+func (t *catalogTracker) Refresh(ctx context.Context, stale *common.CatalogData, reason string) (*common.CatalogData, error) {
+	t.mu.Lock()
+	if t.data != stale {
+		data := t.data
+		t.mu.Unlock()
+		return data, nil
+	}
+	call := t.inflight
+	if call == nil {
+		call = &refreshCall{done: make(chan struct{})}
+		t.inflight = call
+		t.mu.Unlock()
+		t.refresh(ctx, call, reason)
+	} else {
+		t.mu.Unlock()
+	}
+
+	select {
+	case <-call.done:
+		return call.data, call.err
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+}
+
+// refresh 在锁外拉取目录，再在锁内替换当前目录并记录版本变化。
+func (t *catalogTracker) refresh(ctx context.Context, call *refreshCall, reason string) {
+	data, err := t.booking.GetCatalogData(ctx)
+
+	t.mu.Lock()
+	if err == nil {
+		t.history = append(t.history, VersionChange{
+			From:   t.data.FormVersion,
+			To:     data.FormVersion,
+			At:     time.Now(),
+			Reason: reason,
+		})
+		t.data = data
+	}
+	t.inflight = nil
+	t.mu.Unlock()
+
+	if err != nil {
+		err = fmt.Errorf("重新拉取目录失败: %v", err)
+	}
+	call.data, call.err = data, err
+	close(call.done)
+}
+
+// Drifted 在服务端拒绝原因无法识别时，核对 profile 中的版本是否已不同于 stale。
+// 版本已变化时顺带刷新目录。每个目录只核对一次，同时核对的订单等待同一结果。
+func (t *catalogTracker) Drifted(ctx context.Context, stale *common.CatalogData, reason string) (bool, error) {
+	t.mu.Lock()
+	if t.data != stale {
+		t.mu.Unlock()
+		return true, nil
+	}
+	check := t.check
+	if check == nil || check.stale != stale {
+		check = &versionCheck{stale: stale, done: make(chan struct{})}
+		t.check = check
+		t.mu.Unlock()
+		check.drifted, check.err = t.checkVersion(ctx, stale, reason)
+		close(check.done)
+	} else {
+		t.mu.Unlock()
+	}
+
+	select {
+	case <-check.done:
+		return check.drifted, check.err
+	case <-ctx.Done():
+		return false, context.Cause(ctx)
+	}
+}
+
+// checkVersion 在锁外请求 profile，版本与 stale 不同时重新拉取目录。
+func (t *catalogTracker) checkVersion(ctx context.Context, stale *common.CatalogData, reason string) (bool, error) {
+	version, err := t.booking.GetFormVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	if version == stale.FormVersion {
+		return false, nil
+	}
+	if _, err := t.Refresh(ctx, stale, reason); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isVersionDrift 判断预约失败是否需要核对表单版本：
+// 明确提示版本不一致时返回 (true, true)；其他业务拒绝返回 (false, true) 表示需向 profile 核对。
+func isVersionDrift(err error) (drift bool, rejected bool) {
+	if errors.Is(err, common.ErrFormVersionMismatch) {
+		return true, true
+	}
+	var rejection *common.RejectionError
+	return false, errors.As(err, &rejection)
+}
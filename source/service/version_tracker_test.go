@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sports_order/common"
+	"sports_order/vcr"
+)
+
+// TestVersionDriftRetry 模拟开抢时表单版本从 245 变为 246：首次提交被拒后重新拉取目录并重试成功。
+func TestVersionDriftRetry(t *testing.T) {
+	form := common.DefaultFormConfig()
+	cassette := sampleCassette(t, form)
+	profile, catalog := cassette.Interactions[0], cassette.Interactions[1]
+
+	refreshed := profile
+	refreshed.ResponseBody = strings.Replace(profile.ResponseBody, `"version": 245`, `"version": 246`, 1)
+	if refreshed.ResponseBody == profile.ResponseBody {
+		t.Fatal("profile 样本中未找到 version 字段")
+	}
+	cassette.Interactions = []vcr.Interaction{
+		profile, catalog,
+		{
+			Method:       http.MethodPost,
+			URL:          form.FormDataURL(),
+			StatusCode:   http.StatusUnprocessableEntity,
+			Status:       "422 Unprocessable Entity",
+			ResponseBody: `{"code":17001,"message":"表单已更新，请刷新后重试"}`,
+		},
+		refreshed, catalog,
+		{Method: http.MethodPost, URL: form.FormDataURL(), StatusCode: http.StatusOK, ResponseBody: `{"code":0,"message":"ok"}`},
+	}
+
+	order := &common.Order{ID: 1, Date: "2025-12-22", Hour: 21, Venue: 1, Form: form.Name, Status: string(common.OrderStatusPending)}
+	repo := newFakeRepository(order)
+	processor := NewOrderProcessor(vcr.NewReplayer(cassette), repo, &common.Config{})
+	processor.now = sampleNow
+
+	if _, err := processor.ProcessOrdersForDate(context.Background(), order.Date); err != nil {
+		t.Fatalf("ProcessOrdersForDate: %v", err)
+	}
+	if order.Status != string(common.OrderStatusSuccess) {
+		t.Fatalf("order status = %s, want SUCCESS; logs: %v", order.Status, repo.logs)
+	}
+	if len(repo.snapshots) != 1 || repo.snapshots[0].FormVersion != 246 {
+		t.Fatalf("snapshot should record refreshed version 246, got %+v", repo.snapshots)
+	}
+}
+
+// TestUnrecognizedRejectionChecksProfile 拒绝原因未提示版本问题且 profile 版本未变时，不重试。
+func TestUnrecognizedRejectionChecksProfile(t *testing.T) {
+	form := common.DefaultFormConfig()
+	cassette := sampleCassette(t, form)
+	cassette.Interactions = append(cassette.Interactions, cassette.Interactions[0])
+
+	order := &common.Order{ID: 1, Date: "2025-12-22", Hour: 21, Venue: 1, Form: form.Name, Status: string(common.OrderStatusPending)}
+	repo := newFakeRepository(order)
+	replayer := vcr.NewReplayer(cassette)
+	processor := NewOrderProcessor(replayer, repo, &common.Config{})
+	processor.now = sampleNow
+
+	result, _ := processor.ProcessOrdersForDate(context.Background(), order.Date)
+	if order.Status != string(common.OrderStatusFailed) {
+		t.Fatalf("order status = %s, want FAILED", order.Status)
+	}
+	if r := result.Orders[0]; r.Outcome != OutcomeFailed || r.ErrorClass != common.ErrorClassRejected || !r.Persisted || r.Latency <= 0 {
+		t.Errorf("result = %+v, want persisted FAILED / REJECTED with latency", r)
+	}
+	if got := result.Overall(); got != RunFailed {
+		t.Errorf("Overall() = %s, want %s", got, RunFailed)
+	}
+	if len(replayer.Matched) != 4 {
+		t.Fatalf("matched %d interactions, want profile+catalog+post+profile", len(replayer.Matched))
+	}
+}
+
+// blockingCatalogClient 回放 cassette 中的 GET，catalog 请求阻塞到 release 关闭。
+type blockingCatalogClient struct {
+	cassette *vcr.Cassette
+	form     *common.FormConfig
+	release  chan struct{}
+	catalogs atomic.Int32
+}
+
+func (c *blockingCatalogClient) Get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	if url == c.form.CatalogURL() {
+		c.catalogs.Add(1)
+		<-c.release
+	}
+	for _, interaction := range c.cassette.Interactions {
+		if interaction.URL == url {
+			return []byte(interaction.ResponseBody), nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *blockingCatalogClient) Post(ctx context.Context, url string, data []byte, auth string, headers map[string]string) ([]byte, error) {
+	return nil, nil
+}
+
+// TestTrackerRefreshOutsideLock 拉取目录期间 Current 不被阻塞，同时刷新的订单共用一次拉取的结果。
+func TestTrackerRefreshOutsideLock(t *testing.T) {
+	form := common.DefaultFormConfig()
+	client := &blockingCatalogClient{cassette: sampleCassette(t, form), form: form, release: make(chan struct{})}
+	stale := &common.CatalogData{FormVersion: 244}
+	tracker := newCatalogTracker(NewBookingService(client, nil, &common.User{}, form), stale)
+
+	var wg sync.WaitGroup
+	results := make([]*common.CatalogData, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := tracker.Refresh(context.Background(), stale, "test")
+			if err != nil {
+				t.Errorf("Refresh: %v", err)
+			}
+			results[i] = data
+		}()
+	}
+
+	for client.catalogs.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	current := make(chan *common.CatalogData)
+	go func() { current <- tracker.Current() }()
+	select {
+	case data := <-current:
+		if data != stale {
+			t.Errorf("Current() during refresh = %+v, want the stale catalog", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Current() blocked while the catalog was being fetched")
+	}
+
+	close(client.release)
+	wg.Wait()
+	if n := client.catalogs.Load(); n != 1 {
+		t.Errorf("catalog fetched %d times, want 1", n)
+	}
+	for i, data := range results {
+		if data == nil || data != tracker.Current() || data.FormVersion != 245 {
+			t.Errorf("result %d = %p, want the refreshed catalog %p", i, data, tracker.Current())
+		}
+	}
+	if history := tracker.History(); len(history) != 1 || history[0].From != 244 || history[0].To != 245 {
+		t.Errorf("history = %+v, want one change 244 -> 245", history)
+	}
+}
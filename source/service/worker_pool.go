@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"sports_order/common"
+)
+
+// PoolJob 是提交给 WorkerPool 的一项任务。
+type PoolJob struct {
+	Priority int    // 越大越先派发，相同时按提交顺序
+	Account  string // 预约身份（见 User.Identity），受 PerAccount 限制
+	Venue    string // 所属场地，受 PerVenue 限制
+	Run      func(ctx context.Context, waited time.Duration)
+
+	seq      int
+	enqueued time.Time
+}
+
+// WorkerPool 以固定数量的 worker 执行任务：每次派发队列中优先级最高、且所属账号与场地都未达到并发上限的任务。
+type WorkerPool struct {
+	limits common.ConcurrencyConfig
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []*PoolJob
+	seq      int
+	closed   bool
+	accounts map[string]int
+	venues   map[string]int
+	running  int
+	peak     int
+	waits    []time.Duration
+
+	wg sync.WaitGroup
+}
+
+// NewWorkerPool 按并发限制创建 worker 池，worker 数为 limits.GlobalLimit()。
+func NewWorkerPool(limits common.ConcurrencyConfig) *WorkerPool {
+	limits.Global = limits.GlobalLimit()
+	p := &WorkerPool{limits: limits, accounts: make(map[string]int), venues: make(map[string]int)}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Start 启动 worker。任务通过 Submit 加入，全部加入后调用 Close 并 Wait。
+func (p *WorkerPool) Start(ctx context.Context) {
+	for i := 0; i < p.limits.Global; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for {
+				job, waited := p.next()
+				if job == nil {
+					return
+				}
+				job.Run(ctx, waited)
+				p.finish(job)
+			}
+		}()
+	}
+}
+
+// Submit 加入一批任务；同一批任务按优先级派发。
+func (p *WorkerPool) Submit(jobs ...*PoolJob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for _, job := range jobs {
+		p.seq++
+		job.seq, job.enqueued = p.seq, now
+		p.queue = append(p.queue, job)
+	}
+	sort.SliceStable(p.queue, func(i, j int) bool {
+		if p.queue[i].Priority != p.queue[j].Priority {
+			return p.queue[i].Priority > p.queue[j].Priority
+		}
+		return p.queue[i].seq < p.queue[j].seq
+	})
+	p.cond.Broadcast()
+}
+
+// Close 表示不再提交任务，队列清空后 worker 退出。
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cond.Broadcast()
+}
+
+// Wait 等待全部任务完成（须先调用 Close）。
+func (p *WorkerPool) Wait() {
+	p.wg.Wait()
+}
+
+// next 取出下一个可执行的任务并占用其账号与场地的并发额度，没有任务且已关闭时返回 nil。
+func (p *WorkerPool) next() (*PoolJob, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		for i, job := range p.queue {
+			if !p.allowed(job) {
+				continue
+			}
+			p.queue = append(p.queue[:i:i], p.queue[i+1:]...)
+			p.accounts[job.Account]++
+			p.venues[job.Venue]++
+			p.running++
+			p.peak = max(p.peak, p.running)
+			waited := time.Since(job.enqueued)
+			p.waits = append(p.waits, waited)
+			return job, waited
+		}
+		// 队列非空时必有任务在运行，其结束后会唤醒
+		if p.closed && len(p.queue) == 0 {
+			return nil, 0
+		}
+		p.cond.Wait()
+	}
+}
+
+// allowed 判断任务所属账号与场地是否还有并发额度。
+func (p *WorkerPool) allowed(job *PoolJob) bool {
+	if p.limits.PerAccount > 0 && p.accounts[job.Account] >= p.limits.PerAccount {
+		return false
+	}
+	return p.limits.PerVenue <= 0 || p.venues[job.Venue] < p.limits.PerVenue
+}
+
+// finish 释放任务占用的并发额度。
+func (p *WorkerPool) finish(job *PoolJob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.accounts[job.Account]--
+	p.venues[job.Venue]--
+	p.running--
+	p.cond.Broadcast()
+}
+
+// PoolStats 是任务排队等待时间的汇总。
+type PoolStats struct {
+	Jobs       int
+	Peak       int // 同时运行的最大任务数
+	MedianWait time.Duration
+	MaxWait    time.Duration
+}
+
+// Stats 汇总已派发任务的排队等待时间。
+func (p *WorkerPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := PoolStats{Jobs: len(p.waits), Peak: p.peak}
+	if len(p.waits) == 0 {
+		return stats
+	}
+	waits := append([]time.Duration(nil), p.waits...)
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	stats.MedianWait, stats.MaxWait = waits[len(waits)/2], waits[len(waits)-1]
+	return stats
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sports_order/common"
+	"sports_order/vcr"
+)
+
+// TestWorkerPoolLimits 同时运行的任务数不超过总数、每个账号与每个场地的上限。
+func TestWorkerPoolLimits(t *testing.T) {
+	limits := common.ConcurrencyConfig{Global: 4, PerAccount: 3, PerVenue: 1}
+	pool := NewWorkerPool(limits)
+	pool.Start(context.Background())
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	accounts, venues := map[string]int{}, map[string]int{}
+	var jobs []*PoolJob
+	for i := 0; i < 24; i++ {
+		account, venue := fmt.Sprintf("app-%d", i%2), fmt.Sprintf("form/%d", i%4)
+		jobs = append(jobs, &PoolJob{Account: account, Venue: venue, Run: func(ctx context.Context, waited time.Duration) {
+			mu.Lock()
+			running++
+			accounts[account]++
+			venues[venue]++
+			peak = max(peak, running)
+			if accounts[account] > limits.PerAccount || venues[venue] > limits.PerVenue {
+				t.Errorf("account %s = %d, venue %s = %d; over limit", account, accounts[account], venue, venues[venue])
+			}
+			mu.Unlock()
+
+			time.Sleep(2 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			accounts[account]--
+			venues[venue]--
+			mu.Unlock()
+		}})
+	}
+	pool.Submit(jobs...)
+	pool.Close()
+	pool.Wait()
+
+	if peak > limits.Global {
+		t.Fatalf("peak = %d, want <= %d", peak, limits.Global)
+	}
+	stats := pool.Stats()
+	if stats.Jobs != len(jobs) || stats.Peak != peak || stats.MaxWait <= 0 {
+		t.Fatalf("stats = %+v, want %d jobs with queue wait", stats, len(jobs))
+	}
+}
+
+// TestWorkerPoolPriority 同一批任务按优先级派发，相同优先级按提交顺序。
+func TestWorkerPoolPriority(t *testing.T) {
+	pool := NewWorkerPool(common.ConcurrencyConfig{Global: 1})
+	pool.Start(context.Background())
+
+	var mu sync.Mutex
+	var order []string
+	job := func(name string, priority int) *PoolJob {
+		return &PoolJob{Priority: priority, Run: func(ctx context.Context, waited time.Duration) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}}
+	}
+	pool.Submit(job("a", 0), job("b", 5), job("c", 0), job("d", 9), job("e", 5))
+	pool.Close()
+	pool.Wait()
+
+	if got := strings.Join(order, ""); got != "dbeac" {
+		t.Fatalf("dispatch order = %s, want dbeac", got)
+	}
+}
+
+// concurrencyClient 是记录同时进行的提交数的 APIClient：GET 按 URL 回放 cassette，POST 稍作停顿后返回成功。
+type concurrencyClient struct {
+	cassette *vcr.Cassette
+
+	mu      sync.Mutex
+	posting int
+	peak    int
+	posts   int
+}
+
+func (c *concurrencyClient) Get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	for _, interaction := range c.cassette.Interactions {
+		if interaction.URL == url {
+			return []byte(interaction.ResponseBody), nil
+		}
+	}
+	return nil, fmt.Errorf("no interaction for %s", url)
+}
+
+func (c *concurrencyClient) Post(ctx context.Context, url string, data []byte, auth string, headers map[string]string) ([]byte, error) {
+	c.mu.Lock()
+	c.posting++
+	c.posts++
+	c.peak = max(c.peak, c.posting)
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	c.posting--
+	c.mu.Unlock()
+	return []byte(`{"code":0,"message":"ok"}`), nil
+}
+
+// TestProcessorRespectsConcurrency 处理器按 run.concurrency 限制同时提交的订单数，并记录排队时间。
+func TestProcessorRespectsConcurrency(t *testing.T) {
+	form := common.DefaultFormConfig()
+	var orders []*common.Order
+	for i := 0; i < 6; i++ {
+		orders = append(orders, &common.Order{ID: uint(i + 1), Date: "2025-12-22", Hour: 20 + i%2, Venue: i + 1,
+			Form: form.Name, Status: string(common.OrderStatusPending)})
+	}
+	repo := newFakeRepository(orders...)
+	client := &concurrencyClient{cassette: sampleCassette(t, form)}
+	config := &common.Config{Run: common.RunConfig{Concurrency: common.ConcurrencyConfig{Global: 4, PerAccount: 2}}}
+	processor := NewOrderProcessor(client, repo, config)
+	processor.now = sampleNow
+
+	result, err := processor.ProcessOrdersForDate(context.Background(), "2025-12-22")
+	if err != nil {
+		t.Fatalf("ProcessOrdersForDate: %v", err)
+	}
+	if got := result.Overall(); got != RunSucceeded {
+		t.Fatalf("Overall() = %s, want SUCCEEDED; results: %+v", got, result.Orders)
+	}
+	if client.posts != len(orders) || client.peak > 2 {
+		t.Fatalf("posts = %d, peak = %d; want %d posts with at most 2 at once", client.posts, client.peak, len(orders))
+	}
+	queued := 0
+	for _, r := range result.Orders {
+		if r.QueueWait > 0 {
+			queued++
+		}
+	}
+	if queued == 0 {
+		t.Errorf("no order recorded queue wait: %+v", result.Orders)
+	}
+}
+
+// TestProcessorLimitsPerIdentity per_account 按预约身份限制：两个表单的 app_id 不同，订单仍属同一个用户，共用额度。
+func TestProcessorLimitsPerIdentity(t *testing.T) {
+	badminton := common.DefaultFormConfig()
+	tennis := common.DefaultFormConfig()
+	tennis.Name, tennis.FormID, tennis.AppID = "tennis", "tennis-form", "wx-tennis"
+	cassette := sampleCassette(t, badminton)
+	cassette.Interactions = append(cassette.Interactions, sampleCassette(t, tennis).Interactions...)
+
+	var orders []*common.Order
+	for i := 0; i < 6; i++ {
+		form := badminton
+		if i%2 == 1 {
+			form = tennis
+		}
+		orders = append(orders, &common.Order{ID: uint(i + 1), Date: "2025-12-22", Hour: 20, Venue: i/2 + 1,
+			Form: form.Name, Status: string(common.OrderStatusPending)})
+	}
+	repo := newFakeRepository(orders...)
+	client := &concurrencyClient{cassette: cassette}
+	config := &common.Config{
+		User:  common.User{StudentID: "2021001"},
+		Forms: map[string]*common.FormConfig{badminton.Name: badminton, tennis.Name: tennis},
+		Run:   common.RunConfig{Concurrency: common.ConcurrencyConfig{Global: 6, PerAccount: 1}},
+	}
+	processor := NewOrderProcessor(client, repo, config)
+	processor.now = sampleNow
+
+	result, err := processor.ProcessOrdersForDate(context.Background(), "2025-12-22")
+	if err != nil {
+		t.Fatalf("ProcessOrdersForDate: %v", err)
+	}
+	if got := result.Overall(); got != RunSucceeded {
+		t.Fatalf("Overall() = %s, want SUCCEEDED; results: %+v", got, result.Orders)
+	}
+	if client.posts != len(orders) || client.peak != 1 {
+		t.Fatalf("posts = %d, peak = %d; want %d posts one at a time", client.posts, client.peak, len(orders))
+	}
+}
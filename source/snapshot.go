@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"sports_order/common"
+	"sports_order/service"
+)
+
+// snapshotCommand 拉取表单目录、保存快照，并输出与上一次快照的差异事件。
+// 有差异时退出码为 3，便于 cron 包装脚本据此告警。
+func snapshotCommand(ctx context.Context, args []string) int {
+	fs, configPath := newFlagSet("snapshot")
+	formName := fs.String("form", "", "只处理指定表单，默认处理全部表单")
+	jsonOutput := fs.Bool("json", false, "以 JSON Lines 输出差异事件")
+	fs.Parse(args)
+
+	ctx, app, err := newApp(ctx, *configPath)
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	defer app.Close()
+
+	forms := app.config.AllForms()
+	if *formName != "" {
+		form, err := app.config.Form(*formName)
+		if err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+		forms = []*common.FormConfig{form}
+	}
+
+	apiClient, _, closeClient, err := buildAPIClient(ctx, app.config, app.repo, app.runID, "")
+	if err != nil {
+		log.Printf("初始化 API 客户端失败: %v", err)
+		return 1
+	}
+	defer closeClient()
+	history := service.NewCatalogHistory(app.repo)
+
+	changed, failed := false, false
+	for _, form := range forms {
+		booking := service.NewBookingService(apiClient, app.repo, &app.config.User, form)
+		data, err := booking.GetCatalogData(ctx)
+		if err != nil {
+			log.Printf("获取表单 %s 目录失败: %v", form.Name, err)
+			failed = true
+			continue
+		}
+
+		events, err := history.Record(ctx, form.Name, data)
+		if err != nil {
+			log.Printf("记录表单 %s 快照失败: %v", form.Name, err)
+			failed = true
+		}
+		if len(events) > 0 {
+			changed = true
+		}
+
+		for _, event := range events {
+			if *jsonOutput {
+				line, _ := json.Marshal(event)
+				fmt.Println(string(line))
+			} else {
+				fmt.Printf("[%s] %-20s %s\n", form.Name, event.Type, event.Message)
+			}
+		}
+		if !*jsonOutput {
+			fmt.Fprintf(os.Stderr, "表单 %s: 版本 %d，%d 个日期，%d 项变化\n", form.Name, data.FormVersion, len(data.DateMap), len(events))
+		}
+	}
+
+	switch {
+	case failed:
+		return 1
+	case changed:
+		return 3
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,90 @@
+// Package vcr 提供 API 请求的录制与回放：录制每次运行的请求/响应到 cassette 文件，
+// 回放时由 cassette 提供响应，用于离线复现失败的运行或编写回归测试。
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Interaction 是一次请求/响应记录。
+type Interaction struct {
+	Method         string            `json:"method"`
+	URL            string            `json:"url"`
+	RequestHeaders map[string]string `json:"request_headers,omitempty"`
+	RequestBody    string            `json:"request_body,omitempty"`
+	StatusCode     int               `json:"status_code"`             // 0 表示未收到响应（网络错误等）
+	Status         string            `json:"status,omitempty"`        // 如 "422 Unprocessable Entity"
+	ResponseBody   string            `json:"response_body,omitempty"` // 成功或错误响应的完整内容
+	Error          string            `json:"error,omitempty"`         // 未收到响应时的错误信息
+	StartedAt      time.Time         `json:"started_at"`
+	DurationMs     int64             `json:"duration_ms"`
+}
+
+// Cassette 是一次运行的全部请求记录。
+type Cassette struct {
+	RunID        string        `json:"run_id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// cassetteHeader 是录制文件（JSON Lines）的首行，之后每行一条 Interaction，见 Recorder。
+type cassetteHeader struct {
+	RunID     string    `json:"run_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Load 从文件读取 cassette：既可以是 Save 写出的单个 JSON 对象，也可以是 Recorder 录制的 JSON Lines。
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 cassette 失败: %w", err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err == nil {
+		return &cassette, nil
+	}
+	return parseLines(data)
+}
+
+// parseLines 解析 JSON Lines 格式的录制文件。录制进程崩溃时最后一行可能不完整，忽略该行。
+func parseLines(data []byte) (*Cassette, error) {
+	lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+	var header cassetteHeader
+	if err := json.Unmarshal(lines[0], &header); err != nil {
+		return nil, fmt.Errorf("解析 cassette 失败: %w", err)
+	}
+	cassette := &Cassette{RunID: header.RunID, CreatedAt: header.CreatedAt}
+	for i, line := range lines[1:] {
+		var interaction Interaction
+		if err := json.Unmarshal(line, &interaction); err != nil {
+			if i == len(lines)-2 {
+				break
+			}
+			return nil, fmt.Errorf("解析 cassette 第 %d 行失败: %w", i+2, err)
+		}
+		cassette.Interactions = append(cassette.Interactions, interaction)
+	}
+	return cassette, nil
+}
+
+// Save 将 cassette 写入文件（先写临时文件再重命名，避免中途崩溃留下半个文件）。
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 cassette 失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建 cassette 目录失败: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("写入 cassette 失败: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
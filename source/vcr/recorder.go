@@ -0,0 +1,137 @@
+package vcr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"sports_order/common"
+	"sports_order/middleware"
+)
+
+// Recorder 录制经过它的每一次请求，以 JSON Lines 格式逐条追加到文件（首行为运行 ID 与创建时间），
+// 进程中途退出时已完成的请求都已落盘。用 Load 读取。
+// 请求头中的 Authorization 以及请求/响应体里的 token、手机号、学号、姓名都会被脱敏。
+type Recorder struct {
+	mu       sync.Mutex
+	path     string
+	header   cassetteHeader
+	file     *os.File // 首次记录时创建
+	err      error    // 首个落盘错误，之后不再写入
+	redactor *common.Redactor
+}
+
+// NewRecorder 创建录制器，cassette 将写入 path。
+func NewRecorder(path, runID string, redactor *common.Redactor) *Recorder {
+	return &Recorder{
+		path:     path,
+		header:   cassetteHeader{RunID: runID, CreatedAt: time.Now()},
+		redactor: redactor,
+	}
+}
+
+// Close 关闭录制文件，返回录制期间的首个落盘错误。
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil {
+		if err := r.file.Close(); err != nil && r.err == nil {
+			r.err = fmt.Errorf("关闭 cassette 失败: %w", err)
+		}
+		r.file = nil
+	}
+	return r.err
+}
+
+// Path 返回 cassette 文件路径。
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Middleware 返回录制中间件。应放在中间件链最内层，以记录每一次实际发出的请求。
+func (r *Recorder) Middleware() middleware.Middleware {
+	return func(next middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req *middleware.Request) ([]byte, error) {
+			start := time.Now()
+			resp, err := next(ctx, req)
+			r.record(req, resp, err, start)
+			return resp, err
+		}
+	}
+}
+
+// record 追加一条记录。落盘失败不影响请求本身。
+func (r *Recorder) record(req *middleware.Request, resp []byte, err error, start time.Time) {
+	interaction := Interaction{
+		Method:         req.Method,
+		URL:            req.URL,
+		RequestHeaders: r.redactHeaders(req),
+		RequestBody:    r.redactor.Redact(string(req.Body)),
+		StartedAt:      start,
+		DurationMs:     time.Since(start).Milliseconds(),
+	}
+
+	switch httpErr, ok := common.AsHTTPError(err); {
+	case err == nil:
+		interaction.StatusCode = 200
+		interaction.Status = "200 OK"
+		interaction.ResponseBody = r.redactor.Redact(string(resp))
+	case ok:
+		interaction.StatusCode = httpErr.StatusCode
+		interaction.Status = httpErr.Status
+		interaction.ResponseBody = r.redactor.Redact(string(httpErr.Body))
+	default:
+		interaction.Error = r.redactor.Redact(err.Error())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.append(interaction)
+	}
+}
+
+// append 将一条记录写为一行，首次调用时创建文件并写入首行。调用方持有 r.mu。
+func (r *Recorder) append(interaction Interaction) error {
+	if r.file == nil {
+		if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+			return fmt.Errorf("创建 cassette 目录失败: %w", err)
+		}
+		file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("创建 cassette 失败: %w", err)
+		}
+		r.file = file
+		if err := r.writeLine(r.header); err != nil {
+			return err
+		}
+	}
+	return r.writeLine(interaction)
+}
+
+func (r *Recorder) writeLine(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("序列化 cassette 失败: %w", err)
+	}
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入 cassette 失败: %w", err)
+	}
+	return nil
+}
+
+// redactHeaders 复制请求头，并将授权信息替换为占位符。
+func (r *Recorder) redactHeaders(req *middleware.Request) map[string]string {
+	headers := make(map[string]string, len(req.Headers)+1)
+	for key, value := range req.Headers {
+		headers[key] = r.redactor.Redact(value)
+	}
+	if req.Auth != "" {
+		headers["Authorization"] = common.RedactedPlaceholder
+	}
+	return headers
+}
@@ -0,0 +1,84 @@
+package vcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"sports_order/common"
+)
+
+// Replayer 是由 cassette 提供响应的 APIClient。
+// 同一 method+URL 的请求按录制顺序依次返回对应的响应。
+type Replayer struct {
+	mu      sync.Mutex
+	queues  map[string][]Interaction
+	Matched []Interaction // 已回放的记录，便于测试断言
+}
+
+// NewReplayer 基于 cassette 创建回放客户端。
+func NewReplayer(cassette *Cassette) *Replayer {
+	r := &Replayer{queues: make(map[string][]Interaction)}
+	for _, interaction := range cassette.Interactions {
+		key := replayKey(interaction.Method, interaction.URL)
+		r.queues[key] = append(r.queues[key], interaction)
+	}
+	return r
+}
+
+// NewReplayerFromFile 从 cassette 文件创建回放客户端。
+func NewReplayerFromFile(path string) (*Replayer, error) {
+	cassette, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(cassette), nil
+}
+
+func replayKey(method, url string) string {
+	return method + " " + url
+}
+
+// next 取出下一条匹配的记录并还原为响应或错误。
+func (r *Replayer) next(ctx context.Context, method, url string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	key := replayKey(method, url)
+	queue := r.queues[key]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("cassette 中没有更多匹配的记录: %s", key)
+	}
+	interaction := queue[0]
+	r.queues[key] = queue[1:]
+	r.Matched = append(r.Matched, interaction)
+	r.mu.Unlock()
+
+	switch {
+	case interaction.StatusCode == http.StatusOK:
+		return []byte(interaction.ResponseBody), nil
+	case interaction.StatusCode != 0:
+		return nil, &common.HTTPError{
+			StatusCode: interaction.StatusCode,
+			Status:     interaction.Status,
+			Body:       []byte(interaction.ResponseBody),
+		}
+	default:
+		return nil, errors.New(interaction.Error)
+	}
+}
+
+// Get 回放 GET 请求。
+func (r *Replayer) Get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	return r.next(ctx, http.MethodGet, url)
+}
+
+// Post 回放 POST 请求。
+func (r *Replayer) Post(ctx context.Context, url string, data []byte, auth string, headers map[string]string) ([]byte, error) {
+	return r.next(ctx, http.MethodPost, url)
+}
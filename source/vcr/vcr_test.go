@@ -0,0 +1,112 @@
+package vcr
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sports_order/common"
+	"sports_order/middleware"
+)
+
+// stubClient 返回固定响应：GET 成功，POST 返回 422。
+type stubClient struct{}
+
+func (stubClient) Get(ctx context.Context, url string, headers map[string]string) ([]byte, error) {
+	return []byte(`{"code":0,"phone":"13800138000"}`), nil
+}
+
+func (stubClient) Post(ctx context.Context, url string, data []byte, auth string, headers map[string]string) ([]byte, error) {
+	return nil, &common.HTTPError{
+		StatusCode: http.StatusUnprocessableEntity,
+		Status:     "422 Unprocessable Entity",
+		Body:       []byte(`{"code":17936,"message":"您选择的时段未开放预约"}`),
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	recorder := NewRecorder(path, "run-1", common.NewRedactor("secret-token", "13800138000"))
+	client := middleware.Wrap(stubClient{}, recorder.Middleware())
+
+	ctx := context.Background()
+	client.Get(ctx, "https://example.com/catalog", map[string]string{"client-form-id": "1"})
+	client.Post(ctx, "https://example.com/form_data", []byte(`{"token":"secret-token"}`), "secret-token", nil)
+
+	cassette, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cassette.Interactions) != 2 || cassette.RunID != "run-1" {
+		t.Fatalf("cassette = %+v, want 2 interactions of run-1", cassette)
+	}
+	post := cassette.Interactions[1]
+	if post.RequestHeaders["Authorization"] != common.RedactedPlaceholder {
+		t.Errorf("Authorization = %q, want redacted", post.RequestHeaders["Authorization"])
+	}
+	if strings.Contains(post.RequestBody, "secret-token") || strings.Contains(cassette.Interactions[0].ResponseBody, "13800138000") {
+		t.Error("cassette leaked secrets")
+	}
+
+	replayer := NewReplayer(cassette)
+	if _, err := replayer.Get(ctx, "https://example.com/catalog", nil); err != nil {
+		t.Fatalf("replayed GET: %v", err)
+	}
+	_, err = replayer.Post(ctx, "https://example.com/form_data", nil, "", nil)
+	httpErr, ok := common.AsHTTPError(err)
+	if !ok || httpErr.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(string(httpErr.Body), "未开放预约") {
+		t.Fatalf("replayed POST err = %v, want full 422 response", err)
+	}
+	if _, err := replayer.Get(ctx, "https://example.com/catalog", nil); err == nil {
+		t.Fatal("replayer should fail once the cassette is exhausted")
+	}
+}
+
+// TestRecorderAppends 每次请求只在文件末尾追加一行，不重写已录制的内容；崩溃留下的半行在读取时被忽略。
+func TestRecorderAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	recorder := NewRecorder(path, "run-1", common.NewRedactor())
+	client := middleware.Wrap(stubClient{}, recorder.Middleware())
+	ctx := context.Background()
+
+	var previous []byte
+	for i := 0; i < 3; i++ {
+		client.Get(ctx, "https://example.com/profile", nil)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, previous) || bytes.Count(data, []byte("\n")) != i+2 {
+			t.Fatalf("request %d: file rewritten or not one line per request:\n%s", i+1, data)
+		}
+		previous = data
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// 模拟写到一半时崩溃
+	if err := os.WriteFile(path, append(previous, `{"method":"POST","url":"https://exa`...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cassette, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cassette.RunID != "run-1" || len(cassette.Interactions) != 3 {
+		t.Fatalf("cassette = %s with %d interactions, want run-1 with 3", cassette.RunID, len(cassette.Interactions))
+	}
+
+	// Save 写出的单个 JSON 对象仍可读取
+	saved := filepath.Join(t.TempDir(), "saved.json")
+	if err := cassette.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := Load(saved); err != nil || len(loaded.Interactions) != 3 {
+		t.Fatalf("Load(saved) = %v, %v; want 3 interactions", loaded, err)
+	}
+}